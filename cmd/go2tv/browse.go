@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/devices"
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/interactive"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+	"github.com/pkg/errors"
+)
+
+var errBrowseQuit = errors.New("quit")
+
+// runBrowse implements the "browse" mode. We browse the
+// ContentDirectory of a Media Server and cast the selected
+// item to a Media Renderer. The Media Renderer fetches the
+// item directly from the Media Server.
+func runBrowse(args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	serverArg := fs.String("s", "", "UPnP/DLNA Media Server URL. (Default: discover and pick)")
+	queryArg := fs.String("q", "", "Search the Media Server for titles that contain this text.")
+	fs.StringVar(targetPtr, "t", "", "Cast to a specific UPnP/DLNA Media Renderer URL.")
+	fs.StringVar(ifacePtr, "i", "", "Comma separated list of network interfaces to use for discovery and serving.")
+	fs.IntVar(portPtr, "p", 0, "Pin the media server listen port. (Default: first available port from 3500)")
+	fs.StringVar(advPtr, "a", "", "Advertise this host[:port] to the Media Renderer instead of the listen address.")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("runBrowse flags error: %w", err)
+	}
+
+	res := &flagResults{}
+	if err := checkIflag(res); err != nil {
+		return fmt.Errorf("runBrowse error: %w", err)
+	}
+
+	if err := checkPAflags(res); err != nil {
+		return fmt.Errorf("runBrowse error: %w", err)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+
+	dmsURL := *serverArg
+	if dmsURL == "" {
+		serverList, err := devices.LoadMediaServers(1, res.listen.Interfaces...)
+		if err != nil {
+			return fmt.Errorf("runBrowse error: %w", err)
+		}
+
+		dmsURL, err = pickMediaServer(in, serverList)
+		if err != nil {
+			return fmt.Errorf("runBrowse error: %w", err)
+		}
+	}
+
+	cds, err := soapcalls.CDSextractor(dmsURL)
+	if err != nil {
+		return fmt.Errorf("runBrowse error: %w", err)
+	}
+
+	item, err := browseLoop(in, cds.ContentDirectoryControlURL, *queryArg)
+	if errors.Is(err, errBrowseQuit) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("runBrowse error: %w", err)
+	}
+
+	if err := checkTflag(res); err != nil {
+		return fmt.Errorf("runBrowse error: %w", err)
+	}
+
+	return castRemoteItem(res, item)
+}
+
+func pickMediaServer(in *bufio.Reader, serverList map[string]string) (string, error) {
+	keys := make([]string, 0, len(serverList))
+	for k := range serverList {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	fmt.Println()
+	for q, k := range keys {
+		fmt.Printf("%3d) %s\n", q+1, k)
+	}
+
+	n, err := readChoice(in, "Select Media Server: ", len(keys))
+	if err != nil {
+		return "", err
+	}
+
+	return devices.DevicePicker(serverList, n)
+}
+
+// browseLoop lets the user navigate the ContentDirectory
+// and returns the selected item. When a query is provided,
+// we start with the search results.
+func browseLoop(in *bufio.Reader, controlURL, query string) (soapcalls.CDSObject, error) {
+	// The root container of every Media Server has the "0" ID.
+	path := []string{"0"}
+
+	for {
+		var entries []soapcalls.CDSObject
+		var err error
+
+		switch query {
+		case "":
+			entries, err = soapcalls.BrowseSoapCall(controlURL, path[len(path)-1])
+		default:
+			entries, err = soapcalls.SearchSoapCall(controlURL, "0", soapcalls.TitleSearchCriteria(query))
+		}
+
+		if err != nil {
+			return soapcalls.CDSObject{}, err
+		}
+
+		fmt.Println()
+		for q, e := range entries {
+			title := e.Title
+			if e.IsContainer {
+				title = "[" + title + "]"
+			}
+			fmt.Printf("%3d) %s\n", q+1, title)
+		}
+
+		fmt.Print("Select entry ('..' to go back, 'q' to quit): ")
+		line, err := in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return soapcalls.CDSObject{}, err
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "q" || (line == "" && err == io.EOF):
+			return soapcalls.CDSObject{}, errBrowseQuit
+		case line == "..":
+			if query != "" {
+				query = ""
+				continue
+			}
+
+			if len(path) > 1 {
+				path = path[:len(path)-1]
+			}
+			continue
+		}
+
+		n, convErr := strconv.Atoi(line)
+		if convErr != nil || n <= 0 || n > len(entries) {
+			fmt.Println("Invalid selection")
+			continue
+		}
+
+		selected := entries[n-1]
+		if selected.IsContainer {
+			// Search results are flat. Opening a container
+			// from there switches back to browsing.
+			query = ""
+			path = append(path, selected.ID)
+			continue
+		}
+
+		return selected, nil
+	}
+}
+
+func readChoice(in *bufio.Reader, prompt string, max int) (int, error) {
+	for {
+		fmt.Print(prompt)
+		line, err := in.ReadString('\n')
+		line = strings.TrimSpace(line)
+
+		if line == "q" || (line == "" && err == io.EOF) {
+			return 0, errBrowseQuit
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("readChoice error: %w", err)
+		}
+
+		n, err := strconv.Atoi(line)
+		if err == nil && n > 0 && n <= max {
+			return n, nil
+		}
+
+		fmt.Println("Invalid selection")
+	}
+}
+
+func castRemoteItem(res *flagResults, item soapcalls.CDSObject) error {
+	itemRes, err := item.PlayableRes()
+	if err != nil {
+		return fmt.Errorf("castRemoteItem error: %w", err)
+	}
+
+	upnpServicesURLs, err := soapcalls.DMRextractor(res.dmrURL)
+	if err != nil {
+		return fmt.Errorf("castRemoteItem error: %w", err)
+	}
+
+	// We still need our own server for the
+	// Media Renderer event callbacks.
+	whereToListen, whereToAdvertise, err := res.listen.Addresses(res.dmrURL)
+	if err != nil {
+		return fmt.Errorf("castRemoteItem error: %w", err)
+	}
+
+	scr, err := interactive.InitTcellNewScreen()
+	if err != nil {
+		return fmt.Errorf("castRemoteItem error: %w", err)
+	}
+
+	callbackPath, err := utils.RandomString()
+	if err != nil {
+		return fmt.Errorf("castRemoteItem error: %w", err)
+	}
+
+	tvdata := &soapcalls.TVPayload{
+		ControlURL:          upnpServicesURLs.AvtransportControlURL,
+		EventURL:            upnpServicesURLs.AvtransportEventSubURL,
+		RenderingControlURL: upnpServicesURLs.RenderingControlURL,
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
+		MediaURL:            itemRes.URL,
+		MediaType:           itemRes.MediaType(),
+		MediaMetadata:       item.Metadata,
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+
+	s := httphandlers.NewServer(whereToListen)
+	serverStarted := make(chan struct{})
+
+	go func() {
+		err := s.ServeFiles(serverStarted, nil, nil, tvdata, scr)
+		check(err)
+	}()
+	// Wait for HTTP server to properly initialize
+	<-serverStarted
+
+	// The errors are already reported.
+	if status := runSession(scr, tvdata, s); status != 0 {
+		os.Exit(status)
+	}
+
+	return nil
+}
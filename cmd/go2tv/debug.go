@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+func checkDebugflags() error {
+	if *dumpPtr && *debugPtr == "" {
+		return errors.New("checkDebugflags error: -dumpsoap requires -debug")
+	}
+
+	return nil
+}
+
+// openDebugLog opens the -debug log, if any. The terminal belongs
+// to the interactive screen, so we can only log to a file. A nil
+// RequestStats records nothing.
+func openDebugLog() (*httphandlers.RequestStats, error) {
+	if *debugPtr == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(*debugPtr, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("openDebugLog error: %w", err)
+	}
+
+	if *dumpPtr {
+		soapcalls.DumpSOAP(f)
+	}
+
+	// We only log the requests, we don't need to keep them around.
+	return httphandlers.NewRequestStats(0, func(l httphandlers.RequestLog) {
+		fmt.Fprintln(f, l)
+	}), nil
+}
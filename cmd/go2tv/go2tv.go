@@ -6,18 +6,24 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/alexballas/go2tv/internal/devices"
 	"github.com/alexballas/go2tv/internal/gui"
 	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/imageconv"
 	"github.com/alexballas/go2tv/internal/interactive"
+	"github.com/alexballas/go2tv/internal/mediaprobe"
+	"github.com/alexballas/go2tv/internal/mediatags"
 	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/transcoder"
 	"github.com/alexballas/go2tv/internal/urlstreamer"
 	"github.com/alexballas/go2tv/internal/utils"
 	"github.com/pkg/errors"
@@ -26,22 +32,49 @@ import (
 var (
 	//go:embed version.txt
 	version    string
-	mediaArg   = flag.String("v", "", "Local path to the video/audio file. (Triggers the CLI mode)")
-	urlArg     = flag.String("u", "", "HTTP URL to the media file. URL streaming does not support seek operations. (Triggers the CLI mode)")
+	mediaArg   = flag.String("v", "", "Local path to the video/audio file, or - to read it from the standard input. (Triggers the CLI mode)")
+	urlArg     = flag.String("u", "", "HTTP URL to the media file or HLS playlist. Seeking requires an origin with byte range support. (Triggers the CLI mode)")
 	subsArg    = flag.String("s", "", "Local path to the subtitles file.")
 	listPtr    = flag.Bool("l", false, "List all available UPnP/DLNA Media Renderer models and URLs.")
 	targetPtr  = flag.String("t", "", "Cast to a specific UPnP/DLNA Media Renderer URL.")
+	ifacePtr   = flag.String("i", "", "Comma separated list of network interfaces to use for discovery and serving.")
+	portPtr    = flag.Int("p", 0, "Pin the media server listen port. (Default: first available port from 3500)")
+	advPtr     = flag.String("a", "", "Advertise this host[:port] to the Media Renderer instead of the listen address. Useful in containers or behind NAT.")
+	allowPtr   = flag.String("allow", "", "Comma separated list of extra hosts, besides the Media Renderer, that can fetch the media and the subtitles. Use '*' to allow any host.")
+	ratePtr    = flag.String("rate", "", "Limit the media streaming rate, in bits per second (e.g. 20M), or pace it to the media bitrate with auto (local MP4 and MPEG-TS files). Both can be combined, e.g. auto,20M.")
+	debugPtr   = flag.String("debug", "", "Log every request of the Media Renderer (method, range, DLNA headers, status, bytes, duration) to this file.")
+	dumpPtr    = flag.Bool("dumpsoap", false, "Also dump the full SOAP requests and responses to the -debug log, e.g. for bug reports.")
+	tcPtr      = flag.Bool("tc", false, "Transcode the media with ffmpeg, for Media Renderers that can't play it. Images are never transcoded.")
+	tpPtr      = flag.String("tp", "", "Transcoding target profile (mpegts, mp4, mp3 or a custom one). Overrides the per-renderer profile.")
+	wakePtr    = flag.String("w", "", "Wake up a sleeping Media Renderer (name, URL or MAC address) via Wake-on-LAN. Casts to it when combined with -v or -u.")
 	versionPtr = flag.Bool("version", false, "Print version.")
 )
 
 type flagResults struct {
 	dmrURL string
+	listen utils.ListenConfig
 	exit   bool
 }
 
 func main() {
 	guiEnabled := true
 	var mediaFile interface{}
+
+	// The browse, serve and slideshow modes have their own set of flags.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "browse":
+			check(runBrowse(os.Args[2:]))
+			return
+		case "serve":
+			check(runServe(os.Args[2:]))
+			return
+		case "slideshow":
+			check(runSlideshow(os.Args[2:]))
+			return
+		}
+	}
+
 	flag.Parse()
 
 	flagRes, err := processflags()
@@ -51,26 +84,34 @@ func main() {
 		os.Exit(0)
 	}
 
-	if len(os.Args) > 1 {
+	if len(os.Args) > 1 && !checkGUI() {
 		guiEnabled = false
 	}
 
-	if *mediaArg != "" {
+	switch {
+	case *mediaArg == stdinArg:
+		mediaFile, err = openStdin()
+		check(err)
+	case *mediaArg != "":
 		mediaFile = *mediaArg
 	}
 
-	if *mediaArg == "" && *urlArg != "" {
-		mediaFile, err = urlstreamer.StreamURL(context.Background(), *urlArg)
+	// The transcoder reads the URL on its own.
+	if *mediaArg == "" && *urlArg != "" && !*tcPtr {
+		mediaFile, err = urlstreamer.OpenURL(context.Background(), *urlArg)
 		check(err)
 	}
 
 	if guiEnabled {
 		scr := gui.InitFyneNewScreen(version)
+		scr.SetListenConfig(flagRes.listen)
+		go quitOnSignal(scr.Quit)
 		gui.Start(scr)
 	}
 
-	var absMediaFile string
-	var mediaType string
+	var absMediaFile, mediaName string
+	var mediaType, dlnaProfile string
+	var tags *mediatags.Tags
 
 	switch t := mediaFile.(type) {
 	case string:
@@ -80,8 +121,46 @@ func main() {
 
 		mediaType, err = utils.GetMimeDetailsFromFile(absMediaFile)
 		check(err)
-	case io.ReadCloser:
+
+		dlnaProfile = mediaprobe.DLNAProfile(absMediaFile)
+
+		tags = mediatags.LoadMedia(absMediaFile, mediaType)
+
+		photo, err := newPhoto(flagRes.dmrURL, absMediaFile, mediaType)
+		check(err)
+
+		if photo != nil {
+			mediaFile = photo
+			mediaType = imageconv.MediaType
+			dlnaProfile = photo.DLNAProfile()
+			mediaName = imageconv.FileName(absMediaFile)
+		}
+	case *stdinStream:
+		absMediaFile = "stdin"
+		mediaType = t.mediaType
+	case io.ReadCloser, *urlstreamer.RangeSource:
+		absMediaFile = *urlArg
+	case *urlstreamer.BufferedStream:
 		absMediaFile = *urlArg
+
+		// HLS playlists get remuxed to MPEG-TS.
+		if t.ContentType == urlstreamer.HLSMediaType {
+			mediaType = urlstreamer.HLSMediaType
+		}
+	}
+
+	// There's no point in transcoding images.
+	if *tcPtr && !strings.HasPrefix(mediaType, "image/") {
+		if absMediaFile == "" {
+			absMediaFile = *urlArg
+		}
+
+		tc, err := newTranscoder(flagRes.dmrURL, absMediaFile)
+		check(err)
+
+		mediaFile = tc
+		mediaType = tc.Profile.MediaType
+		dlnaProfile = ""
 	}
 
 	absSubtitlesFile, err := filepath.Abs(*subsArg)
@@ -90,7 +169,7 @@ func main() {
 	upnpServicesURLs, err := soapcalls.DMRextractor(flagRes.dmrURL)
 	check(err)
 
-	whereToListen, err := utils.URLtoListenIPandPort(flagRes.dmrURL)
+	whereToListen, whereToAdvertise, err := flagRes.listen.Addresses(flagRes.dmrURL)
 	check(err)
 
 	scr, err := interactive.InitTcellNewScreen()
@@ -99,18 +178,39 @@ func main() {
 	callbackPath, err := utils.RandomString()
 	check(err)
 
+	if mediaName == "" {
+		mediaName = absMediaFile
+	}
+
+	// Tokenized paths, so that nobody can guess them.
+	mediaPath, err := utils.TokenizedPath(mediaName)
+	check(err)
+
+	subsPath, err := utils.TokenizedPath(absSubtitlesFile)
+	check(err)
+
 	tvdata := &soapcalls.TVPayload{
 		ControlURL:          upnpServicesURLs.AvtransportControlURL,
 		EventURL:            upnpServicesURLs.AvtransportEventSubURL,
 		RenderingControlURL: upnpServicesURLs.RenderingControlURL,
-		CallbackURL:         "http://" + whereToListen + "/" + callbackPath,
-		MediaURL:            "http://" + whereToListen + "/" + utils.ConvertFilename(absMediaFile),
-		SubtitlesURL:        "http://" + whereToListen + "/" + utils.ConvertFilename(absSubtitlesFile),
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
+		MediaURL:            utils.BuildHTTPURL(whereToAdvertise, mediaPath),
+		SubtitlesURL:        utils.BuildHTTPURL(whereToAdvertise, subsPath),
 		MediaType:           mediaType,
+		DLNAProfile:         dlnaProfile,
 		CurrentTimers:       make(map[string]*time.Timer),
 	}
 
+	artPath, artwork, err := tags.SetMetadata(tvdata, whereToAdvertise)
+	check(err)
+
+	stats, err := openDebugLog()
+	check(err)
+
 	s := httphandlers.NewServer(whereToListen)
+	s.AllowHosts(flagRes.listen.AllowedHosts...)
+	s.LimitRate(flagRes.listen.Rate)
+	s.TrackRequests(stats)
 	serverStarted := make(chan struct{})
 
 	// We pass the tvdata here as we need the callback handlers to be able to react
@@ -122,7 +222,11 @@ func main() {
 	// Wait for HTTP server to properly initialize
 	<-serverStarted
 
-	scr.InterInit(tvdata)
+	if artwork != nil {
+		check(s.AddArtwork(tvdata, artPath, artwork))
+	}
+
+	os.Exit(runSession(scr, tvdata, s))
 }
 
 func check(err error) {
@@ -132,9 +236,15 @@ func check(err error) {
 	}
 }
 
-func listFlagFunction() error {
+func listFlagFunction(ifaces []string) error {
 	flagsEnabled := 0
 	flag.Visit(func(f *flag.Flag) {
+		// The network flags also
+		// apply to the device listing.
+		switch f.Name {
+		case "i", "p", "a":
+			return
+		}
 		flagsEnabled++
 	})
 
@@ -142,7 +252,7 @@ func listFlagFunction() error {
 		return errors.New("cant combine -l with other flags")
 	}
 
-	deviceList, err := devices.LoadSSDPservices(1)
+	deviceList, err := devices.LoadSSDPservices(1, ifaces...)
 	if err != nil {
 		return errors.New("failed to list devices")
 	}
@@ -170,6 +280,12 @@ func listFlagFunction() error {
 		fmt.Printf("%s--------%s\n", boldStart, boldEnd)
 		fmt.Printf("%sModel:%s %s\n", boldStart, boldEnd, k)
 		fmt.Printf("%sURL:%s   %s\n", boldStart, boldEnd, deviceList[k])
+		if iface := devices.DeviceInterface(deviceList[k]); iface != "" {
+			fmt.Printf("%sIface:%s %s\n", boldStart, boldEnd, iface)
+		}
+		if mac := devices.DeviceMAC(deviceList[k]); mac != "" {
+			fmt.Printf("%sMAC:%s   %s\n", boldStart, boldEnd, mac)
+		}
 		fmt.Println()
 	}
 
@@ -184,7 +300,35 @@ func processflags() (*flagResults, error) {
 		dmrURL: "",
 	}
 
-	if checkGUI() {
+	if err := checkIflag(res); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if err := checkPAflags(res); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if err := checkAllowflag(res); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if err := checkRateflag(res); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if err := checkDebugflags(); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if err := checkTCflags(); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if err := checkWflag(res); err != nil {
+		return nil, fmt.Errorf("checkflags error: %w", err)
+	}
+
+	if res.exit || checkGUI() {
 		return res, nil
 	}
 
@@ -192,7 +336,7 @@ func processflags() (*flagResults, error) {
 		return nil, fmt.Errorf("checkflags error: %w", err)
 	}
 
-	list, err := checkLflag()
+	list, err := checkLflag(res)
 	if err != nil {
 		return nil, fmt.Errorf("checkflags error: %w", err)
 	}
@@ -214,6 +358,16 @@ func processflags() (*flagResults, error) {
 }
 
 func checkVflag() error {
+	if *mediaArg == stdinArg {
+		// The transcoder restarts ffmpeg on every
+		// request, but we can only read stdin once.
+		if *tcPtr {
+			return errors.New("checkVflags error: -tc doesn't work with the standard input")
+		}
+
+		return nil
+	}
+
 	if !*listPtr && *urlArg == "" {
 		if _, err := os.Stat(*mediaArg); os.IsNotExist(err) {
 			return fmt.Errorf("checkVflags error: %w", err)
@@ -251,7 +405,7 @@ func checkTflag(res *flagResults) error {
 
 		res.dmrURL = *targetPtr
 	} else {
-		deviceList, err := devices.LoadSSDPservices(1)
+		deviceList, err := devices.LoadSSDPservices(1, res.listen.Interfaces...)
 		if err != nil {
 			return fmt.Errorf("checkTflag service loading error: %w", err)
 		}
@@ -265,9 +419,208 @@ func checkTflag(res *flagResults) error {
 	return nil
 }
 
-func checkLflag() (bool, error) {
+func checkIflag(res *flagResults) error {
+	if *ifacePtr == "" {
+		return nil
+	}
+
+	for _, i := range strings.Split(*ifacePtr, ",") {
+		i = strings.TrimSpace(i)
+		if i == "" {
+			continue
+		}
+
+		if _, err := net.InterfaceByName(i); err != nil {
+			return fmt.Errorf("checkIflag interface error: %w", err)
+		}
+
+		res.listen.Interfaces = append(res.listen.Interfaces, i)
+	}
+
+	return nil
+}
+
+func checkPAflags(res *flagResults) error {
+	if *portPtr < 0 || *portPtr > 65535 {
+		return errors.New("checkPAflags error: invalid port number")
+	}
+
+	res.listen.Port = *portPtr
+
+	if *advPtr != "" {
+		// We only validate the advertise value here.
+		// The actual listen address is not yet known.
+		if _, err := utils.AdvertisedHostPort("0.0.0.0:3500", *advPtr); err != nil {
+			return fmt.Errorf("checkPAflags error: %w", err)
+		}
+	}
+
+	res.listen.Advertise = *advPtr
+
+	return nil
+}
+
+func checkAllowflag(res *flagResults) error {
+	for _, h := range strings.Split(*allowPtr, ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+
+		if err := httphandlers.CheckHost(h); err != nil {
+			return fmt.Errorf("checkAllowflag error: %w", err)
+		}
+
+		res.listen.AllowedHosts = append(res.listen.AllowedHosts, h)
+	}
+
+	return nil
+}
+
+func checkRateflag(res *flagResults) error {
+	r, err := utils.ParseStreamRate(*ratePtr)
+	if err != nil {
+		return fmt.Errorf("checkRateflag error: %w", err)
+	}
+
+	res.listen.Rate = r
+
+	return nil
+}
+
+func checkTCflags() error {
+	if *tpPtr == "" {
+		return nil
+	}
+
+	if !*tcPtr {
+		return errors.New("checkTCflags error: -tp requires -tc")
+	}
+
+	cfg, err := transcoder.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("checkTCflags error: %w", err)
+	}
+
+	if _, err := cfg.Profile(*tpPtr); err != nil {
+		return fmt.Errorf("checkTCflags error: %w", err)
+	}
+
+	return nil
+}
+
+// newTranscoder picks the transcoding profile of the Media
+// Renderer, unless we got one explicitly via -tp.
+func newTranscoder(dmrURL, input string) (*transcoder.Transcoder, error) {
+	cfg, err := transcoder.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("newTranscoder error: %w", err)
+	}
+
+	var p transcoder.Profile
+	switch *tpPtr {
+	case "":
+		name, _ := soapcalls.GetFriendlyName(dmrURL)
+		var host string
+		if u, err := url.Parse(dmrURL); err == nil {
+			host = u.Hostname()
+		}
+
+		p, err = cfg.ProfileFor(name, dmrURL, host)
+	default:
+		p, err = cfg.Profile(*tpPtr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("newTranscoder error: %w", err)
+	}
+
+	tc, err := cfg.New(input, p)
+	if err != nil {
+		return nil, fmt.Errorf("newTranscoder error: %w", err)
+	}
+
+	return tc, nil
+}
+
+// newPhoto converts the photos that the Media Renderer can't
+// show as they are. The rest of the media get a nil Photo.
+func newPhoto(dmrURL, input, mediaType string) (*imageconv.Photo, error) {
+	if !strings.HasPrefix(mediaType, "image/") {
+		return nil, nil
+	}
+
+	p, ffmpeg, err := photoProfile(dmrURL)
+	if err != nil {
+		return nil, fmt.Errorf("newPhoto error: %w", err)
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("newPhoto open error: %w", err)
+	}
+	defer f.Close()
+
+	if !imageconv.NeedsConversion(f, mediaType, p) {
+		return nil, nil
+	}
+
+	return imageconv.NewFile(input, p, ffmpeg), nil
+}
+
+// photoProfile picks the JPEG profile of the Media Renderer,
+// along with the ffmpeg binary that decodes the HEIC photos.
+func photoProfile(dmrURL string) (imageconv.Profile, string, error) {
+	cfg, err := transcoder.LoadConfig()
+	if err != nil {
+		return imageconv.Profile{}, "", fmt.Errorf("photoProfile error: %w", err)
+	}
+
+	name, _ := soapcalls.GetFriendlyName(dmrURL)
+	var host string
+	if u, err := url.Parse(dmrURL); err == nil {
+		host = u.Hostname()
+	}
+
+	p, err := imageconv.ProfileByName(cfg.ImageProfileFor(name, dmrURL, host))
+	if err != nil {
+		return imageconv.Profile{}, "", fmt.Errorf("photoProfile error: %w", err)
+	}
+
+	return p, cfg.FFmpeg, nil
+}
+
+func checkWflag(res *flagResults) error {
+	if *wakePtr == "" {
+		return nil
+	}
+
+	dev, err := devices.FindWakeDevice(*wakePtr)
+	if err != nil {
+		return fmt.Errorf("checkWflag error: %w", err)
+	}
+
+	fmt.Printf("Waking up %s (%s)...\n", dev.Name, dev.MAC)
+
+	if err := devices.Wake(*dev, 60*time.Second); err != nil {
+		return fmt.Errorf("checkWflag error: %w", err)
+	}
+
+	// Without any media we only wake the device up.
+	if checkGUI() {
+		fmt.Printf("%s is awake.\n", dev.Name)
+		res.exit = true
+		return nil
+	}
+
+	if *targetPtr == "" {
+		*targetPtr = dev.URL
+	}
+
+	return nil
+}
+
+func checkLflag(res *flagResults) (bool, error) {
 	if *listPtr {
-		if err := listFlagFunction(); err != nil {
+		if err := listFlagFunction(res.listen.Interfaces); err != nil {
 			return false, fmt.Errorf("checkLflag error: %w", err)
 		}
 		return true, nil
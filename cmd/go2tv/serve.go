@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alexballas/go2tv/internal/devices"
+	"github.com/alexballas/go2tv/internal/httphandlers"
+)
+
+// ssdpMulticastURL is used to pick the listen IP when we don't
+// have a Media Renderer to cast to. It resolves to the address
+// of the interface that the SSDP multicast traffic goes through.
+const ssdpMulticastURL = "http://239.255.255.250:1900"
+
+// runServe implements the "serve" mode. Go2TV becomes a UPnP/DLNA
+// Media Server that shares a folder, so that the Media Renderers
+// can browse it from their own UI.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dirArg := fs.String("d", ".", "Local path to the folder to share.")
+	nameArg := fs.String("n", "", "Media Server friendly name. (Default: Go2TV (<hostname>))")
+	fs.StringVar(ifacePtr, "i", "", "Comma separated list of network interfaces to use for discovery and serving.")
+	fs.IntVar(portPtr, "p", 0, "Pin the media server listen port. (Default: first available port from 3500)")
+	fs.StringVar(advPtr, "a", "", "Advertise this host[:port] instead of the listen address.")
+	fs.StringVar(debugPtr, "debug", "", "Log every request of the Media Renderers to this file.")
+	fs.StringVar(ratePtr, "rate", "", "Limit the media streaming rate, in bits per second (e.g. 20M), or pace it to the media bitrate with auto. Both can be combined, e.g. auto,20M.")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("runServe flags error: %w", err)
+	}
+
+	res := &flagResults{}
+	if err := checkIflag(res); err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+
+	if err := checkPAflags(res); err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+
+	if err := checkRateflag(res); err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+
+	whereToListen, whereToAdvertise, err := res.listen.Addresses(ssdpMulticastURL)
+	if err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+
+	s, err := httphandlers.NewMediaServer(*dirArg, *nameArg, whereToListen, whereToAdvertise)
+	if err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+	s.LimitRate(res.listen.Rate)
+
+	stats, err := openDebugLog()
+	if err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+	s.TrackRequests(stats)
+
+	serverStarted := make(chan struct{})
+	go func() {
+		err := s.Serve(serverStarted)
+		check(err)
+	}()
+	// Wait for HTTP server to properly initialize
+	<-serverStarted
+
+	adv, err := devices.AdvertiseDevice(s.DescriptionURL(), s.UUID(), httphandlers.MediaServerDeviceType,
+		[]string{httphandlers.ContentDirectoryServiceType, httphandlers.ConnectionManagerServiceType},
+		res.listen.Interfaces...)
+	if err != nil {
+		s.Stop()
+		return fmt.Errorf("runServe error: %w", err)
+	}
+
+	fmt.Printf("Serving %s at %s\n", *dirArg, s.DescriptionURL())
+	fmt.Println("Press Ctrl+C to stop.")
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	// Let the Media Renderers know that we're gone.
+	adv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		return fmt.Errorf("runServe error: %w", err)
+	}
+
+	return nil
+}
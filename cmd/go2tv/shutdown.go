@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/interactive"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+// How long we wait for the in-flight responses when we shut down.
+const shutdownTimeout = 5 * time.Second
+
+// runSession runs the interactive terminal of a casting session until
+// the user exits, the Media Renderer stops or we receive a SIGINT or a
+// SIGTERM, and then ends the session gracefully. It returns the exit
+// status, i.e. 0 on success, 1 on errors and 128+N on signal N.
+func runSession(scr *interactive.NewScreen, tv *soapcalls.TVPayload, s *httphandlers.HTTPserver) int {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	received := make(chan os.Signal, 1)
+	go func() {
+		sig := <-sigs
+		received <- sig
+		scr.Quit()
+	}()
+
+	status := 0
+
+	if err := scr.InterInit(tv); err != nil {
+		fmt.Fprintf(os.Stderr, "Encountered error(s): %s\n", err)
+		status = 1
+	}
+
+	select {
+	case sig := <-received:
+		status = signalStatus(sig)
+	default:
+	}
+
+	if err := endSession(tv, s, !scr.RendererStopped()); err != nil {
+		fmt.Fprintf(os.Stderr, "Encountered error(s): %s\n", err)
+		if status == 0 {
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// endSession stops the Media Renderer, if needed, cleans up the event
+// subscriptions and drains the media server.
+func endSession(tv *soapcalls.TVPayload, s *httphandlers.HTTPserver, stop bool) error {
+	tvErr := tv.Shutdown(stop)
+
+	// We exit, so no other subscription should outlive us.
+	if err := soapcalls.UnsubscribeAll(); err != nil && tvErr == nil {
+		tvErr = err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		return fmt.Errorf("endSession server error: %w", err)
+	}
+
+	if tvErr != nil {
+		return fmt.Errorf("endSession error: %w", tvErr)
+	}
+
+	return nil
+}
+
+func signalStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+
+	return 1
+}
+
+// quitOnSignal calls quit when we receive a SIGINT or a SIGTERM.
+func quitOnSignal(quit func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+
+	quit()
+}
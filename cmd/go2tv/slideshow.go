@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/interactive"
+	"github.com/alexballas/go2tv/internal/slideshow"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+// runSlideshow implements the "slideshow" mode. We cast the photos
+// of a folder, or an M3U playlist, one after the other, and optionally
+// play background music on a second Media Renderer.
+func runSlideshow(args []string) error {
+	fs := flag.NewFlagSet("slideshow", flag.ExitOnError)
+	slidesArg := fs.String("d", "", "Local path to the folder, or the M3U playlist, of the photos.")
+	durationArg := fs.Duration("slide", 5*time.Second, "How long each photo is shown.")
+	shuffleArg := fs.Bool("shuffle", false, "Shuffle the photos.")
+	musicArg := fs.String("music", "", "Local path to the background music file. Requires -mt.")
+	musicTargetArg := fs.String("mt", "", "Play the background music on this UPnP/DLNA Media Renderer URL.")
+	fs.StringVar(targetPtr, "t", "", "Cast to a specific UPnP/DLNA Media Renderer URL.")
+	fs.StringVar(ifacePtr, "i", "", "Comma separated list of network interfaces to use for discovery and serving.")
+	fs.IntVar(portPtr, "p", 0, "Pin the media server listen port. (Default: first available port from 3500)")
+	fs.StringVar(advPtr, "a", "", "Advertise this host[:port] to the Media Renderer instead of the listen address.")
+	fs.StringVar(allowPtr, "allow", "", "Comma separated list of extra hosts, besides the Media Renderers, that can fetch the photos. Use '*' to allow any host.")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("runSlideshow flags error: %w", err)
+	}
+
+	switch {
+	case *slidesArg == "":
+		return errors.New("runSlideshow error: -d is required")
+	case *durationArg <= 0:
+		return errors.New("runSlideshow error: invalid -slide duration")
+	case (*musicArg == "") != (*musicTargetArg == ""):
+		return errors.New("runSlideshow error: -music and -mt go together")
+	}
+
+	res := &flagResults{}
+	if err := checkIflag(res); err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	if err := checkPAflags(res); err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	if err := checkAllowflag(res); err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	slides, err := slideshow.LoadSlides(*slidesArg)
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	if err := checkTflag(res); err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	upnpServicesURLs, err := soapcalls.DMRextractor(res.dmrURL)
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	whereToListen, whereToAdvertise, err := res.listen.Addresses(res.dmrURL)
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	profile, ffmpeg, err := photoProfile(res.dmrURL)
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	callbackPath, err := utils.RandomString()
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	// The session payload only holds the event subscriptions.
+	// Each slide gets its own payload.
+	tvdata := &soapcalls.TVPayload{
+		ControlURL:          upnpServicesURLs.AvtransportControlURL,
+		EventURL:            upnpServicesURLs.AvtransportEventSubURL,
+		RenderingControlURL: upnpServicesURLs.RenderingControlURL,
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+
+	scr, err := interactive.InitTcellNewScreen()
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	s := httphandlers.NewServer(whereToListen)
+	s.AllowHosts(res.listen.AllowedHosts...)
+
+	caster := &slideshow.Caster{
+		Server:    s,
+		TV:        tvdata,
+		Advertise: whereToAdvertise,
+		Profile:   profile,
+		FFmpeg:    ffmpeg,
+	}
+
+	show, err := slideshow.New(slides, *durationArg, *shuffleArg, caster.Cast)
+	if err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	caster.Screen = show.Screen(scr)
+	show.OnSlide = func(_, _ int, path string, err error) {
+		title := filepath.Base(path)
+		if err != nil {
+			title += " (" + err.Error() + ")"
+		}
+		scr.SetTitle(title)
+	}
+	scr.SetSlideshow(show)
+
+	// The first slide takes the callback over.
+	if err := s.AddItem(tvdata, nil, nil, caster.Screen); err != nil {
+		return fmt.Errorf("runSlideshow error: %w", err)
+	}
+
+	serverStarted := make(chan struct{})
+	go func() {
+		err := s.Serve(serverStarted)
+		check(err)
+	}()
+	// Wait for HTTP server to properly initialize
+	<-serverStarted
+
+	var music *soapcalls.TVPayload
+	if *musicArg != "" {
+		music, err = slideshow.CastMusic(s, *musicArg, *musicTargetArg, whereToAdvertise)
+		if err != nil {
+			return fmt.Errorf("runSlideshow error: %w", err)
+		}
+	}
+
+	status := runSession(scr, tvdata, s)
+	show.Stop()
+
+	if music != nil {
+		if err := slideshow.StopMusic(s, music); err != nil && status == 0 {
+			status = 1
+		}
+	}
+
+	if status != 0 {
+		os.Exit(status)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+// stdinArg - "-v -" casts the media from the standard input.
+const stdinArg = "-"
+
+// stdinStream - The media from the standard input. It's a non-seekable
+// stream, so we serve it like the URL streams. We replay the bytes we
+// sniffed the media type from.
+type stdinStream struct {
+	io.Reader
+	io.Closer
+	mediaType string
+}
+
+func openStdin() (*stdinStream, error) {
+	mediaType, r, err := utils.PeekMimeDetails(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("openStdin error: %w", err)
+	}
+
+	// The Media Renderers reject the media without a media
+	// type, and they rarely tell us why.
+	if mediaType == "" {
+		return nil, errors.New("openStdin: unknown media type")
+	}
+
+	return &stdinStream{Reader: r, Closer: os.Stdin, mediaType: mediaType}, nil
+}
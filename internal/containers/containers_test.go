@@ -0,0 +1,64 @@
+package containers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func box(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	out := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint32(out, uint32(8+len(b)))
+	copy(out[4:], typ)
+	return append(out, b...)
+}
+
+func TestChildBox(t *testing.T) {
+	data := bytes.Join([][]byte{
+		box("ftyp", []byte("isom")),
+		box("moov", box("mvhd", make([]byte, 8)), box("udta", box("meta", []byte("tags")))),
+	}, nil)
+
+	meta, ok := ChildBox(bytes.NewReader(data), Box{Size: int64(len(data))}, "moov", "udta", "meta")
+	if !ok || string(data[meta.Off:meta.Off+meta.Size]) != "tags" {
+		t.Fatalf("got: %+v, want: the meta box.", meta)
+	}
+
+	if _, ok := ChildBox(bytes.NewReader(data), Box{Size: int64(len(data))}, "moov", "trak"); ok {
+		t.Errorf("got: a trak box, want: none.")
+	}
+
+	var types []string
+	EachBox(data[meta.Off-8-8:], func(typ string, body []byte) {
+		types = append(types, typ)
+	})
+
+	if len(types) != 1 || types[0] != "udta" {
+		t.Errorf("got: %v, want: [udta].", types)
+	}
+}
+
+func TestIsTS(t *testing.T) {
+	ts := bytes.Repeat(append([]byte{TSSyncByte}, make([]byte, TSPacketSize-1)...), 3)
+	m2ts := bytes.Repeat(append([]byte{0, 0, 0, 0, TSSyncByte}, make([]byte, M2TSPacketSize-5)...), 3)
+
+	tt := []struct {
+		input      []byte
+		packetSize int
+		prefix     int
+		want       bool
+		name       string
+	}{
+		{ts, TSPacketSize, 0, true, `MPEG-TS`},
+		{m2ts, M2TSPacketSize, 4, true, `M2TS`},
+		{ts[:2*TSPacketSize], TSPacketSize, 0, false, `Two packets`},
+		{append([]byte("GIF89a"), make([]byte, TSHeadSize)...), TSPacketSize, 0, false, `GIF`},
+	}
+
+	for _, tc := range tt {
+		if got := IsTS(tc.input, tc.packetSize, tc.prefix); got != tc.want {
+			t.Errorf("%s: got: %t, want: %t.", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package containers walks the structure of the media containers,
+// i.e. the boxes of the MP4 files and the packets of the MPEG-TS
+// streams, for the packages that read the media details.
+package containers
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Box - An MP4 box.
+type Box struct {
+	Type string
+	// Off and Size - The offset and the size of the box body.
+	Off, Size int64
+}
+
+// ReadBoxes - Read the box headers between off and end. We read the
+// headers one by one, as the moov box can get to a few MBs and we
+// often only need a few bytes of it.
+func ReadBoxes(r io.ReaderAt, off, end int64) ([]Box, error) {
+	var boxes []Box
+	hdr := make([]byte, 16)
+
+	for off+8 <= end {
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return nil, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:8])
+		hdrSize := int64(8)
+
+		switch boxSize {
+		case 0:
+			boxSize = end - off
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return nil, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			hdrSize = 16
+		}
+
+		if boxSize < hdrSize || off+boxSize > end {
+			break
+		}
+
+		boxes = append(boxes, Box{Type: typ, Off: off + hdrSize, Size: boxSize - hdrSize})
+		off += boxSize
+	}
+
+	return boxes, nil
+}
+
+// ChildBox - Find the first box of the path under the parent,
+// e.g. "moov", "udta", "meta". Use Box{Size: size} as the parent
+// for the top level boxes.
+func ChildBox(r io.ReaderAt, parent Box, path ...string) (Box, bool) {
+	for _, typ := range path {
+		boxes, err := ReadBoxes(r, parent.Off, parent.Off+parent.Size)
+		if err != nil {
+			return Box{}, false
+		}
+
+		found := false
+		for _, b := range boxes {
+			if b.Type == typ {
+				parent, found = b, true
+				break
+			}
+		}
+
+		if !found {
+			return Box{}, false
+		}
+	}
+
+	return parent, true
+}
+
+// EachBox - Call fn for every child box of b.
+func EachBox(b []byte, fn func(typ string, body []byte)) {
+	for len(b) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(b[:4]))
+		typ := string(b[4:8])
+		hdr := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			hdr = 16
+		}
+
+		if size < hdr || size > uint64(len(b)) {
+			return
+		}
+
+		fn(typ, b[hdr:size])
+		b = b[size:]
+	}
+}
@@ -0,0 +1,34 @@
+package containers
+
+const (
+	// TSPacketSize - The size of the MPEG-TS packets.
+	TSPacketSize = 188
+	// M2TSPacketSize - The size of the M2TS packets, i.e. the
+	// MPEG-TS packets with a 4 byte timestamp prefix.
+	M2TSPacketSize = 192
+	// TSSyncByte - The first byte of every MPEG-TS packet.
+	TSSyncByte = 0x47
+
+	// tsSyncPackets - How many packets in a row need
+	// to start with the sync byte.
+	tsSyncPackets = 3
+)
+
+// TSHeadSize - How much of the media IsTS needs.
+const TSHeadSize = tsSyncPackets * M2TSPacketSize
+
+// IsTS - Check for the sync byte at the start of the first packets.
+// A single sync byte is not enough, as it's just a 'G'.
+func IsTS(head []byte, packetSize, prefix int) bool {
+	if len(head) < (tsSyncPackets-1)*packetSize+prefix+1 {
+		return false
+	}
+
+	for i := 0; i < tsSyncPackets; i++ {
+		if head[i*packetSize+prefix] != TSSyncByte {
+			return false
+		}
+	}
+
+	return true
+}
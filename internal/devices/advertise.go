@@ -0,0 +1,116 @@
+package devices
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/utils"
+	"github.com/koron/go-ssdp"
+)
+
+const advertiseMaxAge = 1800
+
+// ssdpMu guards the ssdp.Interfaces global, which go-ssdp reads when
+// we start advertising. The searches use their own sockets instead.
+var ssdpMu sync.Mutex
+
+// Advertiser - SSDP advertisement of a local UPnP device.
+type Advertiser struct {
+	ads  []*ssdp.Advertiser
+	quit chan struct{}
+	done chan struct{}
+}
+
+// AdvertiseDevice - Advertise the root device and its services.
+// We answer the M-SEARCH requests and periodically send
+// ssdp:alive messages until Close is called.
+func AdvertiseDevice(location, uuid, deviceType string, serviceTypes []string, ifaces ...string) (*Advertiser, error) {
+	iflist, err := utils.NetInterfaces(ifaces)
+	if err != nil {
+		return nil, fmt.Errorf("AdvertiseDevice interfaces error: %w", err)
+	}
+
+	udn := "uuid:" + uuid
+	server := runtime.GOOS + "/1.0 UPnP/1.0 Go2TV/1.0"
+
+	// The notification types and USNs as
+	// defined in the UPnP Device Architecture.
+	targets := [][2]string{
+		{"upnp:rootdevice", udn + "::upnp:rootdevice"},
+		{udn, udn},
+		{deviceType, udn + "::" + deviceType},
+	}
+
+	for _, st := range serviceTypes {
+		targets = append(targets, [2]string{st, udn + "::" + st})
+	}
+
+	a := &Advertiser{
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	ssdpMu.Lock()
+	ssdp.Interfaces = iflist
+	for _, t := range targets {
+		ad, err := ssdp.Advertise(t[0], t[1], location, server, advertiseMaxAge)
+		if err != nil {
+			ssdp.Interfaces = nil
+			ssdpMu.Unlock()
+			a.closeAll()
+			return nil, fmt.Errorf("AdvertiseDevice error: %w", err)
+		}
+		a.ads = append(a.ads, ad)
+	}
+	ssdp.Interfaces = nil
+	ssdpMu.Unlock()
+
+	go a.aliveLoop()
+
+	return a, nil
+}
+
+func (a *Advertiser) aliveLoop() {
+	defer close(a.done)
+
+	a.alive()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.alive()
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+func (a *Advertiser) alive() {
+	for _, ad := range a.ads {
+		_ = ad.Alive()
+	}
+}
+
+// Close - Send the ssdp:byebye messages and
+// stop the advertisement.
+func (a *Advertiser) Close() {
+	close(a.quit)
+	<-a.done
+
+	for _, ad := range a.ads {
+		_ = ad.Bye()
+	}
+
+	a.closeAll()
+}
+
+func (a *Advertiser) closeAll() {
+	for _, ad := range a.ads {
+		ad.Close()
+	}
+}
@@ -2,41 +2,170 @@ package devices
 
 import (
 	"fmt"
+	"net/url"
 	"sort"
+	"sync"
 
 	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
 	"github.com/koron/go-ssdp"
 	"github.com/pkg/errors"
 )
 
+var (
+	// The local interfaces the devices were discovered on, by
+	// location. Each search of a service type replaces the
+	// entries of that type, unless the search failed.
+	deviceIfaces   = make(map[string]deviceIface)
+	deviceIfacesMu sync.Mutex
+)
+
+type deviceIface struct {
+	name        string
+	serviceType string
+}
+
 // LoadSSDPservices .
-func LoadSSDPservices(delay int) (map[string]string, error) {
+// When network interface names are provided, the SSDP
+// search is restricted to those interfaces.
+func LoadSSDPservices(delay int, ifaces ...string) (map[string]string, error) {
+	// We only care about the AVTransport services for basic actions
+	// (stop,play,pause). If we need support other functionalities
+	// like volume control we need to use the RenderingControl service.
+	deviceList, searchErr := searchServices("urn:schemas-upnp-org:service:AVTransport:1", delay, ifaces)
+	if deviceList == nil {
+		return nil, fmt.Errorf("LoadSSDPservices error: %w", searchErr)
+	}
+
+	// Manually registered devices are merged even when
+	// the SSDP search fails, as multicast may be blocked.
+	mergeStaticDevices(deviceList)
+
+	if len(deviceList) > 0 {
+		// Keep track of the MAC addresses while the
+		// devices are awake, so we can wake them up later.
+		rememberDevices(deviceList)
+		return deviceList, nil
+	}
+
+	if searchErr != nil {
+		return nil, fmt.Errorf("LoadSSDPservices search error: %w", searchErr)
+	}
+
+	return nil, errors.New("loadSSDPservices: No available Media Renderers")
+}
+
+// LoadMediaServers - Discover the UPnP/DLNA Media Servers
+// that provide a ContentDirectory service.
+func LoadMediaServers(delay int, ifaces ...string) (map[string]string, error) {
+	deviceList, searchErr := searchServices("urn:schemas-upnp-org:service:ContentDirectory:1", delay, ifaces)
+	if deviceList == nil {
+		return nil, fmt.Errorf("LoadMediaServers error: %w", searchErr)
+	}
+
+	if len(deviceList) > 0 {
+		return deviceList, nil
+	}
+
+	if searchErr != nil {
+		return nil, fmt.Errorf("LoadMediaServers search error: %w", searchErr)
+	}
+
+	return nil, errors.New("loadMediaServers: No available Media Servers")
+}
+
+// searchServices returns the friendly names and locations of the
+// devices that provide the service type. A nil map is only
+// returned when the interfaces are invalid. The SSDP search error
+// is returned next to the (possibly empty) results.
+func searchServices(serviceType string, delay int, ifaces []string) (map[string]string, error) {
+	iflist, err := utils.NetInterfaces(ifaces)
+	if err != nil {
+		return nil, fmt.Errorf("searchServices interfaces error: %w", err)
+	}
+
 	// Reset device list every time we call this.
 	deviceList := make(map[string]string)
-	list, err := ssdp.Search(ssdp.All, delay, "")
-	if err != nil {
-		return nil, fmt.Errorf("LoadSSDPservices search error: %w", err)
+	ifaceList := make(map[string]string)
+
+	// The IPv6 search runs in parallel so
+	// that we don't double the discovery time.
+	ipv6Results := make(chan []ssdpResponse, 1)
+	go func() {
+		res, _ := searchIPv6(ssdp.All, delay, iflist)
+		ipv6Results <- res
+	}()
+
+	list, searchErr := searchIPv4(ssdp.All, delay, iflist)
+
+	// Dual stack devices respond to both searches.
+	// We prefer the IPv4 location in that case.
+	for _, srv := range append(list, <-ipv6Results...) {
+		if srv.Type != serviceType {
+			continue
+		}
+
+		friendlyName, err := soapcalls.GetFriendlyName(srv.Location)
+		if err != nil {
+			continue
+		}
+
+		if _, exists := deviceList[friendlyName]; exists {
+			continue
+		}
+
+		deviceList[friendlyName] = srv.Location
+		if srv.Iface != "" {
+			ifaceList[srv.Location] = srv.Iface
+		}
 	}
 
-	for _, srv := range list {
-		// We only care about the AVTransport services for basic actions
-		// (stop,play,pause). If we need support other functionalities
-		// like volume control we need to use the RenderingControl service.
-		if srv.Type == "urn:schemas-upnp-org:service:AVTransport:1" {
-			friendlyName, err := soapcalls.GetFriendlyName(srv.Location)
-			if err != nil {
-				continue
-			}
+	// After a failed search, we keep the interfaces of the
+	// devices that may still be there.
+	recordInterfaces(serviceType, ifaceList, searchErr == nil)
 
-			deviceList[friendlyName] = srv.Location
+	return deviceList, searchErr
+}
+
+// recordInterfaces records the interfaces of the devices, by location,
+// that we found with the serviceType search. When prune is set, the
+// devices of the previous searches that didn't respond are dropped.
+func recordInterfaces(serviceType string, ifaces map[string]string, prune bool) {
+	deviceIfacesMu.Lock()
+	defer deviceIfacesMu.Unlock()
+
+	if prune {
+		for location, d := range deviceIfaces {
+			if _, found := ifaces[location]; !found && d.serviceType == serviceType {
+				delete(deviceIfaces, location)
+			}
 		}
 	}
 
-	if len(deviceList) > 0 {
-		return deviceList, nil
+	for location, iface := range ifaces {
+		deviceIfaces[location] = deviceIface{name: iface, serviceType: serviceType}
 	}
+}
 
-	return nil, errors.New("loadSSDPservices: No available Media Renderers")
+// DeviceInterface - Return the name of the local network
+// interface the device was found on. For the devices that
+// were not discovered, e.g. the static ones, it's the interface
+// we reach them through.
+func DeviceInterface(dmrURL string) string {
+	deviceIfacesMu.Lock()
+	iface, ok := deviceIfaces[dmrURL]
+	deviceIfacesMu.Unlock()
+
+	if ok {
+		return iface.name
+	}
+
+	u, err := url.Parse(dmrURL)
+	if err != nil {
+		return ""
+	}
+
+	return utils.InterfaceForHost(u.Hostname())
 }
 
 // DevicePicker .
@@ -0,0 +1,154 @@
+package devices
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type ssdpResponse struct {
+	Type     string
+	Location string
+	// Iface - The local interface the response arrived on.
+	Iface string
+}
+
+// searchSocket opens the socket we send the M-SEARCH request
+// from through the interface. It's nil for the interfaces we
+// can't search on.
+type searchSocket func(ifi net.Interface) *net.UDPConn
+
+// searchInterfaces sends the M-SEARCH request to the multicast
+// address on every interface (or only on the selected ones), each
+// from a socket of its own. Since the responses are sent back to
+// the socket the request came from, we know the interface every
+// Media Renderer was found on.
+func searchInterfaces(iflist []net.Interface, open searchSocket, dst *net.UDPAddr, msg []byte, delay int) ([]ssdpResponse, int, error) {
+	if len(iflist) == 0 {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, 0, fmt.Errorf("searchInterfaces error: %w", err)
+		}
+		iflist = all
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		out  = make([]ssdpResponse, 0)
+		sent int
+	)
+
+	for _, ifi := range iflist {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+
+		conn := open(ifi)
+		if conn == nil {
+			continue
+		}
+
+		to := *dst
+		if to.IP.To4() == nil {
+			to.Zone = ifi.Name
+		}
+
+		if _, err := conn.WriteToUDP(msg, &to); err != nil {
+			conn.Close()
+			continue
+		}
+		sent++
+
+		wg.Add(1)
+		go func(conn *net.UDPConn, iface string) {
+			defer wg.Done()
+			defer conn.Close()
+
+			res := readResponses(conn, iface, delay)
+
+			mu.Lock()
+			out = append(out, res...)
+			mu.Unlock()
+		}(conn, ifi.Name)
+	}
+
+	wg.Wait()
+
+	return out, sent, nil
+}
+
+// readResponses collects the M-SEARCH responses
+// that arrive on the socket within the delay.
+func readResponses(conn *net.UDPConn, iface string, delay int) []ssdpResponse {
+	conn.SetReadDeadline(time.Now().Add(time.Duration(delay) * time.Second))
+
+	out := make([]ssdpResponse, 0)
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// We always exit via the read deadline.
+			break
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		zone := from.Zone
+		if zone == "" && from.IP.To4() == nil {
+			zone = iface
+		}
+
+		location := addLocationZone(resp.Header.Get("LOCATION"), zone)
+		if location == "" {
+			continue
+		}
+
+		out = append(out, ssdpResponse{
+			Type:     resp.Header.Get("ST"),
+			Location: location,
+			Iface:    iface,
+		})
+	}
+
+	return out
+}
+
+// searchMessage builds the SSDP M-SEARCH request.
+func searchMessage(host, searchType string, delay int) []byte {
+	var msg bytes.Buffer
+	msg.WriteString("M-SEARCH * HTTP/1.1\r\n")
+	fmt.Fprintf(&msg, "HOST: %s\r\n", host)
+	msg.WriteString("MAN: \"ssdp:discover\"\r\n")
+	fmt.Fprintf(&msg, "MX: %d\r\n", delay)
+	fmt.Fprintf(&msg, "ST: %s\r\n", searchType)
+	msg.WriteString("\r\n")
+
+	return msg.Bytes()
+}
+
+// hasAddr reports whether the interface has an IPv4
+// address, or an IPv6 one when v6 is set.
+func hasAddr(ifi net.Interface, v6 bool) bool {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return false
+	}
+
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if ok && (ipnet.IP.To4() == nil) == v6 {
+			return true
+		}
+	}
+
+	return false
+}
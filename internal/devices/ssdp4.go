@@ -0,0 +1,50 @@
+package devices
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+var ssdpIPv4Addr = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// searchIPv4 sends an SSDP M-SEARCH request to 239.255.255.250
+// on every IPv4 capable interface (or only on the selected ones)
+// and returns the responses that arrive within the delay.
+func searchIPv4(searchType string, delay int, iflist []net.Interface) ([]ssdpResponse, error) {
+	msg := searchMessage("239.255.255.250:1900", searchType, delay)
+
+	out, sent, err := searchInterfaces(iflist, openIPv4Socket, ssdpIPv4Addr, msg, delay)
+	if err != nil {
+		return nil, fmt.Errorf("searchIPv4 error: %w", err)
+	}
+
+	if sent == 0 {
+		return nil, fmt.Errorf("searchIPv4 error: no IPv4 multicast interfaces")
+	}
+
+	return out, nil
+}
+
+func openIPv4Socket(ifi net.Interface) *net.UDPConn {
+	if !hasAddr(ifi, false) {
+		return nil
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil
+	}
+
+	p := ipv4.NewPacketConn(conn)
+	if err := p.SetMulticastInterface(&ifi); err != nil {
+		conn.Close()
+		return nil
+	}
+
+	// The UPnP Device Architecture recommends a TTL of 2.
+	p.SetMulticastTTL(2)
+
+	return conn
+}
@@ -0,0 +1,64 @@
+package devices
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// The go-ssdp package only supports IPv4, so we
+// implement the IPv6 M-SEARCH on our own. We only send
+// to the link-local scope multicast address and collect
+// the unicast responses.
+var ssdpIPv6Addr = &net.UDPAddr{IP: net.ParseIP("ff02::c"), Port: 1900}
+
+// searchIPv6 sends an SSDP M-SEARCH request to ff02::c on
+// every IPv6 capable interface (or only on the selected ones)
+// and returns the responses that arrive within the delay.
+func searchIPv6(searchType string, delay int, iflist []net.Interface) ([]ssdpResponse, error) {
+	msg := searchMessage("[FF02::C]:1900", searchType, delay)
+
+	out, _, err := searchInterfaces(iflist, openIPv6Socket, ssdpIPv6Addr, msg, delay)
+	if err != nil {
+		return nil, fmt.Errorf("searchIPv6 error: %w", err)
+	}
+
+	return out, nil
+}
+
+func openIPv6Socket(ifi net.Interface) *net.UDPConn {
+	if !hasAddr(ifi, true) {
+		return nil
+	}
+
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6unspecified})
+	if err != nil {
+		return nil
+	}
+
+	return conn
+}
+
+// addLocationZone adds the zone of the interface the response
+// arrived on to link-local locations. Without it we can't
+// reach the Media Renderer.
+func addLocationZone(location, zone string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil || !ip.IsLinkLocalUnicast() || ip.To4() != nil || zone == "" {
+		return location
+	}
+
+	host := ip.String() + "%" + zone
+	if u.Port() != "" {
+		u.Host = net.JoinHostPort(host, u.Port())
+	} else {
+		u.Host = "[" + host + "]"
+	}
+
+	return u.String()
+}
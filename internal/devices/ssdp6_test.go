@@ -0,0 +1,38 @@
+package devices
+
+import "testing"
+
+func TestAddLocationZone(t *testing.T) {
+	tt := []struct {
+		name     string
+		location string
+		zone     string
+		want     string
+	}{
+		{
+			`Test #1`,
+			`http://[fe80::10]:9197/dmr`,
+			`eth0`,
+			`http://[fe80::10%25eth0]:9197/dmr`,
+		},
+		{
+			`Test #2`,
+			`http://[2001:db8::10]:9197/dmr`,
+			`eth0`,
+			`http://[2001:db8::10]:9197/dmr`,
+		},
+		{
+			`Test #3`,
+			`http://192.168.88.244:9197/dmr`,
+			`eth0`,
+			`http://192.168.88.244:9197/dmr`,
+		},
+	}
+
+	for _, tc := range tt {
+		out := addLocationZone(tc.location, tc.zone)
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package devices
+
+import (
+	"net"
+	"testing"
+)
+
+func TestReadResponses(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("no IPv4 loopback: %s", err)
+	}
+	defer conn.Close()
+
+	resp := "HTTP/1.1 200 OK\r\n" +
+		"ST: urn:schemas-upnp-org:service:AVTransport:1\r\n" +
+		"LOCATION: http://192.168.88.244:9197/dmr\r\n\r\n"
+
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	for _, r := range []string{resp, "not an SSDP response"} {
+		if _, err := sender.Write([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := readResponses(conn, "eth1", 1)
+	if len(out) != 1 {
+		t.Fatalf("got: %d responses, want: 1.", len(out))
+	}
+
+	if out[0].Location != "http://192.168.88.244:9197/dmr" || out[0].Iface != "eth1" {
+		t.Errorf("got: %s on %s, want: http://192.168.88.244:9197/dmr on eth1.", out[0].Location, out[0].Iface)
+	}
+}
+
+func TestDeviceInterface(t *testing.T) {
+	const (
+		avTransport = "urn:schemas-upnp-org:service:AVTransport:1"
+		dmr         = "http://127.0.0.1:9197/dmr"
+		gone        = "http://127.0.0.1:9198/dmr"
+	)
+
+	// The subnet of the device says nothing
+	// about the interface it was found on.
+	recordInterfaces(avTransport, map[string]string{dmr: "eth1", gone: "eth2"}, true)
+
+	if iface := DeviceInterface(dmr); iface != "eth1" {
+		t.Errorf("got: %s, want: %s.", iface, "eth1")
+	}
+
+	// A failed search keeps the devices that didn't respond.
+	recordInterfaces(avTransport, map[string]string{dmr: "eth1"}, false)
+
+	if iface := DeviceInterface(gone); iface != "eth2" {
+		t.Errorf("Failed search: got: %s, want: %s.", iface, "eth2")
+	}
+
+	// The other service types keep their devices.
+	recordInterfaces("urn:schemas-upnp-org:service:ContentDirectory:1", nil, true)
+
+	if iface := DeviceInterface(dmr); iface != "eth1" {
+		t.Errorf("Other service type: got: %s, want: %s.", iface, "eth1")
+	}
+
+	// A complete search drops the devices that went away.
+	recordInterfaces(avTransport, map[string]string{dmr: "eth1"}, true)
+
+	deviceIfacesMu.Lock()
+	_, exists := deviceIfaces[gone]
+	deviceIfacesMu.Unlock()
+
+	if exists {
+		t.Errorf("Gone device: got: an interface, want: none.")
+	}
+}
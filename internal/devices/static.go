@@ -0,0 +1,258 @@
+package devices
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// StaticDevice - A manually registered Media Renderer.
+// Those are merged with the SSDP discovered ones and are
+// useful for networks where multicast traffic is blocked.
+type StaticDevice struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+var (
+	// staticDevicesFile can be overridden in tests.
+	staticDevicesFile = defaultStaticDevicesFile
+	staticMu          sync.Mutex
+
+	// Ports and description paths that are commonly used
+	// by Media Renderers. We probe those when the user only
+	// provides an IP address or a hostname.
+	commonPorts = []string{"9197", "7676", "52235", "1400", "55000", "49152", "49153", "49154", "8080", "80"}
+	commonPaths = []string{"/dmr", "/description.xml", "/DeviceDescription.xml", "/rootDesc.xml",
+		"/upnp/description.xml", "/dd.xml", "/xml/device_description.xml", "/MediaRenderer/desc.xml"}
+)
+
+func defaultStaticDevicesFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("staticDevicesFile config dir error: %w", err)
+	}
+
+	return filepath.Join(dir, "go2tv", "devices.json"), nil
+}
+
+// LoadStaticDevices - Read the manually registered Media Renderers
+// from the config file. A missing file is not an error.
+func LoadStaticDevices() ([]StaticDevice, error) {
+	staticMu.Lock()
+	defer staticMu.Unlock()
+
+	return loadStaticDevices()
+}
+
+func loadStaticDevices() ([]StaticDevice, error) {
+	f, err := staticDevicesFile()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(f)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadStaticDevices read error: %w", err)
+	}
+
+	var list []StaticDevice
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("loadStaticDevices unmarshal error: %w", err)
+	}
+
+	return list, nil
+}
+
+func saveStaticDevices(list []StaticDevice) error {
+	f, err := staticDevicesFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+		return fmt.Errorf("saveStaticDevices mkdir error: %w", err)
+	}
+
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("saveStaticDevices marshal error: %w", err)
+	}
+
+	if err := os.WriteFile(f, b, 0o644); err != nil {
+		return fmt.Errorf("saveStaticDevices write error: %w", err)
+	}
+
+	return nil
+}
+
+// AddStaticDevice - Resolve the address to a Media Renderer
+// description URL and store it in the static devices list.
+// The address can either be the description URL itself or
+// an IP/hostname (with an optional port).
+func AddStaticDevice(addr string) (*StaticDevice, error) {
+	dmrURL, err := ResolveDeviceAddress(addr)
+	if err != nil {
+		return nil, fmt.Errorf("AddStaticDevice resolve error: %w", err)
+	}
+
+	name, err := soapcalls.GetFriendlyName(dmrURL)
+	if err != nil || name == "" {
+		name = addr
+	}
+
+	dev := StaticDevice{Name: name, URL: dmrURL}
+
+	staticMu.Lock()
+	defer staticMu.Unlock()
+
+	list, err := loadStaticDevices()
+	if err != nil {
+		return nil, fmt.Errorf("AddStaticDevice load error: %w", err)
+	}
+
+	for q, d := range list {
+		if d.URL == dmrURL {
+			list[q] = dev
+			return &dev, saveStaticDevices(list)
+		}
+	}
+
+	list = append(list, dev)
+	if err := saveStaticDevices(list); err != nil {
+		return nil, fmt.Errorf("AddStaticDevice save error: %w", err)
+	}
+
+	return &dev, nil
+}
+
+// RemoveStaticDevice - Remove a Media Renderer from the
+// static devices list.
+func RemoveStaticDevice(dmrURL string) error {
+	staticMu.Lock()
+	defer staticMu.Unlock()
+
+	list, err := loadStaticDevices()
+	if err != nil {
+		return fmt.Errorf("RemoveStaticDevice load error: %w", err)
+	}
+
+	out := list[:0]
+	for _, d := range list {
+		if d.URL != dmrURL {
+			out = append(out, d)
+		}
+	}
+
+	return saveStaticDevices(out)
+}
+
+// ResolveDeviceAddress - Return the description URL of the Media Renderer
+// behind addr. If addr is not a URL, we probe the common ports and paths.
+func ResolveDeviceAddress(addr string) (string, error) {
+	if addr == "" {
+		return "", errors.New("resolveDeviceAddress: empty address")
+	}
+
+	if u, err := url.Parse(addr); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		if !isMediaRenderer(addr) {
+			return "", errors.New("resolveDeviceAddress: not a Media Renderer description URL")
+		}
+		return addr, nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port provided.
+		host = addr
+	}
+
+	ports := commonPorts
+	if port != "" {
+		ports = []string{port}
+	}
+
+	for _, p := range ports {
+		hostPort := net.JoinHostPort(host, p)
+		if !utils.HostPortIsAlive(hostPort) {
+			continue
+		}
+
+		for _, path := range commonPaths {
+			candidate := "http://" + hostPort + path
+			if isMediaRenderer(candidate) {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", errors.New("resolveDeviceAddress: no Media Renderer found at " + addr)
+}
+
+func isMediaRenderer(dmrURL string) bool {
+	_, err := soapcalls.DMRextractor(dmrURL)
+	return err == nil
+}
+
+// mergeStaticDevices - Add the reachable static devices
+// to the discovered devices list.
+func mergeStaticDevices(deviceList map[string]string) {
+	list, err := LoadStaticDevices()
+	if err != nil {
+		return
+	}
+
+	for _, d := range list {
+		var known bool
+		for _, l := range deviceList {
+			if l == d.URL {
+				known = true
+				break
+			}
+		}
+
+		if known {
+			continue
+		}
+
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			continue
+		}
+
+		hostPort := u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			hostPort = net.JoinHostPort(u.Hostname(), port)
+		}
+
+		if !utils.HostPortIsAlive(hostPort) {
+			continue
+		}
+
+		name := d.Name
+		if friendlyName, err := soapcalls.GetFriendlyName(d.URL); err == nil && friendlyName != "" {
+			name = friendlyName
+		}
+
+		if _, exists := deviceList[name]; exists {
+			name = name + " (" + u.Host + ")"
+		}
+
+		deviceList[name] = d.URL
+	}
+}
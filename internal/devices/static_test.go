@@ -0,0 +1,119 @@
+package devices
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<device>
+<friendlyName>Meeting Room TV</friendlyName>
+<serviceList>
+<service>
+<serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>
+<serviceId>urn:upnp-org:serviceId:AVTransport</serviceId>
+<controlURL>/upnp/control/AVTransport1</controlURL>
+<eventSubURL>/upnp/event/AVTransport1</eventSubURL>
+</service>
+</serviceList>
+</device>
+</root>`
+
+func newTestRenderer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/description.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testDescription)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestResolveDeviceAddress(t *testing.T) {
+	srv := newTestRenderer()
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+
+	tt := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			`Test #1`,
+			srv.URL + "/description.xml",
+			srv.URL + "/description.xml",
+		},
+		{
+			`Test #2`,
+			u.Host,
+			srv.URL + "/description.xml",
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := ResolveDeviceAddress(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call ResolveDeviceAddress due to %s", tc.name, err.Error())
+			continue
+		}
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+
+	if _, err := ResolveDeviceAddress(srv.URL + "/missing.xml"); err == nil {
+		t.Errorf("Test #3: expected error for non Media Renderer URL")
+	}
+}
+
+func TestStaticDevices(t *testing.T) {
+	srv := newTestRenderer()
+	defer srv.Close()
+
+	dir, err := os.MkdirTemp("", "go2tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	staticDevicesFile = func() (string, error) {
+		return filepath.Join(dir, "devices.json"), nil
+	}
+	defer func() { staticDevicesFile = defaultStaticDevicesFile }()
+
+	dev, err := AddStaticDevice(srv.URL + "/description.xml")
+	if err != nil {
+		t.Fatalf("Failed to call AddStaticDevice due to %s", err.Error())
+	}
+
+	if dev.Name != "Meeting Room TV" {
+		t.Errorf("got: %s, want: %s.", dev.Name, "Meeting Room TV")
+	}
+
+	deviceList := make(map[string]string)
+	mergeStaticDevices(deviceList)
+
+	if deviceList["Meeting Room TV"] != dev.URL {
+		t.Errorf("static device not merged: %v", deviceList)
+	}
+
+	if err := RemoveStaticDevice(dev.URL); err != nil {
+		t.Fatalf("Failed to call RemoveStaticDevice due to %s", err.Error())
+	}
+
+	list, err := LoadStaticDevices()
+	if err != nil {
+		t.Fatalf("Failed to call LoadStaticDevices due to %s", err.Error())
+	}
+
+	if len(list) != 0 {
+		t.Errorf("got: %d static devices, want: 0.", len(list))
+	}
+}
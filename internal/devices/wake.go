@@ -0,0 +1,262 @@
+package devices
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// WakeDevice - A Media Renderer that we can wake up via
+// Wake-on-LAN. We record the MAC addresses of the discovered
+// Media Renderers, as we can't discover them while they sleep.
+type WakeDevice struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	MAC  string `json:"mac"`
+}
+
+var (
+	// wakeDevicesFile can be overridden in tests.
+	wakeDevicesFile = defaultWakeDevicesFile
+	wakeMu          sync.Mutex
+
+	// neighbourMACs can be overridden in tests.
+	neighbourMACs = defaultNeighbourMACs
+
+	// rememberedAt - When we last looked up the MAC address
+	// of every discovered Media Renderer, by name and URL.
+	// The GUI keeps refreshing the device list, so we only
+	// look them up again every rememberInterval.
+	rememberedAt = make(map[[2]string]time.Time)
+)
+
+const rememberInterval = 10 * time.Minute
+
+var defaultNeighbourMACs = utils.NeighbourMACs
+
+func defaultWakeDevicesFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("wakeDevicesFile config dir error: %w", err)
+	}
+
+	return filepath.Join(dir, "go2tv", "wake.json"), nil
+}
+
+// LoadWakeDevices - Read the Media Renderers that we
+// know the MAC address of. A missing file is not an error.
+func LoadWakeDevices() ([]WakeDevice, error) {
+	wakeMu.Lock()
+	defer wakeMu.Unlock()
+
+	return loadWakeDevices()
+}
+
+func loadWakeDevices() ([]WakeDevice, error) {
+	f, err := wakeDevicesFile()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(f)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadWakeDevices read error: %w", err)
+	}
+
+	var list []WakeDevice
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("loadWakeDevices unmarshal error: %w", err)
+	}
+
+	return list, nil
+}
+
+func saveWakeDevices(list []WakeDevice) error {
+	f, err := wakeDevicesFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+		return fmt.Errorf("saveWakeDevices mkdir error: %w", err)
+	}
+
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("saveWakeDevices marshal error: %w", err)
+	}
+
+	if err := os.WriteFile(f, b, 0o644); err != nil {
+		return fmt.Errorf("saveWakeDevices write error: %w", err)
+	}
+
+	return nil
+}
+
+// rememberDevices - Record the MAC addresses of the discovered
+// Media Renderers. We get them from the neighbour table, which
+// has fresh entries right after the discovery. The devices we
+// recently stored are skipped, while the ones that are not in
+// the neighbour table yet get looked up again.
+func rememberDevices(deviceList map[string]string) {
+	wakeMu.Lock()
+	defer wakeMu.Unlock()
+
+	now := time.Now()
+	fresh := make(map[string]string)
+	for name, dmrURL := range deviceList {
+		key := [2]string{name, dmrURL}
+		if now.Sub(rememberedAt[key]) < rememberInterval {
+			continue
+		}
+
+		fresh[name] = dmrURL
+	}
+
+	if len(fresh) == 0 {
+		return
+	}
+
+	macs, err := neighbourMACs()
+	if err != nil {
+		return
+	}
+
+	list, err := loadWakeDevices()
+	if err != nil {
+		return
+	}
+
+	var changed bool
+	var stored [][2]string
+	for name, dmrURL := range fresh {
+		u, err := url.Parse(dmrURL)
+		if err != nil {
+			continue
+		}
+
+		mac, ok := macs[u.Hostname()]
+		if !ok {
+			continue
+		}
+
+		dev := WakeDevice{Name: name, URL: dmrURL, MAC: mac.String()}
+		stored = append(stored, [2]string{name, dmrURL})
+
+		var found bool
+		for q, d := range list {
+			if d.URL == dmrURL || d.MAC == dev.MAC {
+				found = true
+				if d != dev {
+					list[q] = dev
+					changed = true
+				}
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, dev)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := saveWakeDevices(list); err != nil {
+			return
+		}
+	}
+
+	for _, key := range stored {
+		rememberedAt[key] = now
+	}
+}
+
+// FindWakeDevice - Find a known Media Renderer by its
+// friendly name, description URL or MAC address.
+func FindWakeDevice(s string) (*WakeDevice, error) {
+	list, err := LoadWakeDevices()
+	if err != nil {
+		return nil, fmt.Errorf("FindWakeDevice error: %w", err)
+	}
+
+	for _, d := range list {
+		if d.Name == s || d.URL == s || d.MAC == s {
+			return &d, nil
+		}
+	}
+
+	return nil, errors.New("findWakeDevice: unknown device " + s +
+		" - the device needs to be discovered once while awake")
+}
+
+// Wake - Send the Wake-on-LAN magic packet and wait until the
+// Media Renderer description URL is reachable again. We keep
+// resending the packet, as TVs may miss it while powering up.
+func Wake(dev WakeDevice, timeout time.Duration) error {
+	mac, err := net.ParseMAC(dev.MAC)
+	if err != nil {
+		return fmt.Errorf("Wake MAC error: %w", err)
+	}
+
+	u, err := url.Parse(dev.URL)
+	if err != nil {
+		return fmt.Errorf("Wake URL error: %w", err)
+	}
+
+	hostPort := u.Host
+	if u.Port() == "" {
+		hostPort = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastSent time.Time
+
+	for time.Now().Before(deadline) {
+		if time.Since(lastSent) >= 10*time.Second {
+			if err := utils.SendMagicPacket(mac); err != nil {
+				return fmt.Errorf("Wake error: %w", err)
+			}
+			lastSent = time.Now()
+		}
+
+		if utils.HostPortIsAlive(hostPort) {
+			if _, err := soapcalls.DMRextractor(dev.URL); err == nil {
+				return nil
+			}
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	return errors.New("wake: " + dev.Name + " did not wake up in time")
+}
+
+// DeviceMAC - Return the recorded MAC address
+// of the Media Renderer, if we know it.
+func DeviceMAC(dmrURL string) string {
+	list, err := LoadWakeDevices()
+	if err != nil {
+		return ""
+	}
+
+	for _, d := range list {
+		if d.URL == dmrURL {
+			return d.MAC
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,96 @@
+package devices
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWakeDevices(t *testing.T) {
+	dir, err := os.MkdirTemp("", "go2tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wakeDevicesFile = func() (string, error) {
+		return filepath.Join(dir, "wake.json"), nil
+	}
+	defer func() { wakeDevicesFile = defaultWakeDevicesFile }()
+
+	mac, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	neighbourMACs = func() (map[string]net.HardwareAddr, error) {
+		return map[string]net.HardwareAddr{"192.168.1.10": mac}, nil
+	}
+	defer func() { neighbourMACs = defaultNeighbourMACs }()
+
+	rememberDevices(map[string]string{
+		"Living Room TV": "http://192.168.1.10:8080/description.xml",
+		"Bedroom TV":     "http://192.168.1.11:8080/description.xml",
+	})
+
+	tt := []struct {
+		input string
+		name  string
+	}{
+		{"Living Room TV", `By name`},
+		{"http://192.168.1.10:8080/description.xml", `By URL`},
+		{"aa:bb:cc:dd:ee:ff", `By MAC`},
+	}
+
+	for _, tc := range tt {
+		dev, err := FindWakeDevice(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call FindWakeDevice due to %s", tc.name, err.Error())
+			continue
+		}
+		if dev.MAC != mac.String() {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, dev.MAC, mac.String())
+		}
+	}
+
+	if _, err := FindWakeDevice("Bedroom TV"); err == nil {
+		t.Errorf("Bedroom TV: expected error for device without a known MAC address")
+	}
+
+	// The renderer moved to a new port, but it's the same device.
+	rememberDevices(map[string]string{
+		"Living Room TV": "http://192.168.1.10:9090/description.xml",
+	})
+
+	list, err := LoadWakeDevices()
+	if err != nil {
+		t.Fatalf("Failed to call LoadWakeDevices due to %s", err.Error())
+	}
+
+	if len(list) != 1 || list[0].URL != "http://192.168.1.10:9090/description.xml" {
+		t.Errorf("got: %v, want: a single updated device.", list)
+	}
+
+	// The refreshes of the same devices don't
+	// look up the neighbour table again.
+	var lookups int
+	neighbourMACs = func() (map[string]net.HardwareAddr, error) {
+		lookups++
+		return nil, nil
+	}
+
+	rememberDevices(map[string]string{
+		"Living Room TV": "http://192.168.1.10:9090/description.xml",
+	})
+
+	if lookups != 0 {
+		t.Errorf("got: %d neighbour table lookups, want: 0.", lookups)
+	}
+
+	// The devices without a known MAC address
+	// get looked up again on the next refresh.
+	rememberDevices(map[string]string{
+		"Bedroom TV": "http://192.168.1.11:8080/description.xml",
+	})
+
+	if lookups != 1 {
+		t.Errorf("Bedroom TV: got: %d neighbour table lookups, want: 1.", lookups)
+	}
+}
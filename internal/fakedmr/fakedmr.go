@@ -0,0 +1,521 @@
+// Package fakedmr implements an in-process UPnP/DLNA Media Renderer.
+// We use it to test the casting logic end to end, without a real TV.
+package fakedmr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+const (
+	avTransportService      = "urn:schemas-upnp-org:service:AVTransport:1"
+	renderingControlService = "urn:schemas-upnp-org:service:RenderingControl:1"
+
+	descriptionPath      = "/description.xml"
+	avTransportControl   = "/AVTransport/control"
+	avTransportEvent     = "/AVTransport/event"
+	renderingControlPath = "/RenderingControl/control"
+)
+
+// Quirks - Configurable Media Renderer behaviours. The zero
+// value gives us a well behaved Media Renderer.
+type Quirks struct {
+	// Faults maps the SOAP actions to the UPnP error
+	// code we reply with, e.g. {"GetVolume": 501}.
+	Faults map[string]int
+	// SubscriptionTimeout is the TIMEOUT value of the
+	// SUBSCRIBE replies, in seconds. Defaults to 300.
+	SubscriptionTimeout int
+	// InitialEventDelay delays the initial event that we send
+	// right after a new subscription. Defaults to 100ms.
+	InitialEventDelay time.Duration
+	// NoInitialEvent skips the initial event.
+	NoInitialEvent bool
+	// RejectRenewals replies with 412 to the subscription
+	// renewals, as if the subscription expired.
+	RejectRenewals bool
+	// FetchMedia makes the Media Renderer request the
+	// media URL after SetAVTransportURI, like most TVs do.
+	FetchMedia bool
+	// EventOnLoad sends the STOPPED event that follows
+	// SetAVTransportURI. Most TVs don't send it.
+	EventOnLoad bool
+}
+
+// Renderer - A fake Media Renderer backed by an httptest.Server.
+type Renderer struct {
+	quirks Quirks
+	srv    *httptest.Server
+	events chan event
+
+	mu            sync.Mutex
+	state         string
+	uri           string
+	metadata      string
+	volume        int
+	mute          bool
+	actions       []string
+	subs          map[string]*subscription
+	subscribed    int
+	renewed       int
+	mediaHeaders  http.Header
+	mediaFetchErr error
+}
+
+type subscription struct {
+	callback string
+	seq      int
+}
+
+type event struct {
+	delay    time.Duration
+	sid      string
+	callback string
+	seq      int
+	state    string
+}
+
+// New - Start a fake Media Renderer.
+func New(q Quirks) *Renderer {
+	if q.SubscriptionTimeout == 0 {
+		q.SubscriptionTimeout = 300
+	}
+
+	if q.InitialEventDelay == 0 {
+		q.InitialEventDelay = 100 * time.Millisecond
+	}
+
+	r := &Renderer{
+		quirks: q,
+		events: make(chan event, 64),
+		state:  "NO_MEDIA_PRESENT",
+		volume: 10,
+		subs:   make(map[string]*subscription),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(descriptionPath, r.descriptionHandler)
+	mux.HandleFunc(avTransportControl, r.controlHandler)
+	mux.HandleFunc(renderingControlPath, r.controlHandler)
+	mux.HandleFunc(avTransportEvent, r.eventHandler)
+
+	r.srv = httptest.NewServer(mux)
+
+	// A single sender keeps the events in order.
+	go r.sendEvents()
+
+	return r
+}
+
+// URL - The device description URL.
+func (r *Renderer) URL() string {
+	return r.srv.URL + descriptionPath
+}
+
+// Close - Stop the Media Renderer.
+func (r *Renderer) Close() {
+	r.srv.Close()
+	close(r.events)
+}
+
+// State - The current TransportState.
+func (r *Renderer) State() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// CurrentURI - The media URL of the last SetAVTransportURI action.
+func (r *Renderer) CurrentURI() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.uri
+}
+
+// CurrentMetadata - The DIDL-Lite metadata of the
+// last SetAVTransportURI action.
+func (r *Renderer) CurrentMetadata() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.metadata
+}
+
+// Volume .
+func (r *Renderer) Volume() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.volume
+}
+
+// Muted .
+func (r *Renderer) Muted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mute
+}
+
+// Actions - The SOAP actions we received, in order.
+func (r *Renderer) Actions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.actions...)
+}
+
+// Subscriptions - The number of active subscriptions, and the
+// number of new subscriptions and renewals we received.
+func (r *Renderer) Subscriptions() (active, subscribed, renewed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.subs), r.subscribed, r.renewed
+}
+
+// MediaHeaders - The response headers of the media request
+// when the FetchMedia quirk is enabled.
+func (r *Renderer) MediaHeaders() (http.Header, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mediaHeaders, r.mediaFetchErr
+}
+
+// EndOfMedia - Simulate the end of the playback.
+func (r *Renderer) EndOfMedia() {
+	r.setState("STOPPED", true)
+}
+
+func (r *Renderer) descriptionHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	fmt.Fprintf(w, deviceDescription, avTransportService, avTransportControl, avTransportEvent,
+		renderingControlService, renderingControlPath)
+}
+
+type soapRequest struct {
+	Body struct {
+		Action struct {
+			XMLName xml.Name
+			Args    []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+func (r *Renderer) controlHandler(w http.ResponseWriter, req *http.Request) {
+	var soapReq soapRequest
+	if err := xml.NewDecoder(req.Body).Decode(&soapReq); err != nil {
+		soapFault(w, 402)
+		return
+	}
+
+	action := soapReq.Body.Action.XMLName.Local
+	args := make(map[string]string)
+	for _, a := range soapReq.Body.Action.Args {
+		args[a.XMLName.Local] = a.Value
+	}
+
+	r.mu.Lock()
+	r.actions = append(r.actions, action)
+	fault := r.quirks.Faults[action]
+	r.mu.Unlock()
+
+	if fault != 0 {
+		soapFault(w, fault)
+		return
+	}
+
+	service := avTransportService
+	if req.URL.Path == renderingControlPath {
+		service = renderingControlService
+	}
+
+	var out [][2]string
+	switch action {
+	case "SetAVTransportURI":
+		r.mu.Lock()
+		r.uri = args["CurrentURI"]
+		r.metadata = args["CurrentURIMetaData"]
+		r.mu.Unlock()
+		r.setState("STOPPED", r.quirks.EventOnLoad)
+
+		if r.quirks.FetchMedia {
+			r.fetchMedia(args["CurrentURI"])
+		}
+	case "Play":
+		r.setState("PLAYING", true)
+	case "Pause":
+		r.setState("PAUSED_PLAYBACK", true)
+	case "Stop":
+		r.setState("STOPPED", true)
+	case "GetVolume":
+		out = [][2]string{{"CurrentVolume", strconv.Itoa(r.Volume())}}
+	case "SetVolume":
+		v, err := strconv.Atoi(args["DesiredVolume"])
+		if err != nil || v < 0 || v > 100 {
+			soapFault(w, 402)
+			return
+		}
+		r.mu.Lock()
+		r.volume = v
+		r.mu.Unlock()
+	case "GetMute":
+		mute := "0"
+		if r.Muted() {
+			mute = "1"
+		}
+		out = [][2]string{{"CurrentMute", mute}}
+	case "SetMute":
+		r.mu.Lock()
+		r.mute = args["DesiredMute"] == "1" || args["DesiredMute"] == "true"
+		r.mu.Unlock()
+	default:
+		soapFault(w, 401)
+		return
+	}
+
+	soapResponse(w, service, action, out)
+}
+
+func (r *Renderer) fetchMedia(u string) {
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		r.mu.Lock()
+		r.mediaFetchErr = err
+		r.mu.Unlock()
+		return
+	}
+
+	req.Header.Set("getcontentFeatures.dlna.org", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.mediaFetchErr = err
+		return
+	}
+	resp.Body.Close()
+
+	r.mediaHeaders = resp.Header
+	if resp.StatusCode != http.StatusOK {
+		r.mediaFetchErr = fmt.Errorf("media request failed: %s", resp.Status)
+	}
+}
+
+// eventHandler implements the GENA subscriptions.
+func (r *Renderer) eventHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "SUBSCRIBE":
+		r.subscribe(w, req)
+	case "UNSUBSCRIBE":
+		sid := req.Header.Get("SID")
+
+		r.mu.Lock()
+		_, exists := r.subs[sid]
+		delete(r.subs, sid)
+		r.mu.Unlock()
+
+		if !exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Renderer) subscribe(w http.ResponseWriter, req *http.Request) {
+	timeout := "Second-" + strconv.Itoa(r.quirks.SubscriptionTimeout)
+
+	// Renewal
+	if sid := req.Header.Get("SID"); sid != "" {
+		r.mu.Lock()
+		_, exists := r.subs[sid]
+		if exists && !r.quirks.RejectRenewals {
+			r.renewed++
+		}
+		r.mu.Unlock()
+
+		if !exists || r.quirks.RejectRenewals {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		w.Header()["SID"] = []string{sid}
+		w.Header()["TIMEOUT"] = []string{timeout}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	callback := strings.Trim(req.Header.Get("CALLBACK"), "<>")
+	if callback == "" || req.Header.Get("NT") != "upnp:event" {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	id, err := utils.RandomString()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid := "uuid:" + id
+
+	r.mu.Lock()
+	r.subs[sid] = &subscription{callback: callback}
+	r.subscribed++
+	state := r.state
+	if !r.quirks.NoInitialEvent {
+		r.queueEvent(sid, r.quirks.InitialEventDelay, state)
+	}
+	r.mu.Unlock()
+
+	w.Header()["SID"] = []string{sid}
+	w.Header()["TIMEOUT"] = []string{timeout}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *Renderer) setState(s string, notify bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state = s
+	if !notify {
+		return
+	}
+
+	for sid := range r.subs {
+		r.queueEvent(sid, 0, s)
+	}
+}
+
+// queueEvent must be called with the lock held.
+func (r *Renderer) queueEvent(sid string, delay time.Duration, state string) {
+	sub := r.subs[sid]
+	r.events <- event{
+		delay:    delay,
+		sid:      sid,
+		callback: sub.callback,
+		seq:      sub.seq,
+		state:    state,
+	}
+	sub.seq++
+}
+
+func (r *Renderer) sendEvents() {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	for e := range r.events {
+		time.Sleep(e.delay)
+
+		req, err := http.NewRequest("NOTIFY", e.callback, bytes.NewBufferString(lastChangeEvent(e.state)))
+		if err != nil {
+			continue
+		}
+
+		req.Header = http.Header{
+			"Content-Type": []string{`text/xml; charset="utf-8"`},
+			"NT":           []string{"upnp:event"},
+			"NTS":          []string{"upnp:propchange"},
+			"SID":          []string{e.sid},
+			"SEQ":          []string{strconv.Itoa(e.seq)},
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func lastChangeEvent(state string) string {
+	actions := "Play"
+	switch state {
+	case "PLAYING":
+		actions = "Pause,Stop"
+	case "PAUSED_PLAYBACK":
+		actions = "Play,Stop"
+	}
+
+	inner := `<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0">` +
+		`<TransportState val="` + state + `"/>` +
+		`<CurrentTransportActions val="` + actions + `"/>` +
+		`</InstanceID></Event>`
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>`)
+	xml.EscapeText(&b, []byte(inner))
+	b.WriteString(`</LastChange></e:property></e:propertyset>`)
+
+	return b.String()
+}
+
+func soapResponse(w http.ResponseWriter, service, action string, args [][2]string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+
+	var b strings.Builder
+	b.WriteString(soapEnvelopeStart)
+	fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, service)
+	for _, a := range args {
+		b.WriteString("<" + a[0] + ">")
+		xml.EscapeText(&b, []byte(a[1]))
+		b.WriteString("</" + a[0] + ">")
+	}
+	fmt.Fprintf(&b, `</u:%sResponse>`, action)
+	b.WriteString(soapEnvelopeEnd)
+
+	io.WriteString(w, b.String())
+}
+
+func soapFault(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, soapEnvelopeStart+soapFaultBody+soapEnvelopeEnd, code)
+}
+
+const soapEnvelopeStart = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+	`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`
+
+const soapEnvelopeEnd = `</s:Body></s:Envelope>`
+
+const soapFaultBody = `<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>` +
+	`<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0">` +
+	`<errorCode>%d</errorCode></UPnPError></detail></s:Fault>`
+
+const deviceDescription = `<?xml version="1.0" encoding="utf-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>Fake Media Renderer</friendlyName>
+    <UDN>uuid:00000000-0000-0000-0000-000000000001</UDN>
+    <serviceList>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:AVTransport</serviceId>
+        <controlURL>%s</controlURL>
+        <eventSubURL>%s</eventSubURL>
+      </service>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:RenderingControl</serviceId>
+        <controlURL>%s</controlURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`
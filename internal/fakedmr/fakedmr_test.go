@@ -0,0 +1,306 @@
+package fakedmr_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/fakedmr"
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+// testScreen implements the httphandlers.Screen interface.
+type testScreen struct {
+	msgs chan string
+	fini chan struct{}
+}
+
+func newTestScreen() *testScreen {
+	return &testScreen{
+		msgs: make(chan string, 16),
+		fini: make(chan struct{}, 1),
+	}
+}
+
+func (s *testScreen) EmitMsg(m string) {
+	s.msgs <- m
+}
+
+func (s *testScreen) Fini() {
+	s.fini <- struct{}{}
+}
+
+func (s *testScreen) waitMsg(t *testing.T, want string) {
+	t.Helper()
+
+	select {
+	case got := <-s.msgs:
+		if got != want {
+			t.Fatalf("Screen message: got: %s, want: %s.", got, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Screen message: timed out waiting for %s.", want)
+	}
+}
+
+// startCasting sets up our media server for the fake Media
+// Renderer and sends the Play1 action, like the CLI does.
+func startCasting(t *testing.T, dmr *fakedmr.Renderer, metadata string) (*soapcalls.TVPayload, *testScreen, func()) {
+	t.Helper()
+
+	upnpServicesURLs, err := soapcalls.DMRextractor(dmr.URL())
+	if err != nil {
+		t.Fatalf("DMRextractor: %s", err)
+	}
+
+	whereToListen, err := utils.URLtoListenIPandPort(dmr.URL())
+	if err != nil {
+		t.Fatalf("URLtoListenIPandPort: %s", err)
+	}
+
+	tvdata := &soapcalls.TVPayload{
+		ControlURL:          upnpServicesURLs.AvtransportControlURL,
+		EventURL:            upnpServicesURLs.AvtransportEventSubURL,
+		RenderingControlURL: upnpServicesURLs.RenderingControlURL,
+		CallbackURL:         utils.BuildHTTPURL(whereToListen, "callback"),
+		MediaURL:            utils.BuildHTTPURL(whereToListen, "video.mp4"),
+		MediaType:           "video/mp4",
+		MediaMetadata:       metadata,
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+
+	scr := newTestScreen()
+	s := httphandlers.NewServer(whereToListen)
+	serverStarted := make(chan struct{})
+	go func() {
+		if err := s.ServeFiles(serverStarted, []byte("fake video"), nil, tvdata, scr); err != nil {
+			t.Errorf("ServeFiles: %s", err)
+		}
+	}()
+	<-serverStarted
+
+	if err := tvdata.SendtoTV("Play1"); err != nil {
+		s.StopServeFiles()
+		t.Fatalf("SendtoTV Play1: %s", err)
+	}
+
+	return tvdata, scr, s.StopServeFiles
+}
+
+func TestPlayPauseStop(t *testing.T) {
+	dmr := fakedmr.New(fakedmr.Quirks{FetchMedia: true})
+	defer dmr.Close()
+
+	tvdata, scr, stop := startCasting(t, dmr, "")
+	defer stop()
+
+	scr.waitMsg(t, "Playing")
+
+	if dmr.CurrentURI() != tvdata.MediaURL {
+		t.Errorf("CurrentURI: got: %s, want: %s.", dmr.CurrentURI(), tvdata.MediaURL)
+	}
+
+	if !strings.Contains(dmr.CurrentMetadata(), "object.item.videoItem.movie") {
+		t.Errorf("CurrentMetadata: got: %s, want: a videoItem.", dmr.CurrentMetadata())
+	}
+
+	headers, err := dmr.MediaHeaders()
+	if err != nil {
+		t.Fatalf("Media request: %s", err)
+	}
+
+	if headers.Get("contentFeatures.dlna.org") == "" {
+		t.Errorf("Media request: missing contentFeatures.dlna.org header.")
+	}
+
+	if err := tvdata.SendtoTV("Pause"); err != nil {
+		t.Fatalf("SendtoTV Pause: %s", err)
+	}
+	scr.waitMsg(t, "Paused")
+
+	if err := tvdata.SendtoTV("Play"); err != nil {
+		t.Fatalf("SendtoTV Play: %s", err)
+	}
+	scr.waitMsg(t, "Playing")
+
+	if err := tvdata.SendtoTV("Stop"); err != nil {
+		t.Fatalf("SendtoTV Stop: %s", err)
+	}
+
+	if dmr.State() != "STOPPED" {
+		t.Errorf("State: got: %s, want: STOPPED.", dmr.State())
+	}
+
+	// An explicit Stop cleans up the subscriptions first.
+	if active, _, _ := dmr.Subscriptions(); active != 0 {
+		t.Errorf("Active subscriptions: got: %d, want: 0.", active)
+	}
+
+	want := []string{"SetAVTransportURI", "Play", "Pause", "Play", "Stop"}
+	if got := dmr.Actions(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Actions: got: %v, want: %v.", got, want)
+	}
+}
+
+func TestEndOfMedia(t *testing.T) {
+	dmr := fakedmr.New(fakedmr.Quirks{})
+	defer dmr.Close()
+
+	_, scr, stop := startCasting(t, dmr, "")
+	defer stop()
+
+	scr.waitMsg(t, "Playing")
+
+	dmr.EndOfMedia()
+	scr.waitMsg(t, "Stopped")
+
+	select {
+	case <-scr.fini:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Fini: timed out.")
+	}
+
+	if active, _, _ := dmr.Subscriptions(); active != 0 {
+		t.Errorf("Active subscriptions: got: %d, want: 0.", active)
+	}
+}
+
+func TestMetadataPassthrough(t *testing.T) {
+	dmr := fakedmr.New(fakedmr.Quirks{})
+	defer dmr.Close()
+
+	metadata := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/"><item id="64$1"></item></DIDL-Lite>`
+
+	_, scr, stop := startCasting(t, dmr, metadata)
+	defer stop()
+
+	scr.waitMsg(t, "Playing")
+
+	if dmr.CurrentMetadata() != metadata {
+		t.Errorf("CurrentMetadata: got: %s, want: %s.", dmr.CurrentMetadata(), metadata)
+	}
+}
+
+func TestVolumeMute(t *testing.T) {
+	dmr := fakedmr.New(fakedmr.Quirks{})
+	defer dmr.Close()
+
+	tvdata := &soapcalls.TVPayload{}
+	upnpServicesURLs, err := soapcalls.DMRextractor(dmr.URL())
+	if err != nil {
+		t.Fatalf("DMRextractor: %s", err)
+	}
+	tvdata.RenderingControlURL = upnpServicesURLs.RenderingControlURL
+
+	if err := tvdata.SetVolumeSoapCall("42"); err != nil {
+		t.Fatalf("SetVolumeSoapCall: %s", err)
+	}
+
+	volume, err := tvdata.GetVolumeSoapCall()
+	if err != nil {
+		t.Fatalf("GetVolumeSoapCall: %s", err)
+	}
+
+	if volume != 42 {
+		t.Errorf("Volume: got: %d, want: %d.", volume, 42)
+	}
+
+	tt := []struct {
+		input string
+		want  bool
+		name  string
+	}{
+		{"1", true, "Mute"},
+		{"0", false, "Unmute"},
+	}
+
+	for _, tc := range tt {
+		if err := tvdata.SetMuteSoapCall(tc.input); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		if dmr.Muted() != tc.want {
+			t.Errorf("%s: got: %t, want: %t.", tc.name, dmr.Muted(), tc.want)
+		}
+
+		got, err := tvdata.GetMuteSoapCall()
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		if got != tc.input {
+			t.Errorf("%s: GetMute got: %s, want: %s.", tc.name, got, tc.input)
+		}
+	}
+}
+
+func TestVolumeFault(t *testing.T) {
+	dmr := fakedmr.New(fakedmr.Quirks{Faults: map[string]int{"GetVolume": 501}})
+	defer dmr.Close()
+
+	upnpServicesURLs, err := soapcalls.DMRextractor(dmr.URL())
+	if err != nil {
+		t.Fatalf("DMRextractor: %s", err)
+	}
+
+	tvdata := &soapcalls.TVPayload{RenderingControlURL: upnpServicesURLs.RenderingControlURL}
+	if _, err := tvdata.GetVolumeSoapCall(); err == nil {
+		t.Errorf("GetVolumeSoapCall: got: nil, want: an error.")
+	}
+}
+
+func TestSubscriptionRenewal(t *testing.T) {
+	tt := []struct {
+		quirks      fakedmr.Quirks
+		wantRenewed int
+		wantActive  int
+		name        string
+	}{
+		{fakedmr.Quirks{}, 1, 1, "Renewal accepted"},
+		{fakedmr.Quirks{RejectRenewals: true}, 0, 0, "Renewal rejected"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dmr := fakedmr.New(tc.quirks)
+			defer dmr.Close()
+
+			tvdata, scr, stop := startCasting(t, dmr, "")
+			defer stop()
+
+			scr.waitMsg(t, "Playing")
+
+			if len(tvdata.CurrentTimers) != 1 {
+				t.Fatalf("Refresh timers: got: %d, want: 1.", len(tvdata.CurrentTimers))
+			}
+
+			var sid string
+			for k, timer := range tvdata.CurrentTimers {
+				timer.Stop()
+				sid = k
+			}
+
+			// That's what the refresh timer calls.
+			if err := tvdata.SubscribeSoapCall(sid); err != nil {
+				t.Fatalf("SubscribeSoapCall: %s", err)
+			}
+
+			active, subscribed, renewed := dmr.Subscriptions()
+			if subscribed != 1 || renewed != tc.wantRenewed || active != tc.wantActive {
+				t.Errorf("Subscriptions: got: %d/%d/%d, want: %d/%d/%d.",
+					active, subscribed, renewed, tc.wantActive, 1, tc.wantRenewed)
+			}
+
+			_, rearmed := tvdata.CurrentTimers[sid]
+			if rearmed != (tc.wantRenewed == 1) {
+				t.Errorf("Refresh timer re-armed: got: %t, want: %t.", rearmed, tc.wantRenewed == 1)
+			}
+
+			for _, timer := range tvdata.CurrentTimers {
+				timer.Stop()
+			}
+		})
+	}
+}
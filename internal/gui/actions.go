@@ -6,7 +6,8 @@ package gui
 import (
 	"context"
 	"fmt"
-	"io"
+	"net/url"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -18,9 +19,13 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
 	"github.com/alexballas/go2tv/internal/devices"
-	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/imageconv"
+	"github.com/alexballas/go2tv/internal/mediaprobe"
+	"github.com/alexballas/go2tv/internal/mediatags"
 	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/transcoder"
 	"github.com/alexballas/go2tv/internal/urlstreamer"
 	"github.com/alexballas/go2tv/internal/utils"
 	"github.com/pkg/errors"
@@ -94,6 +99,7 @@ func mediaAction(screen *NewScreen) {
 
 		screen.MediaText.Text = filepath.Base(mfile)
 		screen.mediafile = absMediaFile
+		screen.remoteItem = nil
 
 		if !screen.CustomSubsCheck.Checked {
 			selectSubs(absMediaFile, screen)
@@ -179,10 +185,10 @@ func playAction(screen *NewScreen) {
 	// where we're able to click 'Play' while a media
 	// is looping repeatedly and throws an error that
 	// it's not supported by our media renderer.
-	// Without this check we'd end up spinning more
-	// webservers while keeping the old ones open.
-	if screen.httpserver != nil {
-		screen.httpserver.StopServeFiles()
+	// Without this check we'd keep serving the
+	// old items on our session server.
+	if screen.httpserver != nil && screen.tvdata != nil {
+		screen.httpserver.RemoveItem(screen.tvdata)
 	}
 
 	if screen.mediafile == "" && screen.MediaText.Text == "" {
@@ -197,14 +203,19 @@ func playAction(screen *NewScreen) {
 		return
 	}
 
-	whereToListen, err := utils.URLtoListenIPandPort(screen.controlURL)
+	srv, whereToAdvertise, err := sessionServer(screen)
 	check(w, err)
 	if err != nil {
 		screen.PlayPause.Enable()
 		return
 	}
 
-	var mediaType string
+	if screen.remoteItem != nil {
+		playRemoteItem(screen, srv, whereToAdvertise)
+		return
+	}
+
+	var mediaType, dlnaProfile string
 
 	if !screen.ExternalMediaURL.Checked {
 		mediaType, err = utils.GetMimeDetailsFromFile(screen.mediafile)
@@ -213,6 +224,8 @@ func playAction(screen *NewScreen) {
 			screen.PlayPause.Enable()
 			return
 		}
+
+		dlnaProfile = mediaprobe.DLNAProfile(screen.mediafile)
 	}
 
 	callbackPath, err := utils.RandomString()
@@ -229,19 +242,50 @@ func playAction(screen *NewScreen) {
 		// that defines that something is being streamed.
 		// We use its value for many checks in our code.
 		screen.mediafile = screen.MediaText.Text
+	}
 
-		// We're not using any context here. The reason is
-		// that when the webserver shuts down it causes the
-		// the io.Copy operation to fail with "broken pipe".
-		// That's good enough for us since right after that
-		// we close the io.ReadCloser.
-		mediaURL, err := urlstreamer.StreamURL(context.Background(), screen.MediaText.Text)
-		check(screen.Current, err)
+	mediaName := screen.mediafile
+
+	// Photos that the Media Renderer can't show as they
+	// are get converted on the first request.
+	if !screen.ExternalMediaURL.Checked && strings.HasPrefix(mediaType, "image") {
+		photo, err := newPhoto(screen, screen.mediafile, mediaType)
+		check(w, err)
+		if err != nil {
+			screen.PlayPause.Enable()
+			return
+		}
+
+		if photo != nil {
+			mediaFile = photo
+			mediaType = imageconv.MediaType
+			dlnaProfile = photo.DLNAProfile()
+			mediaName = imageconv.FileName(screen.mediafile)
+		}
+	}
+
+	// We don't read the tags of the media URLs.
+	var tags *mediatags.Tags
+	if !screen.ExternalMediaURL.Checked {
+		tags = mediatags.LoadMedia(screen.mediafile, mediaType)
+	}
+
+	// The transcoder reads the media file or URL on its own.
+	// There's no point in transcoding images.
+	if screen.Transcode && !strings.HasPrefix(mediaType, "image") {
+		tc, err := newTranscoder(screen, screen.mediafile)
+		check(w, err)
 		if err != nil {
 			screen.PlayPause.Enable()
 			return
 		}
 
+		mediaFile = tc
+		mediaType = tc.Profile.MediaType
+		dlnaProfile = ""
+	} else if screen.ExternalMediaURL.Checked {
+		// We're not using any context here. Removing the
+		// item from the webserver closes the URL stream for us.
 		mediaURLinfo, err := urlstreamer.StreamURL(context.Background(), screen.MediaText.Text)
 		check(screen.Current, err)
 		if err != nil {
@@ -256,43 +300,74 @@ func playAction(screen *NewScreen) {
 			return
 		}
 
-		mediaFile = mediaURL
-		if strings.Contains(mediaType, "image") {
-			readerToBytes, err := io.ReadAll(mediaURL)
-			mediaURL.Close()
-			if err != nil {
-				screen.PlayPause.Enable()
-				return
-			}
-			mediaFile = readerToBytes
+		// Origins with byte range support get proxied per request,
+		// the rest get buffered to disk. Either way the Media
+		// Renderer can re-request the media and seek.
+		mediaFile, err = urlstreamer.OpenURL(context.Background(), screen.MediaText.Text)
+		check(screen.Current, err)
+		if err != nil {
+			screen.PlayPause.Enable()
+			return
+		}
+
+		// HLS playlists get remuxed to MPEG-TS.
+		if b, ok := mediaFile.(*urlstreamer.BufferedStream); ok && b.ContentType == urlstreamer.HLSMediaType {
+			mediaType = urlstreamer.HLSMediaType
 		}
 	}
 
+	// Tokenized paths, so that nobody can guess them.
+	mediaPath, err := utils.TokenizedPath(mediaName)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
+	subsPath, err := utils.TokenizedPath(screen.subsfile)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
 	screen.tvdata = &soapcalls.TVPayload{
 		ControlURL:          screen.controlURL,
 		EventURL:            screen.eventlURL,
 		RenderingControlURL: screen.renderingControlURL,
-		MediaURL:            "http://" + whereToListen + "/" + utils.ConvertFilename(screen.mediafile),
-		SubtitlesURL:        "http://" + whereToListen + "/" + utils.ConvertFilename(screen.subsfile),
-		CallbackURL:         "http://" + whereToListen + "/" + callbackPath,
+		MediaURL:            utils.BuildHTTPURL(whereToAdvertise, mediaPath),
+		SubtitlesURL:        utils.BuildHTTPURL(whereToAdvertise, subsPath),
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
 		MediaType:           mediaType,
+		DLNAProfile:         dlnaProfile,
 		CurrentTimers:       make(map[string]*time.Timer),
 	}
 
-	screen.httpserver = httphandlers.NewServer(whereToListen)
-	serverStarted := make(chan struct{})
+	artPath, artwork, err := tags.SetMetadata(screen.tvdata, whereToAdvertise)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
 
 	// We pass the tvdata here as we need the callback handlers to be able to react
 	// to the different media renderer states.
-	go func() {
-		err := screen.httpserver.ServeFiles(serverStarted, mediaFile, screen.subsfile, screen.tvdata, screen)
+	err = srv.AddItem(screen.tvdata, mediaFile, screen.subsfile, screen)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
+	if artwork != nil {
+		err = srv.AddArtwork(screen.tvdata, artPath, artwork)
 		check(w, err)
 		if err != nil {
+			screen.PlayPause.Enable()
 			return
 		}
-	}()
-	// Wait for the HTTP server to properly initialize.
-	<-serverStarted
+	}
+
 	err = screen.tvdata.SendtoTV("Play1")
 	check(w, err)
 	if err != nil {
@@ -317,6 +392,7 @@ func pauseAction(screen *NewScreen) {
 func clearmediaAction(screen *NewScreen) {
 	screen.MediaText.Text = ""
 	screen.mediafile = ""
+	screen.remoteItem = nil
 	screen.MediaText.Refresh()
 }
 
@@ -334,8 +410,14 @@ func previewmedia(screen *NewScreen) {
 		return
 	}
 
-	mediaType, err := utils.GetMimeDetailsFromFile(screen.mediafile)
-	check(w, err)
+	if screen.remoteItem != nil {
+		err := open.Run(screen.mediafile)
+		check(w, err)
+		return
+	}
+
+	// We let the OS open the media we don't know about.
+	mediaType, _ := utils.GetMimeDetailsFromFile(screen.mediafile)
 
 	mediaTypeSlice := strings.Split(mediaType, "/")
 	switch mediaTypeSlice[0] {
@@ -358,11 +440,18 @@ func stopAction(screen *NewScreen) {
 
 	screen.PlayPause.Enable()
 
-	if screen.tvdata == nil || screen.tvdata.ControlURL == "" {
+	// A slideshow may start, or stop, on its own goroutine.
+	screen.mu.RLock()
+	tvdata, srv := screen.tvdata, screen.httpserver
+	screen.mu.RUnlock()
+
+	if tvdata == nil || tvdata.ControlURL == "" {
 		return
 	}
 
-	err := screen.tvdata.SendtoTV("Stop")
+	stopSlideshow(screen)
+
+	err := tvdata.SendtoTV("Stop")
 
 	// Hack to avoid potential http errors during media loop mode.
 	// Will keep the window clean during unattended usage.
@@ -371,16 +460,23 @@ func stopAction(screen *NewScreen) {
 	}
 	check(w, err)
 
-	screen.httpserver.StopServeFiles()
-	screen.tvdata = nil
+	// The session server keeps running for the next item.
+	srv.RemoveItem(tvdata)
+
+	screen.mu.Lock()
+	if screen.tvdata == tvdata {
+		screen.tvdata = nil
+	}
+	screen.mu.Unlock()
+
 	// In theory we should expect an emit message
 	// from the media renderer, but there seems
 	// to be a race condition that prevents this.
 	screen.EmitMsg("Stopped")
 }
 
-func getDevices(delay int) ([]devType, error) {
-	deviceList, err := devices.LoadSSDPservices(delay)
+func getDevices(delay int, ifaces ...string) ([]devType, error) {
+	deviceList, err := devices.LoadSSDPservices(delay, ifaces...)
 	if err != nil {
 		return nil, fmt.Errorf("getDevices error: %w", err)
 	}
@@ -395,12 +491,184 @@ func getDevices(delay int) ([]devType, error) {
 
 	guiDeviceList := make([]devType, 0)
 	for _, k := range keys {
-		guiDeviceList = append(guiDeviceList, devType{k, deviceList[k]})
+		guiDeviceList = append(guiDeviceList, devType{k, deviceList[k], devices.DeviceInterface(deviceList[k])})
 	}
 
 	return guiDeviceList, nil
 }
 
+func addDeviceAction(screen *NewScreen, data *[]devType) {
+	w := screen.Current
+
+	addr := widget.NewEntry()
+	addr.SetPlaceHolder("192.168.1.10 or http://192.168.1.10:9197/dmr")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Address", addr),
+	}
+
+	dialog.ShowForm("Add device by address", "Add", "Cancel", items, func(b bool) {
+		if !b || addr.Text == "" {
+			return
+		}
+
+		go func() {
+			dev, err := devices.AddStaticDevice(addr.Text)
+			check(w, err)
+			if err != nil {
+				return
+			}
+
+			for _, d := range *data {
+				if d.addr == dev.URL {
+					return
+				}
+			}
+
+			*data = append(*data, devType{dev.Name, dev.URL, devices.DeviceInterface(dev.URL)})
+			sort.Slice(*data, func(i, j int) bool {
+				return (*data)[i].name < (*data)[j].name
+			})
+
+			screen.DeviceList.Refresh()
+		}()
+	}, w)
+}
+
+// newTranscoder picks the transcoding
+// profile of the selected Media Renderer.
+func newTranscoder(screen *NewScreen, input string) (*transcoder.Transcoder, error) {
+	cfg, err := transcoder.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("newTranscoder error: %w", err)
+	}
+
+	var host string
+	if u, err := url.Parse(screen.selectedDevice.addr); err == nil {
+		host = u.Hostname()
+	}
+
+	p, err := cfg.ProfileFor(screen.selectedDevice.name, screen.selectedDevice.addr, host)
+	if err != nil {
+		return nil, fmt.Errorf("newTranscoder error: %w", err)
+	}
+
+	tc, err := cfg.New(input, p)
+	if err != nil {
+		return nil, fmt.Errorf("newTranscoder error: %w", err)
+	}
+
+	return tc, nil
+}
+
+// newPhoto converts the photos that the selected Media Renderer
+// can't show as they are. The rest of the media get a nil Photo.
+func newPhoto(screen *NewScreen, input, mediaType string) (*imageconv.Photo, error) {
+	p, ffmpeg, err := photoProfile(screen)
+	if err != nil {
+		return nil, fmt.Errorf("newPhoto error: %w", err)
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("newPhoto open error: %w", err)
+	}
+	defer f.Close()
+
+	if !imageconv.NeedsConversion(f, mediaType, p) {
+		return nil, nil
+	}
+
+	return imageconv.NewFile(input, p, ffmpeg), nil
+}
+
+// photoProfile picks the image profile of the selected
+// Media Renderer, along with the ffmpeg binary to use.
+func photoProfile(screen *NewScreen) (imageconv.Profile, string, error) {
+	cfg, err := transcoder.LoadConfig()
+	if err != nil {
+		return imageconv.Profile{}, "", fmt.Errorf("photoProfile error: %w", err)
+	}
+
+	var host string
+	if u, err := url.Parse(screen.selectedDevice.addr); err == nil {
+		host = u.Hostname()
+	}
+
+	p, err := imageconv.ProfileByName(cfg.ImageProfileFor(screen.selectedDevice.name, screen.selectedDevice.addr, host))
+	if err != nil {
+		return imageconv.Profile{}, "", fmt.Errorf("photoProfile error: %w", err)
+	}
+
+	return p, cfg.FFmpeg, nil
+}
+
+func wakeDeviceAction(screen *NewScreen, data *[]devType) {
+	w := screen.Current
+
+	list, err := devices.LoadWakeDevices()
+	check(w, err)
+	if err != nil {
+		return
+	}
+
+	if len(list) == 0 {
+		check(w, errors.New("no known devices to wake up, a device needs to be discovered once while awake"))
+		return
+	}
+
+	names := make([]string, 0)
+	for _, d := range list {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+
+	sel := widget.NewSelect(names, func(string) {})
+	sel.SetSelectedIndex(0)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Device", sel),
+	}
+
+	dialog.ShowForm("Wake device", "Wake", "Cancel", items, func(b bool) {
+		if !b || sel.Selected == "" {
+			return
+		}
+
+		var dev devices.WakeDevice
+		for _, d := range list {
+			if d.Name == sel.Selected {
+				dev = d
+			}
+		}
+
+		progress := dialog.NewProgressInfinite("Wake device", "Waking up "+dev.Name+"...", w)
+		progress.Show()
+
+		go func() {
+			err := devices.Wake(dev, 60*time.Second)
+			progress.Hide()
+			check(w, err)
+			if err != nil {
+				return
+			}
+
+			for _, d := range *data {
+				if d.addr == dev.URL {
+					return
+				}
+			}
+
+			*data = append(*data, devType{dev.Name, dev.URL, devices.DeviceInterface(dev.URL)})
+			sort.Slice(*data, func(i, j int) bool {
+				return (*data)[i].name < (*data)[j].name
+			})
+
+			screen.DeviceList.Refresh()
+		}()
+	}, w)
+}
+
 func volumeAction(screen *NewScreen, up bool) {
 	w := screen.Current
 	if screen.renderingControlURL == "" {
@@ -4,6 +4,7 @@
 package gui
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -16,8 +17,9 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
 	"github.com/alexballas/go2tv/internal/devices"
-	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/imageconv"
 	"github.com/alexballas/go2tv/internal/soapcalls"
 	"github.com/alexballas/go2tv/internal/urlstreamer"
 	"github.com/alexballas/go2tv/internal/utils"
@@ -146,10 +148,10 @@ func playAction(screen *NewScreen) {
 	// where we're able to click 'Play' while a media
 	// is looping repeatedly and throws an error that
 	// it's not supported by our media renderer.
-	// Without this check we'd end up spinning more
-	// webservers while keeping the old ones open.
-	if screen.httpserver != nil {
-		screen.httpserver.StopServeFiles()
+	// Without this check we'd keep serving the
+	// old items on our session server.
+	if screen.httpserver != nil && screen.tvdata != nil {
+		screen.httpserver.RemoveItem(screen.tvdata)
 	}
 
 	if screen.mediafile == nil && screen.MediaText.Text == "" {
@@ -164,14 +166,15 @@ func playAction(screen *NewScreen) {
 		return
 	}
 
-	whereToListen, err := utils.URLtoListenIPandPort(screen.controlURL)
+	srv, whereToAdvertise, err := sessionServer(screen)
 	check(w, err)
 	if err != nil {
 		screen.PlayPause.Enable()
 		return
 	}
 
-	var mediaType string
+	var mediaType, dlnaProfile string
+	mediaName := screen.MediaText.Text
 
 	callbackPath, err := utils.RandomString()
 	if err != nil {
@@ -210,6 +213,17 @@ func playAction(screen *NewScreen) {
 				return
 			}
 			mediaFile = readerToBytes
+
+			// Photos that the Media Renderer can't show as they
+			// are get converted on the first request.
+			p, _ := imageconv.ProfileByName("")
+			if imageconv.NeedsConversion(bytes.NewReader(readerToBytes), mediaType, p) {
+				photo := imageconv.NewBytes(readerToBytes, p)
+				mediaFile = photo
+				mediaType = imageconv.MediaType
+				dlnaProfile = photo.DLNAProfile()
+				mediaName = imageconv.FileName(screen.MediaText.Text)
+			}
 		}
 	}
 
@@ -223,69 +237,74 @@ func playAction(screen *NewScreen) {
 	}
 
 	if screen.ExternalMediaURL.Checked {
-		// We're not using any context here. The reason is
-		// that when the webserver shuts down it causes the
-		// the io.Copy operation to fail with "broken pipe".
-		// That's good enough for us since right after that
-		// we close the io.ReadCloser.
-		mediaURL, err := urlstreamer.StreamURL(context.Background(), screen.MediaText.Text)
+		// We're not using any context here. Removing the
+		// item from the webserver closes the URL stream for us.
+		mediaURLinfo, err := urlstreamer.StreamURL(context.Background(), screen.MediaText.Text)
 		check(screen.Current, err)
 		if err != nil {
 			screen.PlayPause.Enable()
 			return
 		}
 
-		mediaURLinfo, err := urlstreamer.StreamURL(context.Background(), screen.MediaText.Text)
-		check(screen.Current, err)
+		mediaType, err = utils.GetMimeDetailsFromStream(mediaURLinfo)
+		check(w, err)
 		if err != nil {
 			screen.PlayPause.Enable()
 			return
 		}
 
-		mediaType, err = utils.GetMimeDetailsFromStream(mediaURLinfo)
-		check(w, err)
+		// Origins with byte range support get proxied per request,
+		// the rest get buffered to disk. Either way the Media
+		// Renderer can re-request the media and seek.
+		mediaFile, err = urlstreamer.OpenURL(context.Background(), screen.MediaText.Text)
+		check(screen.Current, err)
 		if err != nil {
 			screen.PlayPause.Enable()
 			return
 		}
 
-		mediaFile = mediaURL
-		if strings.Contains(mediaType, "image") {
-			readerToBytes, err := io.ReadAll(mediaURL)
-			mediaURL.Close()
-			if err != nil {
-				screen.PlayPause.Enable()
-				return
-			}
-			mediaFile = readerToBytes
+		// HLS playlists get remuxed to MPEG-TS.
+		if b, ok := mediaFile.(*urlstreamer.BufferedStream); ok && b.ContentType == urlstreamer.HLSMediaType {
+			mediaType = urlstreamer.HLSMediaType
 		}
 	}
 
+	// Tokenized paths, so that nobody can guess them.
+	mediaPath, err := utils.TokenizedPath(mediaName)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
+	subsPath, err := utils.TokenizedPath(screen.SubsText.Text)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
 	screen.tvdata = &soapcalls.TVPayload{
 		ControlURL:          screen.controlURL,
 		EventURL:            screen.eventlURL,
 		RenderingControlURL: screen.renderingControlURL,
-		MediaURL:            "http://" + whereToListen + "/" + utils.ConvertFilename(screen.MediaText.Text),
-		SubtitlesURL:        "http://" + whereToListen + "/" + utils.ConvertFilename(screen.SubsText.Text),
-		CallbackURL:         "http://" + whereToListen + "/" + callbackPath,
+		MediaURL:            utils.BuildHTTPURL(whereToAdvertise, mediaPath),
+		SubtitlesURL:        utils.BuildHTTPURL(whereToAdvertise, subsPath),
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
 		MediaType:           mediaType,
+		DLNAProfile:         dlnaProfile,
 		CurrentTimers:       make(map[string]*time.Timer),
 	}
 
-	screen.httpserver = httphandlers.NewServer(whereToListen)
-	serverStarted := make(chan struct{})
-
 	// We pass the tvdata here as we need the callback handlers to be able to react
 	// to the different media renderer states.
-	go func() {
-		err := screen.httpserver.ServeFiles(serverStarted, mediaFile, subsFile, screen.tvdata, screen)
-		check(w, err)
-		if err != nil {
-			return
-		}
-	}()
-	// Wait for the HTTP server to properly initialize.
-	<-serverStarted
+	err = srv.AddItem(screen.tvdata, mediaFile, subsFile, screen)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
 	err = screen.tvdata.SendtoTV("Play1")
 	check(w, err)
 	if err != nil {
@@ -337,7 +356,8 @@ func stopAction(screen *NewScreen) {
 	}
 	check(w, err)
 
-	screen.httpserver.StopServeFiles()
+	// The session server keeps running for the next item.
+	screen.httpserver.RemoveItem(screen.tvdata)
 	screen.tvdata = nil
 	// In theory we should expect an emit message
 	// from the media renderer, but there seems
@@ -345,8 +365,8 @@ func stopAction(screen *NewScreen) {
 	screen.EmitMsg("Stopped")
 }
 
-func getDevices(delay int) ([]devType, error) {
-	deviceList, err := devices.LoadSSDPservices(delay)
+func getDevices(delay int, ifaces ...string) ([]devType, error) {
+	deviceList, err := devices.LoadSSDPservices(delay, ifaces...)
 	if err != nil {
 		return nil, fmt.Errorf("getDevices error: %w", err)
 	}
@@ -361,12 +381,116 @@ func getDevices(delay int) ([]devType, error) {
 
 	guiDeviceList := make([]devType, 0)
 	for _, k := range keys {
-		guiDeviceList = append(guiDeviceList, devType{k, deviceList[k]})
+		guiDeviceList = append(guiDeviceList, devType{k, deviceList[k], devices.DeviceInterface(deviceList[k])})
 	}
 
 	return guiDeviceList, nil
 }
 
+func addDeviceAction(screen *NewScreen, data *[]devType) {
+	w := screen.Current
+
+	addr := widget.NewEntry()
+	addr.SetPlaceHolder("192.168.1.10 or http://192.168.1.10:9197/dmr")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Address", addr),
+	}
+
+	dialog.ShowForm("Add device by address", "Add", "Cancel", items, func(b bool) {
+		if !b || addr.Text == "" {
+			return
+		}
+
+		go func() {
+			dev, err := devices.AddStaticDevice(addr.Text)
+			check(w, err)
+			if err != nil {
+				return
+			}
+
+			for _, d := range *data {
+				if d.addr == dev.URL {
+					return
+				}
+			}
+
+			*data = append(*data, devType{dev.Name, dev.URL, devices.DeviceInterface(dev.URL)})
+			sort.Slice(*data, func(i, j int) bool {
+				return (*data)[i].name < (*data)[j].name
+			})
+
+			screen.DeviceList.Refresh()
+		}()
+	}, w)
+}
+
+func wakeDeviceAction(screen *NewScreen, data *[]devType) {
+	w := screen.Current
+
+	list, err := devices.LoadWakeDevices()
+	check(w, err)
+	if err != nil {
+		return
+	}
+
+	if len(list) == 0 {
+		check(w, errors.New("no known devices to wake up, a device needs to be discovered once while awake"))
+		return
+	}
+
+	names := make([]string, 0)
+	for _, d := range list {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+
+	sel := widget.NewSelect(names, func(string) {})
+	sel.SetSelectedIndex(0)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Device", sel),
+	}
+
+	dialog.ShowForm("Wake device", "Wake", "Cancel", items, func(b bool) {
+		if !b || sel.Selected == "" {
+			return
+		}
+
+		var dev devices.WakeDevice
+		for _, d := range list {
+			if d.Name == sel.Selected {
+				dev = d
+			}
+		}
+
+		progress := dialog.NewProgressInfinite("Wake device", "Waking up "+dev.Name+"...", w)
+		progress.Show()
+
+		go func() {
+			err := devices.Wake(dev, 60*time.Second)
+			progress.Hide()
+			check(w, err)
+			if err != nil {
+				return
+			}
+
+			for _, d := range *data {
+				if d.addr == dev.URL {
+					return
+				}
+			}
+
+			*data = append(*data, devType{dev.Name, dev.URL, devices.DeviceInterface(dev.URL)})
+			sort.Slice(*data, func(i, j int) bool {
+				return (*data)[i].name < (*data)[j].name
+			})
+
+			screen.DeviceList.Refresh()
+		}()
+	}, w)
+}
+
 func volumeAction(screen *NewScreen, up bool) {
 	w := screen.Current
 	if screen.renderingControlURL == "" {
@@ -0,0 +1,276 @@
+//go:build !(android || ios)
+// +build !android,!ios
+
+package gui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/alexballas/go2tv/internal/devices"
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// browseEntry is a row in the Media Server browser. It's
+// either a Media Server, a container, an item, or the
+// entry that takes us one level up.
+type browseEntry struct {
+	title  string
+	server string
+	object soapcalls.CDSObject
+	up     bool
+}
+
+type mediaServerBrowser struct {
+	screen     *NewScreen
+	window     fyne.Window
+	list       *widget.List
+	status     *widget.Label
+	entries    []browseEntry
+	controlURL string
+	path       []string
+}
+
+func browseAction(screen *NewScreen) {
+	b := &mediaServerBrowser{
+		screen: screen,
+		window: fyne.CurrentApp().NewWindow("Browse Media Server"),
+		status: widget.NewLabel("Searching for Media Servers..."),
+	}
+
+	b.list = widget.NewList(
+		func() int {
+			return len(b.entries)
+		},
+		func() fyne.CanvasObject {
+			return container.NewHBox(widget.NewIcon(theme.FolderIcon()), widget.NewLabel("Template Object"))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			e := b.entries[i]
+			icon := theme.FileIcon()
+			switch {
+			case e.up:
+				icon = theme.NavigateBackIcon()
+			case e.server != "":
+				icon = theme.StorageIcon()
+			case e.object.IsContainer:
+				icon = theme.FolderIcon()
+			}
+
+			o.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(icon)
+			o.(*fyne.Container).Objects[1].(*widget.Label).SetText(e.title)
+		})
+
+	b.list.OnSelected = func(id widget.ListItemID) {
+		b.list.Unselect(id)
+		e := b.entries[id]
+		go b.open(e)
+	}
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Search titles")
+	search.OnSubmitted = func(q string) {
+		go b.search(q)
+	}
+
+	searchButton := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
+		go b.search(search.Text)
+	})
+
+	top := container.NewVBox(container.NewBorder(nil, nil, nil, searchButton, search), b.status)
+	b.window.SetContent(container.NewBorder(top, nil, nil, nil, b.list))
+	b.window.Resize(fyne.NewSize(500, 500))
+	b.window.CenterOnScreen()
+	b.window.Show()
+
+	go b.loadServers()
+}
+
+func (b *mediaServerBrowser) loadServers() {
+	serverList, err := devices.LoadMediaServers(1, b.screen.getInterfaces()...)
+	if err != nil {
+		b.setStatus("No Media Servers found")
+		return
+	}
+
+	keys := make([]string, 0, len(serverList))
+	for k := range serverList {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	entries := make([]browseEntry, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, browseEntry{title: k, server: serverList[k]})
+	}
+
+	b.controlURL = ""
+	b.path = nil
+	b.setEntries(entries, "Select a Media Server")
+}
+
+func (b *mediaServerBrowser) open(e browseEntry) {
+	switch {
+	case e.up:
+		if len(b.path) <= 1 {
+			b.loadServers()
+			return
+		}
+
+		b.path = b.path[:len(b.path)-1]
+		b.browse()
+	case e.server != "":
+		cds, err := soapcalls.CDSextractor(e.server)
+		check(b.window, err)
+		if err != nil {
+			return
+		}
+
+		b.controlURL = cds.ContentDirectoryControlURL
+		// The root container of every Media Server has the "0" ID.
+		b.path = []string{"0"}
+		b.browse()
+	case e.object.IsContainer:
+		b.path = append(b.path, e.object.ID)
+		b.browse()
+	default:
+		b.selectItem(e.object)
+	}
+}
+
+func (b *mediaServerBrowser) browse() {
+	b.setStatus("Loading...")
+
+	objects, err := soapcalls.BrowseSoapCall(b.controlURL, b.path[len(b.path)-1])
+	check(b.window, err)
+	if err != nil {
+		b.setStatus("")
+		return
+	}
+
+	b.setObjects(objects, "")
+}
+
+func (b *mediaServerBrowser) search(q string) {
+	if b.controlURL == "" {
+		check(b.window, errors.New("please select a Media Server"))
+		return
+	}
+
+	if strings.TrimSpace(q) == "" {
+		b.browse()
+		return
+	}
+
+	b.setStatus("Searching...")
+
+	objects, err := soapcalls.SearchSoapCall(b.controlURL, "0", soapcalls.TitleSearchCriteria(q))
+	check(b.window, err)
+	if err != nil {
+		b.setStatus("")
+		return
+	}
+
+	b.setObjects(objects, "Search results")
+}
+
+func (b *mediaServerBrowser) setObjects(objects []soapcalls.CDSObject, status string) {
+	entries := []browseEntry{{title: "..", up: true}}
+	for _, o := range objects {
+		entries = append(entries, browseEntry{title: o.Title, object: o})
+	}
+
+	b.setEntries(entries, status)
+}
+
+func (b *mediaServerBrowser) setEntries(entries []browseEntry, status string) {
+	b.entries = entries
+	b.list.Refresh()
+	b.list.ScrollToTop()
+	b.setStatus(status)
+}
+
+func (b *mediaServerBrowser) setStatus(s string) {
+	b.status.SetText(s)
+}
+
+// selectItem sets the Media Server item as the media
+// to cast. The Media Renderer fetches it directly
+// from the Media Server.
+func (b *mediaServerBrowser) selectItem(o soapcalls.CDSObject) {
+	res, err := o.PlayableRes()
+	check(b.window, err)
+	if err != nil {
+		return
+	}
+
+	screen := b.screen
+	if screen.ExternalMediaURL.Checked {
+		screen.ExternalMediaURL.SetChecked(false)
+	}
+
+	item := o
+	screen.remoteItem = &item
+	screen.mediafile = res.URL
+	screen.MediaText.Text = o.Title
+	screen.MediaText.Refresh()
+
+	clearsubsAction(screen)
+
+	b.window.Close()
+}
+
+// playRemoteItem casts the selected Media Server item. We pass
+// the item URL and its DIDL-Lite metadata straight to the Media
+// Renderer and only serve the event callbacks ourselves.
+func playRemoteItem(screen *NewScreen, srv *httphandlers.HTTPserver, whereToAdvertise string) {
+	w := screen.Current
+
+	res, err := screen.remoteItem.PlayableRes()
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
+	callbackPath, err := utils.RandomString()
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
+	screen.tvdata = &soapcalls.TVPayload{
+		ControlURL:          screen.controlURL,
+		EventURL:            screen.eventlURL,
+		RenderingControlURL: screen.renderingControlURL,
+		MediaURL:            res.URL,
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
+		MediaType:           res.MediaType(),
+		MediaMetadata:       screen.remoteItem.Metadata,
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+
+	err = srv.AddItem(screen.tvdata, nil, nil, screen)
+	check(w, err)
+	if err != nil {
+		screen.PlayPause.Enable()
+		return
+	}
+
+	err = screen.tvdata.SendtoTV("Play1")
+	check(w, err)
+	if err != nil {
+		screen.controlURL = ""
+		screen.DeviceList.UnselectAll()
+		stopAction(screen)
+	}
+}
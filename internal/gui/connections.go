@@ -0,0 +1,176 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+const (
+	// The number of recent requests that we show.
+	connectionsLogSize = 200
+	// The SOAP dumps that we keep for the bug reports.
+	soapLogSize = 1 << 20
+)
+
+// connectionsWindow shows the requests of the Media Renderers,
+// live, so that we can tell why a Media Renderer refuses a media.
+// The returned refresher only runs while the tab is shown.
+func connectionsWindow(s *NewScreen) (fyne.CanvasObject, *refresher) {
+	var mu sync.Mutex
+	var entries []string
+
+	summary := widget.NewLabel("No requests yet")
+
+	list := widget.NewList(
+		func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(entries)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Wrapping = fyne.TextTruncate
+			return l
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			mu.Lock()
+			defer mu.Unlock()
+			if i < len(entries) {
+				o.(*widget.Label).SetText(entries[i])
+			}
+		})
+
+	soapCheck := widget.NewCheck("Record SOAP messages", func(b bool) {
+		switch b {
+		case true:
+			soapcalls.DumpSOAP(s.soapLog)
+		default:
+			soapcalls.DumpSOAP(nil)
+		}
+	})
+
+	copyButton := widget.NewButton("Copy Log", func() {
+		s.Current.Clipboard().SetContent(debugLog(s))
+	})
+
+	live := newRefresher(time.Second, func() {
+		reqs := s.requests.Requests()
+		lines := make([]string, len(reqs))
+		for i, r := range reqs {
+			lines[i] = r.String()
+		}
+
+		mu.Lock()
+		entries = lines
+		mu.Unlock()
+
+		if n, b := s.requests.Totals(); n > 0 {
+			summary.SetText(fmt.Sprintf("%d requests served, %.1f MB", n, float64(b)/1e6))
+		}
+		list.Refresh()
+	})
+
+	top := container.NewVBox(summary, container.NewHBox(soapCheck, copyButton))
+
+	return container.NewBorder(top, nil, nil, nil, list), live
+}
+
+// refresher calls update on every tick, between start and stop.
+type refresher struct {
+	mu       sync.Mutex
+	interval time.Duration
+	update   func()
+	ticker   *time.Ticker
+	done     chan struct{}
+}
+
+func newRefresher(interval time.Duration, update func()) *refresher {
+	return &refresher{interval: interval, update: update}
+}
+
+// start is a no-op when the refresher already runs.
+func (r *refresher) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ticker != nil {
+		return
+	}
+
+	r.ticker = time.NewTicker(r.interval)
+	r.done = make(chan struct{})
+
+	go func(tick <-chan time.Time, done <-chan struct{}) {
+		// We don't wait a whole tick for the first update.
+		r.update()
+		for {
+			select {
+			case <-tick:
+				r.update()
+			case <-done:
+				return
+			}
+		}
+	}(r.ticker.C, r.done)
+}
+
+func (r *refresher) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ticker == nil {
+		return
+	}
+
+	r.ticker.Stop()
+	close(r.done)
+	r.ticker = nil
+}
+
+// debugLog - The recent requests, oldest first,
+// followed by the recorded SOAP messages.
+func debugLog(s *NewScreen) string {
+	var b strings.Builder
+
+	reqs := s.requests.Requests()
+	for i := len(reqs) - 1; i >= 0; i-- {
+		b.WriteString(reqs[i].String() + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(s.soapLog.String())
+
+	return b.String()
+}
+
+// boundedLog keeps the last soapLogSize bytes that we write to it.
+type boundedLog struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (l *boundedLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	if len(l.buf) > soapLogSize {
+		l.buf = append([]byte(nil), l.buf[len(l.buf)-soapLogSize:]...)
+	}
+
+	return len(p), nil
+}
+
+func (l *boundedLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return string(l.buf)
+}
@@ -16,7 +16,9 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/slideshow"
 	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
 	"github.com/pkg/errors"
 )
 
@@ -25,6 +27,10 @@ type NewScreen struct {
 	mu                  sync.RWMutex
 	Current             fyne.Window
 	tvdata              *soapcalls.TVPayload
+	remoteItem          *soapcalls.CDSObject
+	slideshow           *slideshow.Slideshow
+	slideCaster         *slideshow.Caster
+	slideMusic          *soapcalls.TVPayload
 	Stop                *widget.Button
 	MuteUnmute          *widget.Button
 	CheckVersion        *widget.Button
@@ -34,6 +40,10 @@ type NewScreen struct {
 	SubsText            *widget.Entry
 	DeviceList          *widget.List
 	httpserver          *httphandlers.HTTPserver
+	requests            *httphandlers.RequestStats
+	soapLog             *boundedLog
+	serverKey           string
+	serverAdvertise     string
 	PlayPause           *widget.Button
 	mediafile           string
 	subsfile            string
@@ -45,13 +55,16 @@ type NewScreen struct {
 	currentmfolder      string
 	version             string
 	mediaFormats        []string
+	listenConfig        utils.ListenConfig
 	NextMedia           bool
 	Medialoop           bool
+	Transcode           bool
 }
 
 type devType struct {
-	name string
-	addr string
+	name  string
+	addr  string
+	iface string
 }
 
 type mainButtonsLayout struct{}
@@ -60,16 +73,36 @@ type mainButtonsLayout struct{}
 func Start(s *NewScreen) {
 	w := s.Current
 
+	connections, live := connectionsWindow(s)
+	connectionsTab := container.NewTabItem("Connections", connections)
+
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Go2TV", container.NewPadded(mainWindow(s))),
+		container.NewTabItem("Settings", settingsWindow(s)),
+		connectionsTab,
 		container.NewTabItem("About", aboutWindow(s)),
 	)
 
+	// We only refresh the requests while we show them.
+	tabs.OnSelected = func(t *container.TabItem) {
+		if t == connectionsTab {
+			live.start()
+		}
+	}
+	tabs.OnUnselected = func(t *container.TabItem) {
+		if t == connectionsTab {
+			live.stop()
+		}
+	}
+
 	w.SetContent(tabs)
 	w.Resize(fyne.NewSize(w.Canvas().Size().Width*1.2, w.Canvas().Size().Height*1.3))
 	w.CenterOnScreen()
 	w.SetMaster()
 	w.ShowAndRun()
+	live.stop()
+	stopSlideshow(s)
+	endSession(s)
 	os.Exit(0)
 }
 
@@ -95,7 +128,8 @@ func (p *NewScreen) EmitMsg(a string) {
 // Will only be executed when we receive a callback message,
 // not when we explicitly click the Stop button.
 func (p *NewScreen) Fini() {
-	if p.NextMedia {
+	// We can't pick the next item of a Media Server folder.
+	if p.NextMedia && p.remoteItem == nil {
 		selectNextMedia(p)
 	}
 	// Main media loop logic
@@ -106,19 +140,25 @@ func (p *NewScreen) Fini() {
 
 //InitFyneNewScreen .
 func InitFyneNewScreen(v string) *NewScreen {
-	go2tv := app.New()
+	go2tv := app.NewWithID("com.alexballas.go2tv")
 	w := go2tv.NewWindow("Go2TV")
 	currentdir, err := os.Getwd()
 	if err != nil {
 		currentdir = ""
 	}
 
-	return &NewScreen{
+	s := &NewScreen{
 		Current:        w,
 		currentmfolder: currentdir,
-		mediaFormats:   []string{".mp4", ".avi", ".mkv", ".mpeg", ".mov", ".webm", ".m4v", ".mpv", ".mp3", ".flac", ".wav", ".jpg", ".jpeg", ".png"},
+		mediaFormats:   []string{".mp4", ".avi", ".mkv", ".mpeg", ".mov", ".webm", ".m4v", ".mpv", ".ts", ".m2ts", ".mts", ".mp3", ".flac", ".wav", ".m4a", ".aac", ".ogg", ".oga", ".opus", ".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".heif", ".bmp", ".tif", ".tiff"},
 		version:        v,
+		requests:       httphandlers.NewRequestStats(connectionsLogSize, nil),
+		soapLog:        &boundedLog{},
 	}
+
+	loadListenPreferences(s)
+
+	return s
 }
 
 func check(win fyne.Window, err error) {
@@ -16,6 +16,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/alexballas/go2tv/internal/httphandlers"
 	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
 	"github.com/pkg/errors"
 )
 
@@ -33,6 +34,10 @@ type NewScreen struct {
 	SubsText            *widget.Entry
 	DeviceList          *widget.List
 	httpserver          *httphandlers.HTTPserver
+	requests            *httphandlers.RequestStats
+	soapLog             *boundedLog
+	serverKey           string
+	serverAdvertise     string
 	PlayPause           *widget.Button
 	mediafile           fyne.URI
 	subsfile            fyne.URI
@@ -43,12 +48,14 @@ type NewScreen struct {
 	renderingControlURL string
 	version             string
 	mediaFormats        []string
+	listenConfig        utils.ListenConfig
 	Medialoop           bool
 }
 
 type devType struct {
-	name string
-	addr string
+	name  string
+	addr  string
+	iface string
 }
 
 type mainButtonsLayout struct{}
@@ -57,13 +64,31 @@ type mainButtonsLayout struct{}
 func Start(s *NewScreen) {
 	w := s.Current
 
+	connections, live := connectionsWindow(s)
+	connectionsTab := container.NewTabItem("Connections", connections)
+
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Go2TV", container.NewVScroll(container.NewPadded(mainWindow(s)))),
+		connectionsTab,
 		container.NewTabItem("About", container.NewVScroll(aboutWindow(s))),
 	)
+
+	// We only refresh the requests while we show them.
+	tabs.OnSelected = func(t *container.TabItem) {
+		if t == connectionsTab {
+			live.start()
+		}
+	}
+	tabs.OnUnselected = func(t *container.TabItem) {
+		if t == connectionsTab {
+			live.stop()
+		}
+	}
 	w.SetContent(tabs)
 	w.CenterOnScreen()
 	w.ShowAndRun()
+	live.stop()
+	endSession(s)
 	os.Exit(0)
 }
 
@@ -97,16 +122,22 @@ func (p *NewScreen) Fini() {
 
 //InitFyneNewScreen .
 func InitFyneNewScreen(v string) *NewScreen {
-	go2tv := app.New()
+	go2tv := app.NewWithID("com.alexballas.go2tv")
 	go2tv.Settings().SetTheme(theme.DarkTheme())
 
 	w := go2tv.NewWindow("Go2TV")
 
-	return &NewScreen{
+	s := &NewScreen{
 		Current:      w,
-		mediaFormats: []string{".mp4", ".avi", ".mkv", ".mpeg", ".mov", ".webm", ".m4v", ".mpv", ".mp3", ".flac", ".wav"},
+		mediaFormats: []string{".mp4", ".avi", ".mkv", ".mpeg", ".mov", ".webm", ".m4v", ".mpv", ".ts", ".m2ts", ".mts", ".mp3", ".flac", ".wav", ".m4a", ".aac", ".ogg", ".oga", ".opus"},
 		version:      v,
+		requests:     httphandlers.NewRequestStats(connectionsLogSize, nil),
+		soapLog:      &boundedLog{},
 	}
+
+	loadListenPreferences(s)
+
+	return s
 }
 
 func check(win fyne.Window, err error) {
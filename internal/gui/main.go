@@ -45,7 +45,7 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 	})
 
 	go func() {
-		datanew, err := getDevices(1)
+		datanew, err := getDevices(1, s.getInterfaces()...)
 		data = datanew
 		if err != nil {
 			data = nil
@@ -62,6 +62,14 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 
 	mfiletext.Disable()
 
+	browsemedia := widget.NewButton("Browse Media Server", func() {
+		browseAction(s)
+	})
+
+	slides := widget.NewButton("Slideshow", func() {
+		slideshowAction(s)
+	})
+
 	sfile := widget.NewButton("Select Subtitles File", func() {
 		go subsAction(s)
 	})
@@ -114,11 +122,20 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 	externalmedia := widget.NewCheck("Media from URL", func(b bool) {})
 	medialoop := widget.NewCheck("Loop Selected", func(b bool) {})
 	nextmedia := widget.NewCheck("Auto-Select Next File", func(b bool) {})
+	transcode := widget.NewCheck("Transcode", func(b bool) {})
 
 	mediafilelabel := canvas.NewText("File:", nil)
 	subsfilelabel := canvas.NewText("Subtitles:", nil)
 	devicelabel := canvas.NewText("Select Device:", nil)
 
+	adddevice := widget.NewButtonWithIcon("Add Device", theme.ContentAddIcon(), func() {
+		addDeviceAction(s, &data)
+	})
+
+	wakedevice := widget.NewButtonWithIcon("Wake Device", theme.MediaPlayIcon(), func() {
+		wakeDeviceAction(s, &data)
+	})
+
 	list = widget.NewList(
 		func() int {
 			return len(data)
@@ -127,7 +144,11 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 			return container.NewHBox(widget.NewIcon(theme.NavigateNextIcon()), widget.NewLabel("Template Object"))
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			o.(*fyne.Container).Objects[1].(*widget.Label).SetText(data[i].name)
+			label := data[i].name
+			if data[i].iface != "" {
+				label = label + " (" + data[i].iface + ")"
+			}
+			o.(*fyne.Container).Objects[1].(*widget.Label).SetText(label)
 		})
 
 	s.PlayPause = playpause
@@ -143,12 +164,12 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 
 	mrightbuttons := container.NewHBox(previewmedia, clearmedia)
 
-	checklists := container.NewHBox(externalmedia, sfilecheck, medialoop, nextmedia)
-	mediasubsbuttons := container.New(layout.NewGridLayout(2), mfile, sfile)
+	checklists := container.NewHBox(externalmedia, sfilecheck, medialoop, nextmedia, transcode)
+	mediasubsbuttons := container.New(layout.NewGridLayout(4), mfile, browsemedia, slides, sfile)
 	mfiletextArea := container.New(layout.NewBorderLayout(nil, nil, nil, mrightbuttons), mrightbuttons, mfiletext)
 	sfiletextArea := container.New(layout.NewBorderLayout(nil, nil, nil, clearsubs), clearsubs, sfiletext)
 	viewfilescont := container.New(layout.NewFormLayout(), mediafilelabel, mfiletextArea, subsfilelabel, sfiletextArea)
-	buttons := container.NewVBox(mediasubsbuttons, viewfilescont, checklists, actionbuttons, container.NewPadded(devicelabel))
+	buttons := container.NewVBox(mediasubsbuttons, viewfilescont, checklists, actionbuttons, container.NewPadded(container.NewBorder(nil, nil, nil, container.NewHBox(wakedevice, adddevice), devicelabel)))
 	content := container.New(layout.NewBorderLayout(buttons, nil, nil, nil), buttons, list)
 
 	// Widgets actions
@@ -178,6 +199,7 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 			nextmedia.SetChecked(false)
 			nextmedia.Disable()
 			mfile.Disable()
+			browsemedia.Disable()
 			previewmedia.Disable()
 
 			// rename the label
@@ -195,6 +217,7 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 			medialoop.Enable()
 			nextmedia.Enable()
 			mfile.Enable()
+			browsemedia.Enable()
 			previewmedia.Enable()
 			mediafilelabel.Text = "File:"
 			mfiletext.SetPlaceHolder("")
@@ -212,6 +235,10 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 		s.NextMedia = b
 	}
 
+	transcode.OnChanged = func(b bool) {
+		s.Transcode = b
+	}
+
 	// Device list auto-refresh
 	go refreshDevList(s, &data)
 
@@ -225,7 +252,7 @@ func refreshDevList(s *NewScreen, data *[]devType) {
 	refreshDevices := time.NewTicker(5 * time.Second)
 
 	for range refreshDevices.C {
-		datanew, _ := getDevices(2)
+		datanew, _ := getDevices(2, s.getInterfaces()...)
 		oldListSize := len(*data)
 
 		// check to see if the new refresh includes
@@ -44,7 +44,7 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 	})
 
 	go func() {
-		datanew, err := getDevices(1)
+		datanew, err := getDevices(1, s.getInterfaces()...)
 		data = datanew
 		if err != nil {
 			data = nil
@@ -102,6 +102,14 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 	subsfilelabel := canvas.NewText("Subtitles:", nil)
 	devicelabel := canvas.NewText("Select Device:", nil)
 
+	adddevice := widget.NewButtonWithIcon("Add Device", theme.ContentAddIcon(), func() {
+		addDeviceAction(s, &data)
+	})
+
+	wakedevice := widget.NewButtonWithIcon("Wake Device", theme.MediaPlayIcon(), func() {
+		wakeDeviceAction(s, &data)
+	})
+
 	list = widget.NewList(
 		func() int {
 			return len(data)
@@ -110,7 +118,11 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 			return container.NewHBox(widget.NewIcon(theme.NavigateNextIcon()), widget.NewLabel("Template Object"))
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			o.(*fyne.Container).Objects[1].(*widget.Label).SetText(data[i].name)
+			label := data[i].name
+			if data[i].iface != "" {
+				label = label + " (" + data[i].iface + ")"
+			}
+			o.(*fyne.Container).Objects[1].(*widget.Label).SetText(label)
 		})
 
 	s.PlayPause = playpause
@@ -128,7 +140,7 @@ func mainWindow(s *NewScreen) fyne.CanvasObject {
 	sfiletextArea := container.New(layout.NewBorderLayout(nil, nil, nil, clearsubs), clearsubs, sfiletext)
 	mfiletextArea := container.New(layout.NewBorderLayout(nil, nil, nil, clearmedia), clearmedia, mfiletext)
 	viewfilescont := container.New(layout.NewFormLayout(), mediafilelabel, mfiletextArea, subsfilelabel, sfiletextArea)
-	buttons := container.NewVBox(mediasubsbuttons, viewfilescont, checklists, actionbuttons, container.NewPadded(devicelabel))
+	buttons := container.NewVBox(mediasubsbuttons, viewfilescont, checklists, actionbuttons, container.NewPadded(container.NewBorder(nil, nil, nil, container.NewHBox(wakedevice, adddevice), devicelabel)))
 	content := container.New(layout.NewBorderLayout(buttons, nil, nil, nil), buttons, list)
 
 	// Widgets actions
@@ -188,7 +200,7 @@ func refreshDevList(s *NewScreen, data *[]devType) {
 	refreshDevices := time.NewTicker(5 * time.Second)
 
 	for range refreshDevices.C {
-		datanew, _ := getDevices(2)
+		datanew, _ := getDevices(2, s.getInterfaces()...)
 		oldListSize := len(*data)
 
 		// check to see if the new refresh includes
@@ -0,0 +1,100 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+// How long we wait for the in-flight responses when we exit.
+const shutdownTimeout = 5 * time.Second
+
+// sessionServer returns the media server of the casting session and
+// the host:port pair that we advertise for it. The server outlives
+// the media items, so we only start a new one when we need to listen
+// on a different IP for the selected device, or when the media server
+// settings change.
+func sessionServer(screen *NewScreen) (*httphandlers.HTTPserver, string, error) {
+	cfg := screen.getListenConfig()
+
+	ip, err := cfg.ListenIP(screen.controlURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("sessionServer error: %w", err)
+	}
+
+	key := fmt.Sprint(ip, cfg.Port, cfg.Advertise, cfg.AllowedHosts, cfg.Rate)
+
+	// The slideshow gets its server off the UI goroutine.
+	screen.mu.Lock()
+	defer screen.mu.Unlock()
+
+	if screen.httpserver != nil && screen.serverKey == key {
+		return screen.httpserver, screen.serverAdvertise, nil
+	}
+
+	if screen.httpserver != nil {
+		screen.httpserver.StopServeFiles()
+		screen.httpserver = nil
+	}
+
+	whereToListen, whereToAdvertise, err := cfg.Addresses(screen.controlURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("sessionServer error #2: %w", err)
+	}
+
+	srv := httphandlers.NewServer(whereToListen)
+	srv.AllowHosts(cfg.AllowedHosts...)
+	srv.LimitRate(cfg.Rate)
+	srv.TrackRequests(screen.requests)
+
+	serverStarted := make(chan struct{})
+	serverErr := make(chan error, 1)
+
+	go func() {
+		serverErr <- srv.Serve(serverStarted)
+	}()
+
+	// Wait for the HTTP server to properly initialize.
+	select {
+	case <-serverStarted:
+	case err := <-serverErr:
+		return nil, "", fmt.Errorf("sessionServer error #3: %w", err)
+	}
+
+	screen.httpserver, screen.serverKey, screen.serverAdvertise = srv, key, whereToAdvertise
+
+	return srv, whereToAdvertise, nil
+}
+
+// endSession ends the casting session when we exit, so that the
+// Media Renderer doesn't keep playing a media that we no longer
+// serve, and drains the session server.
+func endSession(screen *NewScreen) {
+	screen.mu.RLock()
+	tvdata, srv := screen.tvdata, screen.httpserver
+	screen.mu.RUnlock()
+
+	if tvdata != nil {
+		tvdata.Shutdown(true)
+	}
+
+	// We exit, so no other subscription should outlive us.
+	soapcalls.UnsubscribeAll()
+
+	if srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		srv.Shutdown(ctx)
+	}
+}
+
+// Quit - Close the app, e.g. when we receive a signal.
+// We end the casting session on the way out.
+func (p *NewScreen) Quit() {
+	fyne.CurrentApp().Quit()
+}
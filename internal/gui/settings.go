@@ -0,0 +1,238 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+const allInterfaces = "All"
+
+func settingsWindow(s *NewScreen) fyne.CanvasObject {
+	current := s.getListenConfig()
+
+	ifaceOptions := []string{allInterfaces}
+	ifaces, err := utils.InterfaceNames()
+	if err == nil {
+		ifaceOptions = append(ifaceOptions, ifaces...)
+	}
+
+	selected := allInterfaces
+	if len(current.Interfaces) > 0 {
+		selected = current.Interfaces[0]
+	}
+
+	ifaceSelect := widget.NewSelect(ifaceOptions, nil)
+	ifaceSelect.SetSelected(selected)
+
+	// We set the callbacks after the initial values so
+	// that we don't persist settings passed via the CLI.
+	ifaceSelect.OnChanged = func(sel string) {
+		if sel == allInterfaces {
+			s.setInterfaces(nil)
+			fyne.CurrentApp().Preferences().SetString("NetworkInterface", "")
+			return
+		}
+
+		s.setInterfaces([]string{sel})
+		fyne.CurrentApp().Preferences().SetString("NetworkInterface", sel)
+	}
+
+	portEntry := widget.NewEntry()
+	portEntry.SetPlaceHolder("Auto (3500+)")
+	if current.Port != 0 {
+		portEntry.SetText(strconv.Itoa(current.Port))
+	}
+
+	portEntry.OnChanged = func(v string) {
+		if v == "" {
+			s.setListenPort(0)
+			fyne.CurrentApp().Preferences().SetInt("ListenPort", 0)
+			return
+		}
+
+		p, err := strconv.Atoi(v)
+		if err != nil || p <= 0 || p > 65535 {
+			return
+		}
+
+		s.setListenPort(p)
+		fyne.CurrentApp().Preferences().SetInt("ListenPort", p)
+	}
+
+	advEntry := widget.NewEntry()
+	advEntry.SetPlaceHolder("host[:port]")
+	advEntry.SetText(current.Advertise)
+
+	advEntry.OnChanged = func(v string) {
+		if v != "" {
+			if _, err := utils.AdvertisedHostPort("0.0.0.0:3500", v); err != nil {
+				return
+			}
+		}
+
+		s.setAdvertise(v)
+		fyne.CurrentApp().Preferences().SetString("AdvertiseAddress", v)
+	}
+
+	allowEntry := widget.NewEntry()
+	allowEntry.SetPlaceHolder("Only the Media Renderer, or * for any host")
+	allowEntry.SetText(strings.Join(current.AllowedHosts, ", "))
+
+	allowEntry.Validator = checkHosts
+
+	// We only keep the hosts once they're all valid,
+	// i.e. not while they're still being typed.
+	allowEntry.OnChanged = func(v string) {
+		if checkHosts(v) != nil {
+			return
+		}
+
+		s.setAllowedHosts(splitHosts(v))
+		fyne.CurrentApp().Preferences().SetString("AllowedHosts", v)
+	}
+
+	rateEntry := widget.NewEntry()
+	rateEntry.SetPlaceHolder("Unlimited (e.g. 20M, auto)")
+	rateEntry.SetText(current.Rate.String())
+
+	rateEntry.OnChanged = func(v string) {
+		r, err := utils.ParseStreamRate(v)
+		if err != nil {
+			return
+		}
+
+		s.setStreamRate(r)
+		fyne.CurrentApp().Preferences().SetString("StreamRate", v)
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Network Interface", ifaceSelect),
+		widget.NewFormItem("Listen Port", portEntry),
+		widget.NewFormItem("Advertise Address", advEntry),
+		widget.NewFormItem("Allowed Hosts", allowEntry),
+		widget.NewFormItem("Streaming Rate", rateEntry),
+	)
+
+	return container.NewVBox(form)
+}
+
+// setInterfaces sets the network interfaces that are
+// used for the device discovery and the media serving.
+func (p *NewScreen) setInterfaces(i []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listenConfig.Interfaces = i
+}
+
+// getInterfaces returns the selected network interfaces.
+// An empty list means that all the interfaces are used.
+func (p *NewScreen) getInterfaces() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.listenConfig.Interfaces
+}
+
+func (p *NewScreen) setListenPort(port int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listenConfig.Port = port
+}
+
+func (p *NewScreen) setAdvertise(a string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listenConfig.Advertise = a
+}
+
+// setAllowedHosts sets the extra hosts, besides the Media
+// Renderer, that can fetch the media and the subtitles.
+func (p *NewScreen) setAllowedHosts(h []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listenConfig.AllowedHosts = h
+}
+
+// setStreamRate sets the rate limit of the media
+// that we serve, as a fixed cap or as pacing.
+func (p *NewScreen) setStreamRate(r utils.StreamRate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listenConfig.Rate = r
+}
+
+// getListenConfig returns the media server network settings.
+func (p *NewScreen) getListenConfig() utils.ListenConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.listenConfig
+}
+
+// SetListenConfig overrides the media server network settings.
+// Used when the settings are passed from the command line.
+func (p *NewScreen) SetListenConfig(c utils.ListenConfig) {
+	if len(c.Interfaces) > 0 {
+		p.setInterfaces(c.Interfaces)
+	}
+
+	if c.Port != 0 {
+		p.setListenPort(c.Port)
+	}
+
+	if c.Advertise != "" {
+		p.setAdvertise(c.Advertise)
+	}
+
+	if len(c.AllowedHosts) > 0 {
+		p.setAllowedHosts(c.AllowedHosts)
+	}
+
+	if c.Rate.Enabled() {
+		p.setStreamRate(c.Rate)
+	}
+}
+
+func loadListenPreferences(p *NewScreen) {
+	prefs := fyne.CurrentApp().Preferences()
+
+	if iface := prefs.String("NetworkInterface"); iface != "" {
+		p.setInterfaces([]string{iface})
+	}
+
+	p.setListenPort(prefs.Int("ListenPort"))
+	p.setAdvertise(prefs.String("AdvertiseAddress"))
+	p.setAllowedHosts(splitHosts(prefs.String("AllowedHosts")))
+
+	// Rates that we can't parse were never stored.
+	r, _ := utils.ParseStreamRate(prefs.String("StreamRate"))
+	p.setStreamRate(r)
+}
+
+// splitHosts splits a comma separated list of hosts.
+// checkHosts validates the comma separated list of the allowed hosts.
+func checkHosts(s string) error {
+	for _, h := range splitHosts(s) {
+		if err := httphandlers.CheckHost(h); err != nil {
+			return fmt.Errorf("checkHosts error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func splitHosts(s string) []string {
+	var hosts []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
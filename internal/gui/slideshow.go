@@ -0,0 +1,359 @@
+//go:build !(android || ios)
+// +build !android,!ios
+
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/alexballas/go2tv/internal/slideshow"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// slideshowScreen - The screen of the slideshow callbacks. The slides
+// don't take part in the media loop and the auto-select next file logic.
+type slideshowScreen struct {
+	*NewScreen
+}
+
+func (slideshowScreen) Fini() {}
+
+// The audio files that we can play as background music.
+var musicFormats = []string{".mp3", ".flac", ".wav", ".m4a", ".aac", ".ogg", ".oga", ".opus"}
+
+// slideshowOptions - The slideshow settings of the Slideshow window.
+type slideshowOptions struct {
+	path     string
+	interval time.Duration
+	shuffle  bool
+	// The background music plays on a second
+	// Media Renderer, when we select both.
+	music       string
+	musicDevice string
+}
+
+func slideshowAction(screen *NewScreen) {
+	w := fyne.CurrentApp().NewWindow("Slideshow")
+
+	var slidesPath, musicPath string
+
+	pathText := widget.NewEntry()
+	pathText.Disable()
+
+	musicText := widget.NewEntry()
+	musicText.Disable()
+
+	duration := widget.NewEntry()
+	duration.SetText("5s")
+
+	shuffle := widget.NewCheck("Shuffle", func(bool) {})
+	status := widget.NewLabel("Select a folder or a playlist of photos.")
+
+	setPath := func(p string) {
+		slidesPath = p
+		pathText.SetText(filepath.Base(p))
+	}
+
+	setMusic := func(p string) {
+		musicPath = p
+		musicText.SetText(filepath.Base(p))
+	}
+
+	// The devices that can play the background music. We
+	// look for them in the background, as we do for the
+	// main window.
+	var devicesMu sync.Mutex
+	musicDevices := make(map[string]string)
+
+	musicDevice := widget.NewSelect(nil, nil)
+	musicDevice.PlaceHolder = "Music Device"
+
+	go func() {
+		list, err := getDevices(1, screen.getInterfaces()...)
+		if err != nil {
+			return
+		}
+
+		names := make([]string, 0, len(list))
+		devicesMu.Lock()
+		for _, d := range list {
+			musicDevices[d.name] = d.addr
+			names = append(names, d.name)
+		}
+		devicesMu.Unlock()
+
+		musicDevice.Options = names
+		musicDevice.Refresh()
+	}()
+	folder := widget.NewButton("Select Folder", func() {
+		fd := dialog.NewFolderOpen(func(l fyne.ListableURI, err error) {
+			check(w, err)
+			if l == nil {
+				return
+			}
+			setPath(l.Path())
+		}, w)
+		fd.Resize(fyne.NewSize(w.Canvas().Size().Width*1.2, w.Canvas().Size().Height*1.3))
+		fd.Show()
+	})
+
+	playlist := widget.NewButton("Select Playlist", func() {
+		fd := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			check(w, err)
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			setPath(reader.URI().Path())
+		}, w)
+		fd.SetFilter(storage.NewExtensionFileFilter([]string{".m3u", ".m3u8"}))
+		fd.Resize(fyne.NewSize(w.Canvas().Size().Width*1.2, w.Canvas().Size().Height*1.3))
+		fd.Show()
+	})
+
+	music := widget.NewButton("Select Music", func() {
+		fd := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			check(w, err)
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			setMusic(reader.URI().Path())
+		}, w)
+		fd.SetFilter(storage.NewExtensionFileFilter(musicFormats))
+		fd.Resize(fyne.NewSize(w.Canvas().Size().Width*1.2, w.Canvas().Size().Height*1.3))
+		fd.Show()
+	})
+
+	clearMusic := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		setMusic("")
+		musicDevice.ClearSelected()
+	})
+
+	pause := widget.NewButtonWithIcon("Pause", theme.MediaPauseIcon(), nil)
+
+	start := widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), func() {
+		d, err := time.ParseDuration(duration.Text)
+		if err != nil || d <= 0 {
+			check(w, errors.New("please enter a slide duration, e.g. 5s"))
+			return
+		}
+
+		devicesMu.Lock()
+		musicURL := musicDevices[musicDevice.Selected]
+		devicesMu.Unlock()
+
+		if (musicPath == "") != (musicURL == "") {
+			check(w, errors.New("please select both the music file and its device"))
+			return
+		}
+
+		pause.SetText("Pause")
+		pause.SetIcon(theme.MediaPauseIcon())
+
+		opts := slideshowOptions{
+			path:        slidesPath,
+			interval:    d,
+			shuffle:     shuffle.Checked,
+			music:       musicPath,
+			musicDevice: musicURL,
+		}
+
+		go func() {
+			err := startSlideshow(screen, opts, func(pos, total int, path string, err error) {
+				text := fmt.Sprintf("Slide %d/%d: %s", pos, total, filepath.Base(path))
+				if err != nil {
+					text += "\n" + err.Error()
+				}
+				status.SetText(text)
+			})
+			check(w, err)
+		}()
+	})
+
+	previous := widget.NewButtonWithIcon("", theme.MediaSkipPreviousIcon(), func() {
+		if show := screen.currentSlideshow(); show != nil {
+			go show.Previous()
+		}
+	})
+
+	next := widget.NewButtonWithIcon("", theme.MediaSkipNextIcon(), func() {
+		if show := screen.currentSlideshow(); show != nil {
+			go show.Next()
+		}
+	})
+
+	pause.OnTapped = func() {
+		show := screen.currentSlideshow()
+		if show == nil {
+			return
+		}
+
+		if show.TogglePause() {
+			pause.SetText("Resume")
+			pause.SetIcon(theme.MediaPlayIcon())
+			return
+		}
+
+		pause.SetText("Pause")
+		pause.SetIcon(theme.MediaPauseIcon())
+	}
+
+	stop := widget.NewButtonWithIcon("Stop", theme.MediaStopIcon(), func() {
+		go stopAction(screen)
+		status.SetText("Slideshow stopped.")
+	})
+
+	pathArea := container.New(layout.NewBorderLayout(nil, nil, nil, container.NewHBox(folder, playlist)), container.NewHBox(folder, playlist), pathText)
+	musicArea := container.New(layout.NewBorderLayout(nil, nil, nil, container.NewHBox(music, musicDevice, clearMusic)), container.NewHBox(music, musicDevice, clearMusic), musicText)
+	form := container.New(layout.NewFormLayout(), widget.NewLabel("Photos:"), pathArea, widget.NewLabel("Music:"), musicArea, widget.NewLabel("Slide Duration:"), duration)
+	controls := container.New(layout.NewGridLayout(5), start, previous, pause, next, stop)
+
+	w.SetContent(container.NewPadded(container.NewVBox(form, shuffle, controls, status)))
+	w.Resize(fyne.NewSize(700, 240))
+	w.CenterOnScreen()
+	w.Show()
+}
+
+// startSlideshow casts the photos of a folder, or an M3U playlist,
+// to the selected device, replacing the current casting session.
+// We run it off the UI goroutine, so the screen state that we
+// share with the UI callbacks only changes under screen.mu.
+func startSlideshow(screen *NewScreen, opts slideshowOptions, onSlide func(pos, total int, path string, err error)) error {
+	if opts.path == "" {
+		return errors.New("please select a folder or a playlist of photos")
+	}
+
+	if screen.controlURL == "" {
+		return errors.New("please select a device")
+	}
+
+	slides, err := slideshow.LoadSlides(opts.path)
+	if err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	screen.mu.RLock()
+	casting := screen.tvdata != nil
+	screen.mu.RUnlock()
+
+	if casting {
+		stopAction(screen)
+	}
+
+	srv, whereToAdvertise, err := sessionServer(screen)
+	if err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	profile, ffmpeg, err := photoProfile(screen)
+	if err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	callbackPath, err := utils.RandomString()
+	if err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	// The session payload only holds the event subscriptions.
+	// Each slide gets its own payload.
+	tvdata := &soapcalls.TVPayload{
+		ControlURL:          screen.controlURL,
+		EventURL:            screen.eventlURL,
+		RenderingControlURL: screen.renderingControlURL,
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+
+	caster := &slideshow.Caster{
+		Server:    srv,
+		TV:        tvdata,
+		Advertise: whereToAdvertise,
+		Profile:   profile,
+		FFmpeg:    ffmpeg,
+	}
+
+	show, err := slideshow.New(slides, opts.interval, opts.shuffle, caster.Cast)
+	if err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	caster.Screen = show.Screen(slideshowScreen{screen})
+	show.OnSlide = onSlide
+
+	// The first slide takes the callback over.
+	if err := srv.AddItem(tvdata, nil, nil, caster.Screen); err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	screen.mu.Lock()
+	screen.tvdata, screen.slideshow, screen.slideCaster = tvdata, show, caster
+	screen.mu.Unlock()
+
+	if err := show.Start(); err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	if opts.music == "" {
+		return nil
+	}
+
+	music, err := slideshow.CastMusic(srv, opts.music, opts.musicDevice, whereToAdvertise)
+	if err != nil {
+		return fmt.Errorf("startSlideshow error: %w", err)
+	}
+
+	screen.mu.Lock()
+	defer screen.mu.Unlock()
+
+	// The slideshow may have stopped while we
+	// started the music on the second device.
+	if screen.slideshow != show {
+		go slideshow.StopMusic(srv, music)
+		return nil
+	}
+
+	screen.slideMusic = music
+
+	return nil
+}
+
+// stopSlideshow stops advancing the slides, if there's a
+// slideshow, and stops serving the current slide and the
+// background music.
+func stopSlideshow(screen *NewScreen) {
+	screen.mu.Lock()
+	show, caster, music := screen.slideshow, screen.slideCaster, screen.slideMusic
+	srv := screen.httpserver
+	screen.slideshow, screen.slideCaster, screen.slideMusic = nil, nil, nil
+	screen.mu.Unlock()
+
+	if show != nil {
+		show.Stop()
+		caster.Close()
+	}
+
+	if music != nil {
+		slideshow.StopMusic(srv, music)
+	}
+}
+
+// currentSlideshow returns the running slideshow, if any.
+func (p *NewScreen) currentSlideshow() *slideshow.Slideshow {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.slideshow
+}
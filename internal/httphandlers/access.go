@@ -0,0 +1,187 @@
+package httphandlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// AnyHost - The allowed host that turns the access checks off, e.g.
+// for the Media Renderers that fetch the media from a different IP
+// than their ControlURL host, like multi-homed TVs or behind NAT.
+const AnyHost = "*"
+
+// accessPolicy - Only the Media Renderer, and the extra hosts
+// that the user allows, get to fetch the media and the subtitles.
+// Only the Media Renderer gets to send us event notifications.
+type accessPolicy struct {
+	renderer map[string]bool
+	allowed  map[string]bool
+	anyHost  bool
+}
+
+func newAccessPolicy(rendererURL string, hosts []string) (*accessPolicy, error) {
+	u, err := url.Parse(rendererURL)
+	if err != nil {
+		return nil, fmt.Errorf("newAccessPolicy parse error: %w", err)
+	}
+
+	if u.Hostname() == "" {
+		return nil, errors.New("newAccessPolicy: unknown Media Renderer host")
+	}
+
+	a := &accessPolicy{
+		renderer: make(map[string]bool),
+		allowed:  make(map[string]bool),
+	}
+
+	ips, err := resolveHost(u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("newAccessPolicy renderer error: %w", err)
+	}
+
+	for _, ip := range ips {
+		a.renderer[ip] = true
+		a.allowed[ip] = true
+	}
+
+	for _, h := range hosts {
+		if strings.TrimSpace(h) == AnyHost {
+			a.anyHost = true
+			continue
+		}
+
+		// A host that we can't resolve, e.g. a typo or a host that
+		// is offline, shouldn't stop us from casting.
+		ips, err := resolveHost(h)
+		if err != nil {
+			log.Printf("newAccessPolicy: skipping allowed host %q: %s", h, err)
+			continue
+		}
+
+		for _, ip := range ips {
+			a.allowed[ip] = true
+		}
+	}
+
+	return a, nil
+}
+
+// fromRenderer - The request comes from the Media Renderer.
+func (a *accessPolicy) fromRenderer(r *http.Request) bool {
+	return a.anyHost || a.renderer[remoteIP(r)]
+}
+
+// isAllowed - The request comes from the Media
+// Renderer or from one of the allowed hosts.
+func (a *accessPolicy) isAllowed(r *http.Request) bool {
+	return a.anyHost || a.allowed[remoteIP(r)]
+}
+
+// restrict only lets the allowed hosts through to the handler.
+func (a *accessPolicy) restrict(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.isAllowed(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// CheckHost - Check that h is an allowed host we can work with, i.e.
+// AnyHost, an IP or a host name, optionally with a port. We don't
+// resolve the host names here.
+func CheckHost(h string) error {
+	h = strings.TrimSpace(h)
+	if h == AnyHost {
+		return nil
+	}
+
+	if host, port, err := net.SplitHostPort(h); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("checkHost: invalid port %q", port)
+		}
+		h = host
+	}
+	h = strings.Trim(h, "[]")
+
+	if normalizeIP(h) != "" {
+		return nil
+	}
+
+	if h == "" || len(h) > 253 {
+		return fmt.Errorf("checkHost: invalid host %q", h)
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(h, "."), ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("checkHost: invalid host %q", h)
+		}
+
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("checkHost: invalid host %q", h)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveHost returns the IPs of a host, a host:port pair or an IP.
+func resolveHost(h string) ([]string, error) {
+	h = strings.TrimSpace(h)
+	if host, _, err := net.SplitHostPort(h); err == nil {
+		h = host
+	}
+	h = strings.Trim(h, "[]")
+
+	if ip := normalizeIP(h); ip != "" {
+		return []string{ip}, nil
+	}
+
+	addrs, err := net.LookupHost(h)
+	if err != nil {
+		return nil, fmt.Errorf("resolveHost lookup error: %w", err)
+	}
+
+	ips := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if ip := normalizeIP(a); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips, nil
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return normalizeIP(host)
+}
+
+// normalizeIP makes sure that we compare the same IP forms, e.g.
+// IPv4-mapped IPv6 addresses and IPv6 addresses with zones.
+func normalizeIP(s string) string {
+	if i := strings.LastIndex(s, "%"); i > 0 {
+		s = s[:i]
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
@@ -0,0 +1,143 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+type nopScreen struct{}
+
+func (nopScreen) EmitMsg(string) {}
+func (nopScreen) Fini()          {}
+
+func TestAccessPolicy(t *testing.T) {
+	policy, err := newAccessPolicy("http://192.168.1.20:9197/dmr/control", []string{"192.168.1.30", " [fe80::1%eth0]:8080 "})
+	if err != nil {
+		t.Fatalf("newAccessPolicy error: %s", err)
+	}
+
+	served := policy.restrict(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("media"))
+	})
+
+	tt := []struct {
+		remote   string
+		want     int
+		renderer bool
+		name     string
+	}{
+		{"192.168.1.20:50000", http.StatusOK, true, `Media Renderer`},
+		{"[::ffff:192.168.1.20]:50000", http.StatusOK, true, `IPv4-mapped Media Renderer`},
+		{"192.168.1.30:50000", http.StatusOK, false, `Allowed host`},
+		{"[fe80::1]:50000", http.StatusOK, false, `Allowed IPv6 host`},
+		{"192.168.1.40:50000", http.StatusForbidden, false, `Other host`},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/token/video.mp4", nil)
+		r.RemoteAddr = tc.remote
+
+		served(w, r)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, w.Code, tc.want)
+		}
+
+		if policy.fromRenderer(r) != tc.renderer {
+			t.Errorf("%s: fromRenderer got: %t, want: %t.", tc.name, !tc.renderer, tc.renderer)
+		}
+	}
+
+	if _, err := newAccessPolicy("", nil); err == nil {
+		t.Errorf("Missing Media Renderer: expected error")
+	}
+}
+
+func TestAccessPolicyHosts(t *testing.T) {
+	tt := []struct {
+		hosts    []string
+		remote   string
+		allowed  bool
+		renderer bool
+		name     string
+	}{
+		{[]string{"nonexistent.invalid", "192.168.1.30"}, "192.168.1.30:50000", true, false, `Unresolvable host skipped`},
+		{[]string{"nonexistent.invalid"}, "192.168.1.20:50000", true, true, `Media Renderer with an unresolvable host`},
+		{nil, "192.168.1.40:50000", false, false, `Renderer only`},
+		{[]string{AnyHost}, "192.168.1.40:50000", true, true, `Any host`},
+	}
+
+	for _, tc := range tt {
+		policy, err := newAccessPolicy("http://192.168.1.20:9197/dmr/control", tc.hosts)
+		if err != nil {
+			t.Errorf("%s: newAccessPolicy error: %s", tc.name, err)
+			continue
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/token/video.mp4", nil)
+		r.RemoteAddr = tc.remote
+
+		if got := policy.isAllowed(r); got != tc.allowed {
+			t.Errorf("%s: isAllowed got: %t, want: %t.", tc.name, got, tc.allowed)
+		}
+
+		if got := policy.fromRenderer(r); got != tc.renderer {
+			t.Errorf("%s: fromRenderer got: %t, want: %t.", tc.name, got, tc.renderer)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tt := []struct {
+		host  string
+		valid bool
+		name  string
+	}{
+		{"*", true, `Any host`},
+		{"192.168.1.30", true, `IPv4`},
+		{"[fe80::1%eth0]:8080", true, `IPv6 with a port`},
+		{"tv.local", true, `Host name`},
+		{"tv.local:8080", true, `Host name with a port`},
+		{"192.168.1.", true, `Half-typed IPv4 is a host name`},
+		{"tv..local", false, `Empty label`},
+		{"tv local", false, `Space`},
+		{"-tv.local", false, `Leading hyphen`},
+		{"http://tv", false, `URL`},
+	}
+
+	for _, tc := range tt {
+		if err := CheckHost(tc.host); (err == nil) != tc.valid {
+			t.Errorf("%s: got: %v, want valid: %t.", tc.name, err, tc.valid)
+		}
+	}
+}
+
+func TestCallbackSource(t *testing.T) {
+	policy, err := newAccessPolicy("http://192.168.1.20:9197/dmr/control", []string{"192.168.1.30"})
+	if err != nil {
+		t.Fatalf("newAccessPolicy error: %s", err)
+	}
+
+	s := NewServer("127.0.0.1:0")
+	handler := s.callbackHandler(&soapcalls.TVPayload{}, nopScreen{}, policy)
+
+	// Allowed hosts can fetch the media, but only
+	// the Media Renderer gets to send us events.
+	for _, remote := range []string{"192.168.1.30:50000", "192.168.1.40:50000"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("NOTIFY", "/callback", strings.NewReader("<e:propertyset/>"))
+		r.Header.Set("SID", "uuid:1234")
+		r.RemoteAddr = remote
+
+		handler(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("NOTIFY from %s: got: %d, want: %d.", remote, w.Code, http.StatusForbidden)
+		}
+	}
+}
@@ -2,24 +2,38 @@ package httphandlers
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html"
 	"io"
-	"net"
+	"math"
 	"net/http"
-	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/alexballas/go2tv/internal/imageconv"
 	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/timeseek"
+	"github.com/alexballas/go2tv/internal/transcoder"
+	"github.com/alexballas/go2tv/internal/urlstreamer"
 	"github.com/alexballas/go2tv/internal/utils"
 )
 
 // HTTPserver - new http.Server instance.
 type HTTPserver struct {
 	http *http.Server
-	mux  *http.ServeMux
+	// The session items and the handlers of their
+	// paths. Items come and go while we serve.
+	mu     sync.RWMutex
+	routes map[string]http.HandlerFunc
+	items  map[*soapcalls.TVPayload]*sessionItem
+	// Extra hosts, besides the Media Renderer,
+	// that get to fetch the media and the subtitles.
+	allowedHosts []string
+	rate         utils.StreamRate
 }
 
 // Screen interface.
@@ -38,43 +52,23 @@ func Close(scr Screen) {
 	scr.Fini()
 }
 
-// ServeFiles - Start HTTP server and serve the files.
+// ServeFiles - Start HTTP server and serve the files. It's the
+// single item form of AddItem and Serve.
 func (s *HTTPserver) ServeFiles(serverStarted chan<- struct{}, media, subtitles interface{},
 	tvpayload *soapcalls.TVPayload, screen Screen) error {
 
-	mURL, err := url.Parse(tvpayload.MediaURL)
-	if err != nil {
-		return fmt.Errorf("failed to parse MediaURL: %w", err)
-	}
-
-	sURL, err := url.Parse(tvpayload.SubtitlesURL)
-	if err != nil {
-		return fmt.Errorf("failed to parse SubtitlesURL: %w", err)
-	}
-
-	callbackURL, err := url.Parse(tvpayload.CallbackURL)
-	if err != nil {
-		return fmt.Errorf("failed to parse CallbackURL: %w", err)
-	}
-
-	s.mux.HandleFunc(mURL.Path, s.serveMediaHandler(tvpayload, media))
-	s.mux.HandleFunc(sURL.Path, s.serveSubtitlesHandler(subtitles))
-	s.mux.HandleFunc(callbackURL.Path, s.callbackHandler(tvpayload, screen))
-
-	ln, err := net.Listen("tcp", s.http.Addr)
-	if err != nil {
-		return fmt.Errorf("server listen error: %w", err)
+	if err := s.AddItem(tvpayload, media, subtitles, screen); err != nil {
+		return err
 	}
 
-	serverStarted <- struct{}{}
-	s.http.Serve(ln)
-
-	return nil
+	return s.Serve(serverStarted)
 }
 
 func (s *HTTPserver) serveMediaHandler(tv *soapcalls.TVPayload, media interface{}) http.HandlerFunc {
+	limit := mediaRate(s.rate, media)
+
 	return func(w http.ResponseWriter, req *http.Request) {
-		serveContent(w, req, tv, media, true)
+		serveContent(pace(w, req, limit), req, tv, media, true)
 	}
 }
 
@@ -84,8 +78,15 @@ func (s *HTTPserver) serveSubtitlesHandler(subs interface{}) http.HandlerFunc {
 	}
 }
 
-func (s *HTTPserver) callbackHandler(tv *soapcalls.TVPayload, screen Screen) http.HandlerFunc {
+func (s *HTTPserver) callbackHandler(tv *soapcalls.TVPayload, screen Screen, policy *accessPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		// Anyone on the LAN could otherwise stop our
+		// casting session with a fake STOPPED event.
+		if !policy.fromRenderer(req) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
 		reqParsed, _ := io.ReadAll(req.Body)
 		sidVal, sidExists := req.Header["Sid"]
 
@@ -141,20 +142,68 @@ func (s *HTTPserver) callbackHandler(tv *soapcalls.TVPayload, screen Screen) htt
 	}
 }
 
-// StopServeFiles .
+// AllowHosts - Let extra hosts, besides the Media Renderer, fetch
+// the media and the subtitles. The hosts can be IPs or hostnames.
+// Call it before adding the items.
+func (s *HTTPserver) AllowHosts(hosts ...string) {
+	s.allowedHosts = append(s.allowedHosts, hosts...)
+}
+
+// LimitRate - Limit the rate that we serve the
+// media at. Call it before adding the items.
+func (s *HTTPserver) LimitRate(r utils.StreamRate) {
+	s.rate = r
+}
+
+// TrackRequests - Record the requests that we serve
+// to st. Call it before starting the server.
+func (s *HTTPserver) TrackRequests(st *RequestStats) {
+	s.http.Handler = st.track(s)
+}
+
+// StopServeFiles - Stop the server and all its items.
 func (s *HTTPserver) StopServeFiles() {
 	s.http.Close()
+	s.removeItems()
+}
+
+// Shutdown - Stop the server and all its items gracefully. We
+// wait for the in-flight responses until ctx is done, and then
+// we close them.
+func (s *HTTPserver) Shutdown(ctx context.Context) error {
+	defer s.removeItems()
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		if err := s.http.Close(); err != nil {
+			return fmt.Errorf("Shutdown close error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *HTTPserver) removeItems() {
+	s.mu.RLock()
+	items := make([]*soapcalls.TVPayload, 0, len(s.items))
+	for tv := range s.items {
+		items = append(items, tv)
+	}
+	s.mu.RUnlock()
+
+	for _, tv := range items {
+		s.RemoveItem(tv)
+	}
 }
 
 // NewServer - create a new HTTP server.
 func NewServer(a string) *HTTPserver {
-	mux := http.NewServeMux()
-	srv := HTTPserver{
-		http: &http.Server{Addr: a, Handler: mux},
-		mux:  mux,
+	srv := &HTTPserver{
+		routes: make(map[string]http.HandlerFunc),
+		items:  make(map[*soapcalls.TVPayload]*sessionItem),
 	}
+	srv.http = &http.Server{Addr: a, Handler: srv}
 
-	return &srv
+	return srv
 }
 
 func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayload, s interface{}, isMedia bool) {
@@ -166,23 +215,15 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 		respHeader["transferMode.dlna.org"] = []string{"Interactive"}
 	}
 
-	var mediaType string
+	var mediaType, dlnaProfile string
 	if tv != nil {
-		mediaType = tv.MediaType
+		mediaType, dlnaProfile = tv.MediaType, tv.DLNAProfile
 	}
 
+	paced := isPaced(w)
+
 	switch f := s.(type) {
 	case string:
-		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
-			contentFeatures, err := utils.BuildContentFeatures(mediaType, "01", false)
-			if err != nil {
-				http.NotFound(w, r)
-				return
-			}
-
-			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
-		}
-
 		filePath, err := os.Open(f)
 		if err != nil {
 			http.NotFound(w, r)
@@ -196,12 +237,46 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 			return
 		}
 
+		timeSeek := r.Header.Get("TimeSeekRange.dlna.org")
+
+		// We only build the time seek index when the renderer
+		// asks for the seek capabilities or for a time seek.
+		var idx timeseek.Index
+		if isMedia && (timeSeek != "" || r.Header.Get("getcontentFeatures.dlna.org") == "1") {
+			idx, _ = timeseek.OpenFile(f)
+		}
+
+		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+			seek := "01"
+			if idx != nil {
+				seek = "11"
+			}
+
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{
+				MediaType:   mediaType,
+				DLNAProfile: dlnaProfile,
+				Seek:        seek,
+				Paced:       paced,
+			})
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
+		}
+
+		if isMedia && timeSeek != "" {
+			serveTimeSeek(w, r, idx, filePath, fileStat.Size(), timeSeek, mediaType)
+			return
+		}
+
 		name := strings.TrimLeft(r.URL.Path, "/")
 		http.ServeContent(w, r, name, fileStat.ModTime(), filePath)
 
 	case []byte:
 		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
-			contentFeatures, err := utils.BuildContentFeatures(mediaType, "01", false)
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{MediaType: mediaType, Seek: "01", Paced: paced})
 			if err != nil {
 				http.NotFound(w, r)
 				return
@@ -215,9 +290,160 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 		name := strings.TrimLeft(r.URL.Path, "/")
 		http.ServeContent(w, r, name, time.Now(), bReader)
 
+	case *imageconv.Photo:
+		// We convert the photo on the first request.
+		b, err := f.JPEG()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{
+				MediaType:   imageconv.MediaType,
+				DLNAProfile: f.DLNAProfile(),
+				Seek:        "01",
+				Transcode:   true,
+				Paced:       paced,
+			})
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
+		}
+
+		respHeader.Set("Content-Type", imageconv.MediaType)
+
+		name := strings.TrimLeft(r.URL.Path, "/")
+		http.ServeContent(w, r, name, time.Now(), bytes.NewReader(b))
+
+	case *urlstreamer.RangeSource:
+		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{MediaType: mediaType, Seek: "01", Paced: paced})
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
+		}
+
+		if r.Method == http.MethodHead {
+			respHeader.Set("Accept-Ranges", "bytes")
+			respHeader.Set("Content-Length", strconv.FormatInt(f.Length, 10))
+			if f.ContentType != "" {
+				respHeader.Set("Content-Type", f.ContentType)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Each renderer request is proxied to the origin
+		// with its own Range header.
+		resp, err := f.Open(r.Context(), r.Header.Get("Range"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		for _, h := range []string{"Accept-Ranges", "Content-Length", "Content-Range", "Content-Type"} {
+			if v := resp.Header.Get(h); v != "" {
+				respHeader.Set(h, v)
+			}
+		}
+
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+
+	case *transcoder.Transcoder:
+		// Transcoded media only support time based seek,
+		// as we don't know the byte offsets upfront.
+		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{MediaType: f.Profile.MediaType, Seek: "10", Transcode: true, Paced: paced})
+			if err != nil {
+				// Custom profiles may not have a DLNA profile.
+				contentFeatures, _ = utils.BuildContentFeatures(utils.ContentFeatures{Seek: "10", Transcode: true, Paced: paced})
+			}
+
+			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
+		}
+
+		respHeader.Set("Content-Type", f.Profile.MediaType)
+
+		var offset time.Duration
+		if tsr := r.Header.Get("TimeSeekRange.dlna.org"); tsr != "" {
+			start, end, err := utils.ParseTimeSeekRange(tsr)
+			if err != nil {
+				// DLNA asks for "406 Not Acceptable" on invalid ranges.
+				http.Error(w, err.Error(), http.StatusNotAcceptable)
+				return
+			}
+
+			if duration, err := f.Duration(); err == nil {
+				respHeader["TimeSeekRange.dlna.org"] = []string{utils.TimeSeekRangeHeader(start, end, duration)}
+			}
+
+			offset = start
+		}
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		out, err := f.Start(r.Context(), offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer out.Close()
+
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, out)
+
+	case *urlstreamer.BufferedStream:
+		// We can only serve byte ranges when we know the media size.
+		length := f.Len()
+		seek := "00"
+		if length >= 0 {
+			seek = "01"
+		}
+
+		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{MediaType: mediaType, Seek: seek, Paced: paced})
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			respHeader["contentFeatures.dlna.org"] = []string{contentFeatures}
+		}
+
+		if f.ContentType != "" {
+			respHeader.Set("Content-Type", f.ContentType)
+		}
+
+		if length >= 0 {
+			name := strings.TrimLeft(r.URL.Path, "/")
+			http.ServeContent(w, r, name, time.Now(), io.NewSectionReader(f, 0, length))
+			return
+		}
+
+		// The origin did not provide the media size and the download
+		// is still in progress, so we ignore any Range header and
+		// stream from the start of the buffer.
+		if r.Method == http.MethodGet {
+			io.Copy(w, io.NewSectionReader(f, 0, math.MaxInt64))
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+
 	case io.ReadCloser:
 		if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
-			contentFeatures, err := utils.BuildContentFeatures(mediaType, "00", false)
+			contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{MediaType: mediaType, Seek: "00", Paced: paced})
 			if err != nil {
 				http.NotFound(w, r)
 				return
@@ -239,3 +465,49 @@ func serveContent(w http.ResponseWriter, r *http.Request, tv *soapcalls.TVPayloa
 		return
 	}
 }
+
+// serveTimeSeek serves the TimeSeekRange.dlna.org requests. We map
+// the requested times to byte offsets and reply with the actual
+// time and byte ranges that we serve.
+func serveTimeSeek(w http.ResponseWriter, r *http.Request, idx timeseek.Index, f io.ReaderAt, size int64, timeSeek, mediaType string) {
+	// DLNA asks for "406 Not Acceptable" when we
+	// can't serve the time seek request.
+	if idx == nil {
+		http.Error(w, timeseek.ErrUnsupported.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	start, end, err := utils.ParseTimeSeekRange(timeSeek)
+	if err != nil || start > idx.Duration() {
+		http.Error(w, "invalid time seek range", http.StatusNotAcceptable)
+		return
+	}
+
+	first, at, err := idx.Offset(start)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	last := size - 1
+	if end >= 0 {
+		if endOffset, _, err := idx.Offset(end); err == nil && endOffset > first {
+			last = endOffset - 1
+		}
+	}
+
+	respHeader := w.Header()
+	respHeader["TimeSeekRange.dlna.org"] = []string{
+		fmt.Sprintf("%s bytes=%d-%d/%d", utils.TimeSeekRangeHeader(at, end, idx.Duration()), first, last, size),
+	}
+	respHeader.Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+	if mediaType != "" {
+		respHeader.Set("Content-Type", mediaType)
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		io.Copy(w, io.NewSectionReader(f, first, last-first+1))
+	}
+}
@@ -2,10 +2,22 @@ package httphandlers
 
 import (
 	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/imageconv"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/urlstreamer"
 )
 
 func TestServeContent(t *testing.T) {
@@ -47,3 +59,230 @@ func TestServeContent(t *testing.T) {
 
 	}
 }
+
+func TestServeRangeProxy(t *testing.T) {
+	media := bytes.Repeat([]byte("0123456789"), 100)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Now(), bytes.NewReader(media))
+	}))
+	defer origin.Close()
+
+	src, err := urlstreamer.ProbeURL(context.Background(), origin.URL)
+	if err != nil {
+		t.Fatalf("ProbeURL: %s", err)
+	}
+
+	tt := []struct {
+		rangeHeader  string
+		wantStatus   int
+		wantRange    string
+		wantFeatures string
+		name         string
+	}{
+		{"", http.StatusOK, "", "DLNA.ORG_OP=01", `Full request`},
+		{"bytes=100-199", http.StatusPartialContent, "bytes 100-199/1000", "DLNA.ORG_OP=01", `Range request`},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Add("getcontentFeatures.dlna.org", "1")
+		if tc.rangeHeader != "" {
+			r.Header.Set("Range", tc.rangeHeader)
+		}
+
+		serveContent(w, r, nil, src, true)
+
+		res := w.Result()
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s: got: %s, want: %d.", tc.name, res.Status, tc.wantStatus)
+		}
+
+		if got := res.Header.Get("Content-Range"); got != tc.wantRange {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.wantRange)
+		}
+
+		if got := strings.Join(res.Header["contentFeatures.dlna.org"], ""); !strings.Contains(got, tc.wantFeatures) {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.wantFeatures)
+		}
+	}
+}
+
+func TestServeBufferedStream(t *testing.T) {
+	media := bytes.Repeat([]byte("0123456789"), 100)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(media)
+	}))
+	defer origin.Close()
+
+	src, err := urlstreamer.BufferURL(context.Background(), origin.URL, 0)
+	if err != nil {
+		t.Fatalf("BufferURL: %s", err)
+	}
+	defer src.Close()
+
+	// Renderers re-request the media and probe its end.
+	tt := []struct {
+		rangeHeader string
+		wantStatus  int
+		wantBody    string
+		name        string
+	}{
+		{"bytes=990-", http.StatusPartialContent, "0123456789", `End of media`},
+		{"", http.StatusOK, string(media), `Full request`},
+		{"", http.StatusOK, string(media), `Repeated request`},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.rangeHeader != "" {
+			r.Header.Set("Range", tc.rangeHeader)
+		}
+
+		serveContent(w, r, nil, src, true)
+
+		res := w.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != tc.wantStatus || string(body) != tc.wantBody {
+			t.Errorf("%s: got: %s with %d bytes, want: %d with %d bytes.", tc.name, res.Status, len(body), tc.wantStatus, len(tc.wantBody))
+		}
+	}
+}
+
+func TestServeTimeSeek(t *testing.T) {
+	// A MPEG-TS file with a PCR every 10ms, 10 seconds long.
+	var ts bytes.Buffer
+	for i := int64(0); i <= 1000; i++ {
+		p := make([]byte, 188)
+		p[0], p[1], p[3], p[4], p[5] = 0x47, 0x01, 0x30, 7, 0x10
+		pcr := i * 900
+		p[6], p[7], p[8], p[9], p[10] = byte(pcr>>25), byte(pcr>>17), byte(pcr>>9), byte(pcr>>1), byte(pcr<<7)
+		ts.Write(p)
+	}
+
+	dir := t.TempDir()
+	tsFile := filepath.Join(dir, "video.ts")
+	txtFile := filepath.Join(dir, "video.txt")
+	if err := os.WriteFile(tsFile, ts.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(txtFile, []byte("not a video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		input      string
+		timeSeek   string
+		wantStatus int
+		wantRange  string
+		wantLength int
+		name       string
+	}{
+		{tsFile, "npt=5.0-", http.StatusOK, "npt=0:00:05.000-0:00:10.000/0:00:10.000 bytes=94000-188187/188188", 188188 - 94000, `MPEG-TS`},
+		{tsFile, "npt=20.0-", http.StatusNotAcceptable, "", -1, `Past the end`},
+		{txtFile, "npt=5.0-", http.StatusNotAcceptable, "", -1, `Unsupported media`},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("TimeSeekRange.dlna.org", tc.timeSeek)
+
+		serveContent(w, r, nil, tc.input, true)
+
+		res := w.Result()
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s: got: %s, want: %d.", tc.name, res.Status, tc.wantStatus)
+			continue
+		}
+
+		if got := strings.Join(res.Header["TimeSeekRange.dlna.org"], ""); got != tc.wantRange {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.wantRange)
+		}
+
+		if body, _ := io.ReadAll(res.Body); tc.wantLength >= 0 && len(body) != tc.wantLength {
+			t.Errorf("%s: got: %d bytes, want: %d bytes.", tc.name, len(body), tc.wantLength)
+		}
+	}
+}
+
+func TestServeDLNAProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(file, []byte("not really a video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := "DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+	tt := []struct {
+		name  string
+		input *soapcalls.TVPayload
+		want  string
+	}{
+		{
+			`Probed profile`,
+			&soapcalls.TVPayload{MediaType: "video/mp4", DLNAProfile: "AVC_MP4_HP_HD_AAC"},
+			"DLNA.ORG_PN=AVC_MP4_HP_HD_AAC;DLNA.ORG_OP=01;" + flags,
+		},
+		{
+			`No profile`,
+			&soapcalls.TVPayload{MediaType: "video/mp4"},
+			"DLNA.ORG_OP=01;" + flags,
+		},
+		{
+			`MediaType profile`,
+			&soapcalls.TVPayload{MediaType: "audio/mpeg"},
+			"DLNA.ORG_PN=MP3;DLNA.ORG_OP=01;" + flags,
+		},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodHead, "/", nil)
+		r.Header.Set("getcontentFeatures.dlna.org", "1")
+
+		serveContent(w, r, tc.input, file, true)
+
+		if got := strings.Join(w.Result().Header["contentFeatures.dlna.org"], ""); got != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestServePhoto(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, image.NewRGBA(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := imageconv.ProfileByName("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	photo := imageconv.NewBytes(src.Bytes(), p)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("getcontentFeatures.dlna.org", "1")
+
+	serveContent(w, r, &soapcalls.TVPayload{MediaType: "image/png"}, photo, true)
+
+	res := w.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got: %s.", res.Status)
+	}
+
+	if got := res.Header.Get("Content-Type"); got != imageconv.MediaType {
+		t.Errorf("Content-Type: got: %s, want: %s.", got, imageconv.MediaType)
+	}
+
+	want := "DLNA.ORG_PN=JPEG_LRG;DLNA.ORG_OP=01;DLNA.ORG_CI=1;"
+	if got := strings.Join(res.Header["contentFeatures.dlna.org"], ""); !strings.HasPrefix(got, want) {
+		t.Errorf("contentFeatures: got: %s, want: %s.", got, want)
+	}
+
+	if _, err := jpeg.Decode(res.Body); err != nil {
+		t.Errorf("Body: not a JPEG image: %s", err)
+	}
+}
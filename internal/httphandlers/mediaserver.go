@@ -0,0 +1,781 @@
+package httphandlers
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/mediaprobe"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+const (
+	// MediaServerDeviceType .
+	MediaServerDeviceType = "urn:schemas-upnp-org:device:MediaServer:1"
+	// ContentDirectoryServiceType .
+	ContentDirectoryServiceType = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	// ConnectionManagerServiceType .
+	ConnectionManagerServiceType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+
+	descriptionPath = "/description.xml"
+	mediaPath       = "/media/"
+)
+
+// MediaServer - A UPnP/DLNA Media Server that
+// shares a local folder tree.
+type MediaServer struct {
+	http *http.Server
+	mux  *http.ServeMux
+	root string
+	// realRoot - The root folder with its symlinks resolved.
+	realRoot string
+	name     string
+	uuid     string
+	baseURL  string
+	rate     utils.StreamRate
+
+	// The media types and the DLNA profiles of the shared
+	// files, so that we don't sniff and probe every file on
+	// every Browse request.
+	detailsMu  sync.Mutex
+	mediaTypes map[fileKey]string
+	profiles   map[fileKey]string
+}
+
+type fileKey struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// The file details caches are cleared when they get that big.
+const maxCachedDetails = 4096
+
+// NewMediaServer - Create a Media Server for the root folder. The
+// server listens to the listen address and advertises the URLs
+// using the advertise host:port pair.
+func NewMediaServer(root, name, listen, advertise string) (*MediaServer, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("NewMediaServer root error: %w", err)
+	}
+
+	info, err := os.Stat(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("NewMediaServer root error: %w", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("NewMediaServer root error: %s is not a directory", absRoot)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("NewMediaServer root error: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	if name == "" {
+		name = "Go2TV (" + hostname + ")"
+	}
+
+	mux := http.NewServeMux()
+	s := &MediaServer{
+		http:       &http.Server{Addr: listen, Handler: mux},
+		mux:        mux,
+		root:       absRoot,
+		realRoot:   realRoot,
+		mediaTypes: make(map[fileKey]string),
+		profiles:   make(map[fileKey]string),
+		name:       name,
+		uuid:       stableUUID(hostname + absRoot),
+		baseURL:    strings.TrimSuffix(utils.BuildHTTPURL(advertise, ""), "/"),
+	}
+
+	mux.HandleFunc(descriptionPath, s.descriptionHandler)
+	mux.HandleFunc("/ContentDirectory.xml", scpdHandler(contentDirectorySCPD))
+	mux.HandleFunc("/ConnectionManager.xml", scpdHandler(connectionManagerSCPD))
+	mux.HandleFunc("/ContentDirectory/control", s.contentDirectoryHandler)
+	mux.HandleFunc("/ConnectionManager/control", s.connectionManagerHandler)
+	mux.HandleFunc("/ContentDirectory/event", eventSubHandler)
+	mux.HandleFunc("/ConnectionManager/event", eventSubHandler)
+	mux.HandleFunc(mediaPath, s.mediaHandler)
+
+	return s, nil
+}
+
+// DescriptionURL - The device description URL
+// we advertise via SSDP.
+func (s *MediaServer) DescriptionURL() string {
+	return s.baseURL + descriptionPath
+}
+
+// UUID - The unique device identifier.
+func (s *MediaServer) UUID() string {
+	return s.uuid
+}
+
+// LimitRate - Limit the rate that we serve the media at.
+func (s *MediaServer) LimitRate(r utils.StreamRate) {
+	s.rate = r
+}
+
+// TrackRequests - Record the requests that we serve
+// to st. Call it before starting the server.
+func (s *MediaServer) TrackRequests(st *RequestStats) {
+	s.http.Handler = st.track(s.mux)
+}
+
+// Serve - Start the Media Server.
+func (s *MediaServer) Serve(serverStarted chan<- struct{}) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("MediaServer listen error: %w", err)
+	}
+
+	serverStarted <- struct{}{}
+	s.http.Serve(ln)
+
+	return nil
+}
+
+// Stop .
+func (s *MediaServer) Stop() {
+	s.http.Close()
+}
+
+// Shutdown - Stop the Media Server gracefully. We wait for the
+// in-flight responses until ctx is done, and then we close them.
+func (s *MediaServer) Shutdown(ctx context.Context) error {
+	if err := s.http.Shutdown(ctx); err != nil {
+		if err := s.http.Close(); err != nil {
+			return fmt.Errorf("MediaServer shutdown error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *MediaServer) descriptionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(s.name))
+
+	fmt.Fprintf(w, deviceDescription, MediaServerDeviceType, name.String(), s.uuid,
+		ContentDirectoryServiceType, ConnectionManagerServiceType)
+}
+
+func (s *MediaServer) contentDirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	switch soapAction(r) {
+	case "Browse":
+		s.browse(w, r)
+	case "GetSystemUpdateID":
+		soapResponse(w, ContentDirectoryServiceType, "GetSystemUpdateID", [][2]string{{"Id", "1"}})
+	case "GetSearchCapabilities":
+		soapResponse(w, ContentDirectoryServiceType, "GetSearchCapabilities", [][2]string{{"SearchCaps", ""}})
+	case "GetSortCapabilities":
+		soapResponse(w, ContentDirectoryServiceType, "GetSortCapabilities", [][2]string{{"SortCaps", ""}})
+	default:
+		soapFault(w, 401, "Invalid Action")
+	}
+}
+
+func (s *MediaServer) connectionManagerHandler(w http.ResponseWriter, r *http.Request) {
+	switch soapAction(r) {
+	case "GetProtocolInfo":
+		soapResponse(w, ConnectionManagerServiceType, "GetProtocolInfo", [][2]string{
+			{"Source", sourceProtocolInfo()},
+			{"Sink", ""},
+		})
+	case "GetCurrentConnectionIDs":
+		soapResponse(w, ConnectionManagerServiceType, "GetCurrentConnectionIDs", [][2]string{{"ConnectionIDs", "0"}})
+	case "GetCurrentConnectionInfo":
+		soapResponse(w, ConnectionManagerServiceType, "GetCurrentConnectionInfo", [][2]string{
+			{"RcsID", "-1"},
+			{"AVTransportID", "-1"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Output"},
+			{"Status", "OK"},
+		})
+	default:
+		soapFault(w, 401, "Invalid Action")
+	}
+}
+
+type browseRequest struct {
+	Body struct {
+		Browse struct {
+			ObjectID       string
+			BrowseFlag     string
+			StartingIndex  int
+			RequestedCount int
+		} `xml:"Browse"`
+	} `xml:"Body"`
+}
+
+func (s *MediaServer) browse(w http.ResponseWriter, r *http.Request) {
+	var req browseRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		soapFault(w, 402, "Invalid Args")
+		return
+	}
+
+	b := req.Body.Browse
+
+	p, ok := s.objectPath(b.ObjectID)
+	if !ok || !s.contained(p) {
+		soapFault(w, 701, "No such object")
+		return
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		soapFault(w, 701, "No such object")
+		return
+	}
+
+	var objects []string
+	switch b.BrowseFlag {
+	case "BrowseMetadata":
+		objects = []string{b.ObjectID}
+	case "BrowseDirectChildren":
+		if !info.IsDir() {
+			soapFault(w, 710, "No such container")
+			return
+		}
+		objects = s.children(b.ObjectID)
+	default:
+		soapFault(w, 402, "Invalid Args")
+		return
+	}
+
+	total := len(objects)
+
+	start := b.StartingIndex
+	if start < 0 || start > total {
+		start = total
+	}
+
+	end := total
+	if b.RequestedCount > 0 && start+b.RequestedCount < total {
+		end = start + b.RequestedCount
+	}
+
+	var didl strings.Builder
+	didl.WriteString(didlLiteHeader)
+	for _, id := range objects[start:end] {
+		s.writeDIDLObject(&didl, id)
+	}
+	didl.WriteString(`</DIDL-Lite>`)
+
+	soapResponse(w, ContentDirectoryServiceType, "Browse", [][2]string{
+		{"Result", didl.String()},
+		{"NumberReturned", strconv.Itoa(end - start)},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", "1"},
+	})
+}
+
+// objectPath converts the object ID to the local path. The root
+// container has the "0" ID, and every other object has its slash
+// separated path, relative to the root, as the ID. We make sure
+// that the clients can't escape the root folder, or reach the
+// hidden files and folders.
+func (s *MediaServer) objectPath(id string) (string, bool) {
+	if id == "0" {
+		return s.root, true
+	}
+
+	if id == "" || strings.Contains(id, "\\") {
+		return "", false
+	}
+
+	clean := path.Clean("/" + id)
+	if clean == "/" || clean[1:] != id {
+		return "", false
+	}
+
+	for _, segment := range strings.Split(id, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return "", false
+		}
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(id)), true
+}
+
+// contained reports whether the path, with its symlinks
+// resolved, is still inside the root folder.
+func (s *MediaServer) contained(p string) bool {
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(s.realRoot, real)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// children returns the IDs of the folders and the media files
+// of the container. Folders come first. Hidden files are skipped.
+func (s *MediaServer) children(id string) []string {
+	p, _ := s.objectPath(id)
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil
+	}
+
+	prefix := ""
+	if id != "0" {
+		prefix = id + "/"
+	}
+
+	dirs := make([]string, 0)
+	files := make([]string, 0)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		// The container is inside the root folder, so only
+		// the symlinks in it can lead us outside of it.
+		entryPath := filepath.Join(p, e.Name())
+		if e.Type()&os.ModeSymlink != 0 && !s.contained(entryPath) {
+			continue
+		}
+
+		info, err := os.Stat(entryPath)
+		if err != nil {
+			continue
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, prefix+e.Name())
+			continue
+		}
+
+		if mediaClass(s.mediaType(entryPath, info)) == "" {
+			continue
+		}
+
+		files = append(files, prefix+e.Name())
+	}
+
+	return append(dirs, files...)
+}
+
+func (s *MediaServer) writeDIDLObject(b *strings.Builder, id string) {
+	p, _ := s.objectPath(id)
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+
+	parentID := "-1"
+	title := s.name
+	if id != "0" {
+		parentID = path.Dir(id)
+		if parentID == "." {
+			parentID = "0"
+		}
+		title = path.Base(id)
+	}
+
+	if info.IsDir() {
+		b.WriteString(`<container id="`)
+		xml.EscapeText(b, []byte(id))
+		b.WriteString(`" parentID="`)
+		xml.EscapeText(b, []byte(parentID))
+		fmt.Fprintf(b, `" restricted="1" childCount="%d"><dc:title>`, len(s.children(id)))
+		xml.EscapeText(b, []byte(title))
+		b.WriteString(`</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>`)
+		return
+	}
+
+	mediaType := s.mediaType(p, info)
+
+	b.WriteString(`<item id="`)
+	xml.EscapeText(b, []byte(id))
+	b.WriteString(`" parentID="`)
+	xml.EscapeText(b, []byte(parentID))
+	b.WriteString(`" restricted="1"><dc:title>`)
+	xml.EscapeText(b, []byte(strings.TrimSuffix(title, filepath.Ext(title))))
+	b.WriteString(`</dc:title><upnp:class>`)
+	b.WriteString(mediaClass(mediaType))
+	b.WriteString(`</upnp:class><res protocolInfo="`)
+	xml.EscapeText(b, []byte(protocolInfo(mediaType, s.dlnaProfile(p, info))))
+	fmt.Fprintf(b, `" size="%d">`, info.Size())
+	xml.EscapeText(b, []byte(s.mediaURL(id)))
+	b.WriteString(`</res></item>`)
+}
+
+// mediaType returns the media type of the file. We only
+// sniff the files again when they get modified.
+func (s *MediaServer) mediaType(p string, info os.FileInfo) string {
+	return s.fileDetail(&s.mediaTypes, p, info, mediaTypeOf)
+}
+
+// dlnaProfile returns the DLNA profile of the file. We only
+// probe the files again when they get modified.
+func (s *MediaServer) dlnaProfile(p string, info os.FileInfo) string {
+	return s.fileDetail(&s.profiles, p, info, mediaprobe.DLNAProfile)
+}
+
+func (s *MediaServer) fileDetail(cache *map[fileKey]string, p string, info os.FileInfo, load func(string) string) string {
+	key := fileKey{path: p, modTime: info.ModTime(), size: info.Size()}
+
+	s.detailsMu.Lock()
+	detail, ok := (*cache)[key]
+	s.detailsMu.Unlock()
+
+	if ok {
+		return detail
+	}
+
+	detail = load(p)
+
+	s.detailsMu.Lock()
+	if len(*cache) >= maxCachedDetails {
+		*cache = make(map[fileKey]string)
+	}
+	(*cache)[key] = detail
+	s.detailsMu.Unlock()
+
+	return detail
+}
+
+func (s *MediaServer) mediaURL(id string) string {
+	segments := strings.Split(id, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return s.baseURL + mediaPath + strings.Join(segments, "/")
+}
+
+func (s *MediaServer) mediaHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, mediaPath)
+
+	p, ok := s.objectPath(id)
+	if !ok || id == "0" || !s.contained(p) {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := os.Stat(p)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// We only share the media files, as the Browse
+	// listings do.
+	mediaType := s.mediaType(p, info)
+	if mediaClass(mediaType) == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	tv := &soapcalls.TVPayload{MediaType: mediaType}
+
+	// We only need the profile for the content features.
+	if r.Header.Get("getcontentFeatures.dlna.org") == "1" {
+		tv.DLNAProfile = s.dlnaProfile(p, info)
+	}
+
+	serveContent(pace(w, r, mediaRate(s.rate, p)), r, tv, p, true)
+}
+
+func mediaTypeOf(p string) string {
+	mediaType, err := utils.GetMimeDetailsFromFile(p)
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
+func mediaClass(mediaType string) string {
+	switch {
+	case strings.HasPrefix(mediaType, "video/"):
+		return "object.item.videoItem"
+	case strings.HasPrefix(mediaType, "audio/"):
+		return "object.item.audioItem.musicTrack"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "object.item.imageItem.photo"
+	}
+
+	return ""
+}
+
+// protocolInfo returns the protocolInfo of the res element. We
+// use the same DLNA profile logic as we do for the casting.
+func protocolInfo(mediaType, dlnaProfile string) string {
+	contentFeatures, err := utils.BuildContentFeatures(utils.ContentFeatures{
+		MediaType:   mediaType,
+		DLNAProfile: dlnaProfile,
+		Seek:        "01",
+	})
+	if err != nil {
+		contentFeatures = "*"
+	}
+
+	return "http-get:*:" + mediaType + ":" + contentFeatures
+}
+
+// sourceProtocolInfo lists the protocols we can serve.
+func sourceProtocolInfo() string {
+	types := []string{
+		"video/mp4", "video/x-matroska", "video/x-msvideo", "video/mpeg",
+		"video/quicktime", "video/webm", "video/mp2t", "audio/mpeg",
+		"audio/mp4", "audio/aac", "audio/x-flac", "audio/x-wav", "audio/ogg",
+		"image/jpeg", "image/png", "image/gif",
+	}
+
+	out := make([]string, 0, len(types))
+	for _, t := range types {
+		out = append(out, protocolInfo(t, ""))
+	}
+
+	return strings.Join(out, ",")
+}
+
+func soapAction(r *http.Request) string {
+	a := strings.Trim(r.Header.Get("SOAPAction"), `"`)
+	if i := strings.LastIndex(a, "#"); i >= 0 {
+		return a[i+1:]
+	}
+
+	return a
+}
+
+func soapResponse(w http.ResponseWriter, serviceType, action string, args [][2]string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+
+	var b strings.Builder
+	b.WriteString(soapEnvelopeStart)
+	fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, serviceType)
+	for _, a := range args {
+		b.WriteString("<" + a[0] + ">")
+		xml.EscapeText(&b, []byte(a[1]))
+		b.WriteString("</" + a[0] + ">")
+	}
+	fmt.Fprintf(&b, `</u:%sResponse>`, action)
+	b.WriteString(soapEnvelopeEnd)
+
+	io.WriteString(w, b.String())
+}
+
+func soapFault(w http.ResponseWriter, code int, desc string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, soapEnvelopeStart+soapFaultBody+soapEnvelopeEnd, code, desc)
+}
+
+func scpdHandler(scpd string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		io.WriteString(w, scpd)
+	}
+}
+
+// eventSubHandler accepts the event subscriptions. We don't
+// send any events, but some Media Renderers refuse to use
+// Media Servers that reject the subscriptions.
+func eventSubHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "SUBSCRIBE":
+		sid := r.Header.Get("SID")
+		if sid == "" {
+			id, err := utils.RandomString()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			sid = "uuid:" + id
+		}
+
+		w.Header()["SID"] = []string{sid}
+		w.Header()["TIMEOUT"] = []string{"Second-1800"}
+		w.WriteHeader(http.StatusOK)
+	case "UNSUBSCRIBE":
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// stableUUID derives the device UUID from the seed, so that the
+// Media Renderers see the same device across restarts.
+func stableUUID(seed string) string {
+	h := md5.Sum([]byte(seed))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+const didlLiteHeader = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" ` +
+	`xmlns:dc="http://purl.org/dc/elements/1.1/" ` +
+	`xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" ` +
+	`xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`
+
+const soapEnvelopeStart = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+	`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`
+
+const soapEnvelopeEnd = `</s:Body></s:Envelope>`
+
+const soapFaultBody = `<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>` +
+	`<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0">` +
+	`<errorCode>%d</errorCode><errorDescription>%s</errorDescription>` +
+	`</UPnPError></detail></s:Fault>`
+
+const deviceDescription = `<?xml version="1.0" encoding="utf-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>%s</deviceType>
+    <friendlyName>%s</friendlyName>
+    <manufacturer>Go2TV</manufacturer>
+    <manufacturerURL>https://github.com/alexballas/go2tv</manufacturerURL>
+    <modelName>Go2TV Media Server</modelName>
+    <UDN>uuid:%s</UDN>
+    <dlna:X_DLNADOC>DMS-1.50</dlna:X_DLNADOC>
+    <serviceList>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ContentDirectory</serviceId>
+        <SCPDURL>/ContentDirectory.xml</SCPDURL>
+        <controlURL>/ContentDirectory/control</controlURL>
+        <eventSubURL>/ContentDirectory/event</eventSubURL>
+      </service>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ConnectionManager</serviceId>
+        <SCPDURL>/ConnectionManager.xml</SCPDURL>
+        <controlURL>/ConnectionManager/control</controlURL>
+        <eventSubURL>/ConnectionManager/event</eventSubURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`
+
+const contentDirectorySCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>Browse</name>
+      <argumentList>
+        <argument><name>ObjectID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+        <argument><name>BrowseFlag</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_BrowseFlag</relatedStateVariable></argument>
+        <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+        <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+        <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+        <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+        <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSystemUpdateID</name>
+      <argumentList>
+        <argument><name>Id</name><direction>out</direction><relatedStateVariable>SystemUpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSearchCapabilities</name>
+      <argumentList>
+        <argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSortCapabilities</name>
+      <argumentList>
+        <argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_BrowseFlag</name><dataType>string</dataType>
+      <allowedValueList><allowedValue>BrowseMetadata</allowedValue><allowedValue>BrowseDirectChildren</allowedValue></allowedValueList>
+    </stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Filter</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>`
+
+const connectionManagerSCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>GetProtocolInfo</name>
+      <argumentList>
+        <argument><name>Source</name><direction>out</direction><relatedStateVariable>SourceProtocolInfo</relatedStateVariable></argument>
+        <argument><name>Sink</name><direction>out</direction><relatedStateVariable>SinkProtocolInfo</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetCurrentConnectionIDs</name>
+      <argumentList>
+        <argument><name>ConnectionIDs</name><direction>out</direction><relatedStateVariable>CurrentConnectionIDs</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetCurrentConnectionInfo</name>
+      <argumentList>
+        <argument><name>ConnectionID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ConnectionID</relatedStateVariable></argument>
+        <argument><name>RcsID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_RcsID</relatedStateVariable></argument>
+        <argument><name>AVTransportID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_AVTransportID</relatedStateVariable></argument>
+        <argument><name>ProtocolInfo</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ProtocolInfo</relatedStateVariable></argument>
+        <argument><name>PeerConnectionManager</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionManager</relatedStateVariable></argument>
+        <argument><name>PeerConnectionID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionID</relatedStateVariable></argument>
+        <argument><name>Direction</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Direction</relatedStateVariable></argument>
+        <argument><name>Status</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionStatus</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="yes"><name>SourceProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SinkProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>CurrentConnectionIDs</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionStatus</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionManager</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Direction</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionID</name><dataType>i4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_AVTransportID</name><dataType>i4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_RcsID</name><dataType>i4</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>`
@@ -0,0 +1,180 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+var mp4Header = []byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2',
+	0, 0, 0, 0, 'm', 'p', '4', '2', 'i', 's', 'o', 'm'}
+
+func newTestMediaServer(t *testing.T) (*MediaServer, *httptest.Server) {
+	root := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(root, "Movies"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, ".hidden"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"Movies/My Clip.mp4": mp4Header,
+		"notes.txt":          []byte("not a media file"),
+		".hidden/clip.mp4":   mp4Header,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewMediaServer(root, "Test Server", "127.0.0.1:0", "127.0.0.1:3500")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, httptest.NewServer(s.mux)
+}
+
+func TestMediaServerBrowse(t *testing.T) {
+	s, ts := newTestMediaServer(t)
+	defer ts.Close()
+
+	cds, err := soapcalls.CDSextractor(ts.URL + descriptionPath)
+	if err != nil {
+		t.Fatalf("CDSextractor: %s", err)
+	}
+
+	root, err := soapcalls.BrowseSoapCall(cds.ContentDirectoryControlURL, "0")
+	if err != nil {
+		t.Fatalf("Browse root: %s", err)
+	}
+
+	if len(root) != 1 || !root[0].IsContainer || root[0].Title != "Movies" {
+		t.Fatalf("Browse root: got: %+v, want: the Movies container only.", root)
+	}
+
+	movies, err := soapcalls.BrowseSoapCall(cds.ContentDirectoryControlURL, root[0].ID)
+	if err != nil {
+		t.Fatalf("Browse Movies: %s", err)
+	}
+
+	if len(movies) != 1 {
+		t.Fatalf("Browse Movies: got: %d entries, want: 1.", len(movies))
+	}
+
+	res, err := movies[0].PlayableRes()
+	if err != nil {
+		t.Fatalf("PlayableRes: %s", err)
+	}
+
+	if movies[0].Title != "My Clip" {
+		t.Errorf("Item title: got: %s, want: %s.", movies[0].Title, "My Clip")
+	}
+
+	if res.MediaType() != "video/mp4" {
+		t.Errorf("Item media type: got: %s, want: %s.", res.MediaType(), "video/mp4")
+	}
+
+	want := "http://127.0.0.1:3500/media/Movies/My%20Clip.mp4"
+	if res.URL != want {
+		t.Errorf("Item URL: got: %s, want: %s.", res.URL, want)
+	}
+
+	u, _ := url.Parse(res.URL)
+	resp, err := http.Get(ts.URL + u.EscapedPath())
+	if err != nil {
+		t.Fatalf("GET media: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET media: got: %s, want: %d.", resp.Status, http.StatusOK)
+	}
+
+	// The Browse requests sniff each file once.
+	clip := filepath.Join(s.root, "Movies", "My Clip.mp4")
+	cached := 0
+	for k, v := range s.mediaTypes {
+		if k.path == clip && v == "video/mp4" {
+			cached++
+		}
+	}
+
+	if cached != 1 {
+		t.Errorf("Cached media types: got: %d, want: 1.", cached)
+	}
+}
+
+func TestMediaServerObjectPath(t *testing.T) {
+	s := &MediaServer{root: "/srv/media"}
+
+	tt := []struct {
+		input string
+		ok    bool
+		name  string
+	}{
+		{"0", true, "Root container"},
+		{"Movies/clip.mp4", true, "Nested item"},
+		{"../etc/passwd", false, "Parent traversal"},
+		{"Movies/../../etc", false, "Nested traversal"},
+		{"/etc/passwd", false, "Absolute path"},
+		{".ssh/id_rsa", false, "Hidden folder"},
+		{"Movies/.clip.mp4", false, "Hidden file"},
+		{"", false, "Empty ID"},
+	}
+
+	for _, tc := range tt {
+		_, ok := s.objectPath(tc.input)
+		if ok != tc.ok {
+			t.Errorf("%s: got: %t, want: %t.", tc.name, ok, tc.ok)
+		}
+	}
+}
+
+func TestMediaServerMedia(t *testing.T) {
+	s, ts := newTestMediaServer(t)
+	defer ts.Close()
+
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "clip.mp4"), mp4Header, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "clip.mp4"), filepath.Join(s.root, "Movies", "link.mp4")); err != nil {
+		t.Skipf("no symlinks: %s", err)
+	}
+
+	tt := []struct {
+		path string
+		want int
+		name string
+	}{
+		{"Movies/My%20Clip.mp4", http.StatusOK, "Media file"},
+		{".hidden/clip.mp4", http.StatusNotFound, "Hidden file"},
+		{"notes.txt", http.StatusNotFound, "Text file"},
+		{"Movies/link.mp4", http.StatusNotFound, "Symlink outside the root"},
+	}
+
+	for _, tc := range tt {
+		resp, err := http.Get(ts.URL + mediaPath + tc.path)
+		if err != nil {
+			t.Errorf("%s: GET error: %s", tc.name, err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+}
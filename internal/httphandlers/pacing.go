@@ -0,0 +1,123 @@
+package httphandlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/timeseek"
+	"github.com/alexballas/go2tv/internal/utils"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// We pace the media a bit faster than its bitrate,
+	// so that the Media Renderer buffer never runs dry.
+	pacedHeadroom = 1.5
+	// The Media Renderers can fill their buffers at full
+	// speed for the first seconds of each response.
+	pacedBurst = 2 * time.Second
+	// We never wait for less than that.
+	minPacedChunk = 32 * 1024
+)
+
+// pacedWriter - Write the media responses at a limited rate. It
+// purposely hides the io.ReaderFrom of the http.ResponseWriter,
+// so that io.Copy goes through Write.
+type pacedWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+// pace limits the rate of the media responses. The limit
+// is in bytes per second and zero means no limit.
+func pace(w http.ResponseWriter, r *http.Request, limit float64) http.ResponseWriter {
+	if limit <= 0 {
+		return w
+	}
+
+	burst := int(limit * pacedBurst.Seconds())
+	if burst < minPacedChunk {
+		burst = minPacedChunk
+	}
+
+	return &pacedWriter{
+		ResponseWriter: w,
+		ctx:            r.Context(),
+		limiter:        rate.NewLimiter(rate.Limit(limit), burst),
+	}
+}
+
+func (p *pacedWriter) Write(b []byte) (int, error) {
+	var written int
+
+	for len(b) > 0 {
+		n := len(b)
+		if n > p.limiter.Burst() {
+			n = p.limiter.Burst()
+		}
+
+		// We stop waiting when the Media Renderer drops the connection.
+		if err := p.limiter.WaitN(p.ctx, n); err != nil {
+			return written, err
+		}
+
+		m, err := p.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+
+		b = b[n:]
+	}
+
+	return written, nil
+}
+
+func isPaced(w http.ResponseWriter) bool {
+	_, ok := w.(*pacedWriter)
+	return ok
+}
+
+// mediaRate returns the rate, in bytes per second, that we serve
+// the media at. We can only pace the local files with a known
+// duration to their bitrate. The cap applies to all the media.
+func mediaRate(sr utils.StreamRate, media interface{}) float64 {
+	var limit float64
+
+	if sr.Paced {
+		if bitrate := mediaBitrate(media); bitrate > 0 {
+			limit = bitrate * pacedHeadroom
+		}
+	}
+
+	if c := float64(sr.Cap); c > 0 && (limit == 0 || c < limit) {
+		limit = c
+	}
+
+	return limit
+}
+
+// mediaBitrate returns the average bitrate of the
+// media in bytes per second, or zero if we don't know it.
+func mediaBitrate(media interface{}) float64 {
+	f, ok := media.(string)
+	if !ok {
+		return 0
+	}
+
+	fileStat, err := os.Stat(f)
+	if err != nil {
+		return 0
+	}
+
+	// We get the cached index, as we pace every request.
+	idx, err := timeseek.OpenFile(f)
+	if err != nil || idx.Duration() <= 0 {
+		return 0
+	}
+
+	return float64(fileStat.Size()) / idx.Duration().Seconds()
+}
@@ -0,0 +1,60 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+func TestMediaRate(t *testing.T) {
+	tt := []struct {
+		rate utils.StreamRate
+		want float64
+		name string
+	}{
+		{utils.StreamRate{}, 0, `No limit`},
+		{utils.StreamRate{Cap: 1000}, 1000, `Fixed cap`},
+		{utils.StreamRate{Paced: true}, 0, `Unknown bitrate`},
+		{utils.StreamRate{Cap: 1000, Paced: true}, 1000, `Unknown bitrate with cap`},
+	}
+
+	for _, tc := range tt {
+		if got := mediaRate(tc.rate, []byte("media")); got != tc.want {
+			t.Errorf("%s: got: %f, want: %f.", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestServePaced(t *testing.T) {
+	// The first minPacedChunk bytes are served at full speed,
+	// the rest at minPacedChunk/2 bytes per second.
+	media := bytes.Repeat([]byte("0123456789abcdef"), (minPacedChunk+minPacedChunk/4)/16)
+	tv := &soapcalls.TVPayload{MediaType: "video/mp4"}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	r.Header.Add("getcontentFeatures.dlna.org", "1")
+
+	start := time.Now()
+	serveContent(pace(w, r, minPacedChunk/2), r, tv, media, true)
+	elapsed := time.Since(start)
+
+	if !bytes.Equal(w.Body.Bytes(), media) {
+		t.Fatalf("Paced body: got %d bytes, want: %d bytes.", w.Body.Len(), len(media))
+	}
+
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("Paced duration: got: %s, want at least: %s.", elapsed, 400*time.Millisecond)
+	}
+
+	flags := w.Result().Header["contentFeatures.dlna.org"]
+	if len(flags) == 0 || !strings.HasSuffix(flags[0], "DLNA.ORG_FLAGS=81700000000000000000000000000000") {
+		t.Errorf("Paced content features: got: %v, want the sender paced flag.", flags)
+	}
+}
@@ -0,0 +1,174 @@
+package httphandlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+// sessionItem - The paths that we serve for a casting session item,
+// i.e. its media, subtitles, artwork and callback, and the resources
+// that we release when we stop serving it.
+type sessionItem struct {
+	policy  *accessPolicy
+	paths   []string
+	closers []io.Closer
+}
+
+// AddItem - Start serving the media and the subtitles of an item on
+// the session server, along with the callback of its Media Renderer.
+// Items can be added before or after the server starts.
+func (s *HTTPserver) AddItem(tvpayload *soapcalls.TVPayload, media, subtitles interface{}, screen Screen) error {
+	mURL, err := url.Parse(tvpayload.MediaURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse MediaURL: %w", err)
+	}
+
+	sURL, err := url.Parse(tvpayload.SubtitlesURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse SubtitlesURL: %w", err)
+	}
+
+	callbackURL, err := url.Parse(tvpayload.CallbackURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse CallbackURL: %w", err)
+	}
+
+	policy, err := newAccessPolicy(tvpayload.ControlURL, s.allowedHosts)
+	if err != nil {
+		return fmt.Errorf("failed to set up the access policy: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.items[tvpayload]; exists {
+		return errors.New("addItem: item already served")
+	}
+
+	item := &sessionItem{policy: policy}
+
+	// The media and subtitles are nil when the Media Renderer
+	// fetches them from elsewhere, e.g. from a Media Server.
+	// We still need the callback handler.
+	if media != nil {
+		if err := s.route(item, mURL.Path, policy.restrict(s.serveMediaHandler(tvpayload, media))); err != nil {
+			return fmt.Errorf("AddItem media error: %w", err)
+		}
+	}
+
+	if subtitles != nil && sURL.Path != "" {
+		if err := s.route(item, sURL.Path, policy.restrict(s.serveSubtitlesHandler(subtitles))); err != nil {
+			s.unroute(item)
+			return fmt.Errorf("AddItem subtitles error: %w", err)
+		}
+	}
+
+	if err := s.route(item, callbackURL.Path, s.callbackHandler(tvpayload, screen, policy)); err != nil {
+		s.unroute(item)
+		return fmt.Errorf("AddItem callback error: %w", err)
+	}
+
+	// The URL streams, and their disk buffers, get
+	// closed when we stop serving the item.
+	if c, ok := media.(io.Closer); ok {
+		item.closers = append(item.closers, c)
+	}
+
+	s.items[tvpayload] = item
+
+	return nil
+}
+
+// AddArtwork - Serve an extra file of an item, e.g. its album art,
+// on the given URL path. Only the hosts that can fetch the media of
+// the item can fetch it.
+func (s *HTTPserver) AddArtwork(tvpayload *soapcalls.TVPayload, path string, artwork interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.items[tvpayload]
+	if !exists {
+		return errors.New("addArtwork: unknown item")
+	}
+
+	if err := s.route(item, path, item.policy.restrict(s.serveSubtitlesHandler(artwork))); err != nil {
+		return fmt.Errorf("AddArtwork error: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveItem - Stop serving the paths of an item. The session server
+// keeps running for the rest of the items.
+func (s *HTTPserver) RemoveItem(tvpayload *soapcalls.TVPayload) {
+	s.mu.Lock()
+	item, exists := s.items[tvpayload]
+	if exists {
+		s.unroute(item)
+		delete(s.items, tvpayload)
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return
+	}
+
+	for _, c := range item.closers {
+		c.Close()
+	}
+}
+
+// Serve - Start the session server. serverStarted is
+// signaled once we listen for the Media Renderer requests.
+func (s *HTTPserver) Serve(serverStarted chan<- struct{}) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("server listen error: %w", err)
+	}
+
+	serverStarted <- struct{}{}
+	s.http.Serve(ln)
+
+	return nil
+}
+
+// ServeHTTP dispatches the requests to the
+// handlers of the items that we currently serve.
+func (s *HTTPserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	h, exists := s.routes[r.URL.Path]
+	s.mu.RUnlock()
+
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	h(w, r)
+}
+
+// route expects the lock to be held. A path belongs to a single
+// item, so that removing an item never drops the routes of another.
+func (s *HTTPserver) route(item *sessionItem, path string, h http.HandlerFunc) error {
+	if _, exists := s.routes[path]; exists {
+		return fmt.Errorf("route: path already served: %s", path)
+	}
+
+	s.routes[path] = h
+	item.paths = append(item.paths, path)
+
+	return nil
+}
+
+// unroute expects the lock to be held.
+func (s *HTTPserver) unroute(item *sessionItem) {
+	for _, p := range item.paths {
+		delete(s.routes, p)
+	}
+}
@@ -0,0 +1,160 @@
+package httphandlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+func TestSessionItems(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+
+	first := &soapcalls.TVPayload{
+		ControlURL:  "http://192.0.2.1:9197/dmr/control",
+		MediaURL:    "http://192.0.2.10:3500/one/first.mp3",
+		CallbackURL: "http://192.0.2.10:3500/callback-one",
+		MediaType:   "audio/mpeg",
+	}
+
+	second := &soapcalls.TVPayload{
+		ControlURL:  "http://192.0.2.2:9197/dmr/control",
+		MediaURL:    "http://192.0.2.10:3500/two/second.mp3",
+		CallbackURL: "http://192.0.2.10:3500/callback-two",
+		MediaType:   "audio/mpeg",
+	}
+
+	if err := s.AddItem(first, []byte("first"), nil, nopScreen{}); err != nil {
+		t.Fatalf("AddItem error: %s", err)
+	}
+
+	if err := s.AddItem(second, []byte("second"), nil, nopScreen{}); err != nil {
+		t.Fatalf("AddItem error: %s", err)
+	}
+
+	if err := s.AddItem(first, []byte("first"), nil, nopScreen{}); err == nil {
+		t.Errorf("AddItem: expected error for an item that we already serve")
+	}
+
+	if err := s.AddArtwork(second, "/two/cover.jpg", []byte("cover")); err != nil {
+		t.Fatalf("AddArtwork error: %s", err)
+	}
+
+	// A third item can't take over the paths of the first one.
+	third := &soapcalls.TVPayload{
+		ControlURL:  "http://192.0.2.3:9197/dmr/control",
+		MediaURL:    "http://192.0.2.10:3500/three/third.mp3",
+		CallbackURL: "http://192.0.2.10:3500/callback-one",
+		MediaType:   "audio/mpeg",
+	}
+
+	if err := s.AddItem(third, []byte("third"), nil, nopScreen{}); err == nil {
+		t.Errorf("AddItem: expected error for a path that we already serve")
+	}
+
+	if err := s.AddArtwork(second, "/one/first.mp3", []byte("cover")); err == nil {
+		t.Errorf("AddArtwork: expected error for a path that we already serve")
+	}
+
+	get := func(path, remote string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = remote
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	tt := []struct {
+		path   string
+		remote string
+		want   int
+		body   string
+		name   string
+	}{
+		{"/one/first.mp3", "192.0.2.1:50000", http.StatusOK, "first", `First item`},
+		{"/two/second.mp3", "192.0.2.2:50000", http.StatusOK, "second", `Second item`},
+		{"/two/cover.jpg", "192.0.2.2:50000", http.StatusOK, "cover", `Second item artwork`},
+		{"/one/first.mp3", "192.0.2.2:50000", http.StatusForbidden, "", `First item from the second renderer`},
+		{"/three/third.mp3", "192.0.2.3:50000", http.StatusNotFound, "", `Rejected item`},
+		{"/unknown.mp3", "192.0.2.1:50000", http.StatusNotFound, "", `Unknown path`},
+	}
+
+	for _, tc := range tt {
+		w := get(tc.path, tc.remote)
+		if w.Code != tc.want {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, w.Code, tc.want)
+			continue
+		}
+
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, w.Body.String(), tc.body)
+		}
+	}
+
+	s.RemoveItem(second)
+
+	for _, path := range []string{"/two/second.mp3", "/two/cover.jpg", "/callback-two"} {
+		if w := get(path, "192.0.2.2:50000"); w.Code != http.StatusNotFound {
+			t.Errorf("Removed item %s: got: %d, want: %d.", path, w.Code, http.StatusNotFound)
+		}
+	}
+
+	if w := get("/one/first.mp3", "192.0.2.1:50000"); w.Code != http.StatusOK {
+		t.Errorf("Remaining item: got: %d, want: %d.", w.Code, http.StatusOK)
+	}
+
+	// The callback of the first item doesn't respond
+	// to a plain GET, so we look at its route instead.
+	if _, exists := s.routes["/callback-one"]; !exists {
+		t.Errorf("Remaining item callback: route missing after a rejected item.")
+	}
+
+	if err := s.AddArtwork(second, "/two/cover.jpg", []byte("cover")); err == nil {
+		t.Errorf("AddArtwork: expected error for a removed item")
+	}
+}
+
+func TestSessionShutdown(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+
+	tv := &soapcalls.TVPayload{
+		ControlURL:  "http://127.0.0.1:9197/dmr/control",
+		MediaURL:    "http://127.0.0.1:3500/media.mp3",
+		CallbackURL: "http://127.0.0.1:3500/callback",
+		MediaType:   "audio/mpeg",
+	}
+
+	if err := s.AddItem(tv, []byte("media"), nil, nopScreen{}); err != nil {
+		t.Fatalf("AddItem error: %s", err)
+	}
+
+	serverStarted := make(chan struct{})
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(serverStarted) }()
+	<-serverStarted
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown error: %s", err)
+	}
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatalf("Serve didn't return after Shutdown")
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/media.mp3", nil)
+	r.RemoteAddr = "127.0.0.1:50000"
+	s.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Item after Shutdown: got: %d, want: %d.", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,246 @@
+package httphandlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestLog - A request that we served, for the Media Renderer
+// debugging. DLNAHeaders holds the *.dlna.org request headers.
+type RequestLog struct {
+	Time            time.Time
+	Remote          string
+	UserAgent       string
+	Method          string
+	Path            string
+	Range           string
+	DLNAHeaders     map[string]string
+	ContentFeatures string
+	Status          int
+	Bytes           int64
+	Duration        time.Duration
+	InFlight        bool
+}
+
+// String - A single line form of the request, for the logs.
+func (l RequestLog) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s %s %s", l.Time.Format("2006-01-02 15:04:05"), l.Remote, l.Method, l.Path)
+
+	if l.Range != "" {
+		fmt.Fprintf(&b, " Range=%q", l.Range)
+	}
+
+	keys := make([]string, 0, len(l.DLNAHeaders))
+	for k := range l.DLNAHeaders {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%q", k, l.DLNAHeaders[k])
+	}
+
+	if l.UserAgent != "" {
+		fmt.Fprintf(&b, " User-Agent=%q", l.UserAgent)
+	}
+
+	switch l.InFlight {
+	case true:
+		fmt.Fprintf(&b, " -> in flight, %d bytes, %s", l.Bytes, l.Duration.Round(time.Millisecond))
+	default:
+		fmt.Fprintf(&b, " -> %d, %d bytes, %s", l.Status, l.Bytes, l.Duration.Round(time.Millisecond))
+	}
+
+	if l.ContentFeatures != "" {
+		fmt.Fprintf(&b, " contentFeatures=%q", l.ContentFeatures)
+	}
+
+	return b.String()
+}
+
+// RequestStats - Records the requests that we serve, i.e. the
+// ones in flight and the most recent ones. It can be shared
+// by many servers and is safe for concurrent use.
+type RequestStats struct {
+	mu       sync.Mutex
+	active   map[*statsWriter]bool
+	recent   []RequestLog
+	size     int
+	requests int
+	bytes    int64
+	logf     func(RequestLog)
+}
+
+// NewRequestStats - Keep the last size requests. logf,
+// when not nil, gets called for every request we serve.
+func NewRequestStats(size int, logf func(RequestLog)) *RequestStats {
+	return &RequestStats{
+		active: make(map[*statsWriter]bool),
+		size:   size,
+		logf:   logf,
+	}
+}
+
+// Requests - The requests in flight, followed
+// by the most recent ones, newest first.
+func (st *RequestStats) Requests() []RequestLog {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	out := make([]RequestLog, 0, len(st.active)+len(st.recent))
+	for sw := range st.active {
+		l := sw.log
+		l.Bytes = sw.bytes
+		l.Duration = time.Since(l.Time)
+		out = append(out, l)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Time.After(out[j].Time)
+	})
+
+	for i := len(st.recent) - 1; i >= 0; i-- {
+		out = append(out, st.recent[i])
+	}
+
+	return out
+}
+
+// Totals - The number of requests and bytes that we served.
+func (st *RequestStats) Totals() (int, int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.requests, st.bytes
+}
+
+// track records the requests to the handler.
+// A nil RequestStats records nothing.
+func (st *RequestStats) track(h http.Handler) http.Handler {
+	if st == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := st.begin(w, r)
+		defer st.finish(sw)
+
+		h.ServeHTTP(sw, r)
+	})
+}
+
+func (st *RequestStats) begin(w http.ResponseWriter, r *http.Request) *statsWriter {
+	dlnaHeaders := make(map[string]string)
+	for k, v := range r.Header {
+		if strings.HasSuffix(strings.ToLower(k), ".dlna.org") && len(v) > 0 {
+			dlnaHeaders[k] = v[0]
+		}
+	}
+
+	sw := &statsWriter{
+		ResponseWriter: w,
+		stats:          st,
+		log: RequestLog{
+			Time:        time.Now(),
+			Remote:      remoteIP(r),
+			UserAgent:   r.UserAgent(),
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Range:       r.Header.Get("Range"),
+			DLNAHeaders: dlnaHeaders,
+			InFlight:    true,
+		},
+	}
+
+	st.mu.Lock()
+	st.active[sw] = true
+	st.mu.Unlock()
+
+	return sw
+}
+
+func (st *RequestStats) finish(sw *statsWriter) {
+	st.mu.Lock()
+	delete(st.active, sw)
+
+	l := sw.log
+	l.InFlight = false
+	l.Bytes = sw.bytes
+	l.Duration = time.Since(l.Time)
+	l.Status = sw.status
+	if l.Status == 0 {
+		l.Status = http.StatusOK
+	}
+
+	if cf := sw.Header()["contentFeatures.dlna.org"]; len(cf) > 0 {
+		l.ContentFeatures = cf[0]
+	}
+
+	st.requests++
+	st.bytes += l.Bytes
+
+	st.recent = append(st.recent, l)
+	if len(st.recent) > st.size {
+		st.recent = st.recent[len(st.recent)-st.size:]
+	}
+	st.mu.Unlock()
+
+	if st.logf != nil {
+		st.logf(l)
+	}
+}
+
+// statsWriter - Counts the status and the bytes of a response.
+type statsWriter struct {
+	http.ResponseWriter
+	stats  *RequestStats
+	log    RequestLog
+	status int
+	bytes  int64
+}
+
+func (sw *statsWriter) WriteHeader(status int) {
+	sw.stats.mu.Lock()
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.stats.mu.Unlock()
+
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statsWriter) Write(b []byte) (int, error) {
+	n, err := sw.ResponseWriter.Write(b)
+	sw.count(int64(n))
+
+	return n, err
+}
+
+// ReadFrom keeps the sendfile support of the http.ResponseWriter.
+func (sw *statsWriter) ReadFrom(src io.Reader) (int64, error) {
+	rf, ok := sw.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		return io.Copy(struct{ io.Writer }{sw}, src)
+	}
+
+	n, err := rf.ReadFrom(src)
+	sw.count(n)
+
+	return n, err
+}
+
+func (sw *statsWriter) count(n int64) {
+	sw.stats.mu.Lock()
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	sw.bytes += n
+	sw.stats.mu.Unlock()
+}
@@ -0,0 +1,96 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestStats(t *testing.T) {
+	var logged []RequestLog
+	st := NewRequestStats(2, func(l RequestLog) {
+		logged = append(logged, l)
+	})
+
+	release := make(chan struct{})
+	h := st.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Header()["contentFeatures.dlna.org"] = []string{"DLNA.ORG_OP=01"}
+		http.ServeContent(w, r, "video.mp4", time.Time{}, strings.NewReader("0123456789"))
+	}))
+
+	serve := func(path, rangeHeader string) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "192.168.1.20:50000"
+		r.Header.Set("getcontentFeatures.dlna.org", "1")
+		if rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+		h.ServeHTTP(w, r)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve("/slow", "")
+		close(done)
+	}()
+
+	// Wait for the slow request to show up in flight.
+	for {
+		if reqs := st.Requests(); len(reqs) == 1 && reqs[0].InFlight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	serve("/one", "bytes=2-5")
+	serve("/two", "")
+	serve("/three", "")
+
+	reqs := st.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("Requests: got: %d, want: %d.", len(reqs), 3)
+	}
+
+	if !reqs[0].InFlight || reqs[0].Path != "/slow" {
+		t.Errorf("In flight request: got: %s.", reqs[0])
+	}
+
+	// Only the last two requests are kept, newest first.
+	if reqs[1].Path != "/three" || reqs[2].Path != "/two" {
+		t.Errorf("Recent requests: got: %s, %s.", reqs[1].Path, reqs[2].Path)
+	}
+
+	close(release)
+	<-done
+
+	tt := []struct {
+		log    RequestLog
+		status int
+		bytes  int64
+		rng    string
+		name   string
+	}{
+		{logged[0], http.StatusPartialContent, 4, "bytes=2-5", `Range request`},
+		{logged[1], http.StatusOK, 10, "", `Full request`},
+	}
+
+	for _, tc := range tt {
+		if tc.log.Status != tc.status || tc.log.Bytes != tc.bytes || tc.log.Range != tc.rng {
+			t.Errorf("%s: got: %d %d %q, want: %d %d %q.", tc.name, tc.log.Status, tc.log.Bytes, tc.log.Range, tc.status, tc.bytes, tc.rng)
+		}
+
+		if tc.log.Remote != "192.168.1.20" || tc.log.DLNAHeaders["Getcontentfeatures.dlna.org"] != "1" || tc.log.ContentFeatures != "DLNA.ORG_OP=01" {
+			t.Errorf("%s: got: %s.", tc.name, tc.log)
+		}
+	}
+
+	if n, b := st.Totals(); n != 4 || b != 34 {
+		t.Errorf("Totals: got: %d requests, %d bytes, want: 4 requests, 34 bytes.", n, b)
+	}
+}
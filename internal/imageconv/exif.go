@@ -0,0 +1,118 @@
+package imageconv
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const exifOrientationTag = 0x0112
+
+// orientation - The EXIF orientation of a JPEG, WebP or TIFF image,
+// i.e. 1 to 8. Images without one are upright, so we return 1.
+func orientation(b []byte) int {
+	var tiff []byte
+
+	switch {
+	case len(b) > 2 && b[0] == 0xFF && b[1] == 0xD8:
+		tiff = jpegExif(b)
+	case len(b) > 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		tiff = webpExif(b)
+	default:
+		tiff = b
+	}
+
+	o := tiffOrientation(tiff)
+	if o < 1 || o > 8 {
+		return 1
+	}
+
+	return o
+}
+
+// jpegExif finds the TIFF data of the EXIF APP1 segment.
+func jpegExif(b []byte) []byte {
+	i := 2
+	for i+4 <= len(b) {
+		if b[i] != 0xFF {
+			return nil
+		}
+
+		marker := b[i+1]
+		// The image data follow the SOS segment.
+		if marker == 0xDA {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint16(b[i+2:]))
+		if size < 2 || i+2+size > len(b) {
+			return nil
+		}
+
+		segment := b[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+
+		i += 2 + size
+	}
+
+	return nil
+}
+
+// webpExif finds the TIFF data of the EXIF chunk.
+func webpExif(b []byte) []byte {
+	i := 12
+	for i+8 <= len(b) {
+		size := int(binary.LittleEndian.Uint32(b[i+4:]))
+		if size < 0 || i+8+size > len(b) {
+			return nil
+		}
+
+		if string(b[i:i+4]) == "EXIF" {
+			// Some encoders keep the JPEG prefix.
+			return bytes.TrimPrefix(b[i+8:i+8+size], []byte("Exif\x00\x00"))
+		}
+
+		// The chunks are padded to an even size.
+		i += 8 + size + size&1
+	}
+
+	return nil
+}
+
+// tiffOrientation reads the orientation tag of the first IFD.
+func tiffOrientation(b []byte) int {
+	if len(b) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(b[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(b[4:]))
+	if ifd < 8 || ifd+2 > len(b) {
+		return 0
+	}
+
+	entries := int(order.Uint16(b[ifd:]))
+	for i := 0; i < entries; i++ {
+		e := ifd + 2 + i*12
+		if e+12 > len(b) {
+			return 0
+		}
+
+		// The orientation is a SHORT that fits in the value field.
+		if order.Uint16(b[e:]) == exifOrientationTag {
+			return int(order.Uint16(b[e+8:]))
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,294 @@
+// Package imageconv converts the photos that the Media Renderers can't
+// show as they are, e.g. HEIC or WebP photos, rotated phone photos or
+// huge PNGs, to upright JPEG images within the DLNA profile limits.
+package imageconv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register the GIF decoder.
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "golang.org/x/image/bmp" // Register the BMP decoder.
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff" // Register the TIFF decoder.
+	_ "golang.org/x/image/webp" // Register the WebP decoder.
+)
+
+// MediaType - The media type of the converted photos.
+const MediaType = "image/jpeg"
+
+// DefaultProfile - Most Media Renderers show JPEG_LRG images.
+const DefaultProfile = "JPEG_LRG"
+
+const (
+	jpegQuality = 90
+	// Enough to read the image size and
+	// the EXIF orientation of the photos.
+	headLen = 256 << 10
+)
+
+// Profile - A DLNA JPEG profile and its resolution limits.
+type Profile struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+var profiles = []Profile{
+	{Name: "JPEG_SM", MaxWidth: 640, MaxHeight: 480},
+	{Name: "JPEG_MED", MaxWidth: 1024, MaxHeight: 768},
+	{Name: "JPEG_LRG", MaxWidth: 4096, MaxHeight: 4096},
+}
+
+// ProfileByName - Find a profile by name. An empty
+// name gets us the default profile.
+func ProfileByName(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return Profile{}, errors.New("profileByName: unknown image profile " + name)
+}
+
+// NeedsConversion - Whether we need to convert the image, i.e. when
+// it's not a JPEG, PNG or GIF image, when it's rotated via EXIF or
+// when it's larger than the profile allows.
+func NeedsConversion(r io.Reader, mediaType string, p Profile) bool {
+	switch mediaType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return true
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r, headLen))
+	if err != nil {
+		return true
+	}
+
+	if orientation(head) != 1 {
+		return true
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil {
+		// We serve what we can't read as is.
+		return false
+	}
+
+	return cfg.Width > p.MaxWidth || cfg.Height > p.MaxHeight
+}
+
+// FileName - The file name of the converted photo. Some
+// Media Renderers go by the extension of the media path.
+func FileName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".jpg"
+}
+
+// Photo - A photo that we convert on the first request and then
+// serve from memory, as the Media Renderers tend to request the
+// same image more than once.
+type Photo struct {
+	Profile Profile
+	// FFmpeg decodes the photos that we can't, e.g. HEIC
+	// ones. It only works for the photos on disk.
+	FFmpeg string
+
+	path string
+	src  []byte
+
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// NewFile - A photo on disk.
+func NewFile(path string, p Profile, ffmpeg string) *Photo {
+	return &Photo{Profile: p, FFmpeg: ffmpeg, path: path}
+}
+
+// NewBytes - A photo in memory.
+func NewBytes(b []byte, p Profile) *Photo {
+	return &Photo{Profile: p, src: b}
+}
+
+// DLNAProfile - The DLNA profile of the converted photo.
+func (ph *Photo) DLNAProfile() string {
+	return ph.Profile.Name
+}
+
+// JPEG - The converted photo.
+func (ph *Photo) JPEG() ([]byte, error) {
+	ph.once.Do(func() {
+		ph.data, ph.err = ph.convert()
+	})
+
+	return ph.data, ph.err
+}
+
+func (ph *Photo) convert() ([]byte, error) {
+	src := ph.src
+	if ph.path != "" {
+		b, err := os.ReadFile(ph.path)
+		if err != nil {
+			return nil, fmt.Errorf("convert read error: %w", err)
+		}
+		src = b
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil && ph.path != "" {
+		img, err = ffmpegDecode(ph.FFmpeg, ph.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("convert decode error: %w", err)
+	}
+
+	out, err := Convert(img, orientation(src), ph.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("convert error: %w", err)
+	}
+
+	return out, nil
+}
+
+// Convert - Downsize the image to the profile limits, turn it
+// upright according to its EXIF orientation and encode it to JPEG.
+func Convert(img image.Image, orient int, p Profile) ([]byte, error) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// The orientations 5 to 8 swap the width and the height,
+	// so we fit the image to the rotated profile limits.
+	maxW, maxH := p.MaxWidth, p.MaxHeight
+	if orient >= 5 && orient <= 8 {
+		maxW, maxH = maxH, maxW
+	}
+
+	dw, dh := fit(w, h, maxW, maxH)
+
+	// JPEG has no alpha channel, so the transparent
+	// parts of the image get a white background.
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+
+	switch {
+	case dw == w && dh == h:
+		draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	default:
+		draw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	}
+
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, rotate(dst, orient), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("Convert encode error: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// fit scales w x h down to fit in maxW x maxH,
+// keeping the aspect ratio. We never upscale.
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+
+	dw, dh := maxW, h*maxW/w
+	if dh > maxH {
+		dw, dh = w*maxH/h, maxH
+	}
+
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	return dw, dh
+}
+
+// rotate turns the image upright according to its EXIF orientation.
+func rotate(src *image.RGBA, orient int) *image.RGBA {
+	if orient < 2 || orient > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dw, dh := w, h
+	if orient >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orient {
+			case 2: // Mirrored horizontally.
+				sx, sy = w-1-x, y
+			case 3: // Rotated by 180°.
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored vertically.
+				sx, sy = x, h-1-y
+			case 5: // Transposed.
+				sx, sy = y, x
+			case 6: // Rotated by 90° clockwise.
+				sx, sy = y, h-1-x
+			case 7: // Transversed.
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotated by 90° counterclockwise.
+				sx, sy = w-1-y, x
+			}
+
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
+// ffmpegDecode decodes the first frame of the photos that the
+// Go decoders don't know about, e.g. HEIC ones, to PNG.
+func ffmpegDecode(ffmpeg, path string) (image.Image, error) {
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
+	bin, err := exec.LookPath(ffmpeg)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpegDecode error: %w", err)
+	}
+
+	out, err := exec.Command(bin, "-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", path, "-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "pipe:1").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpegDecode ffmpeg error: %w", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("ffmpegDecode error #2: %w", err)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,186 @@
+package imageconv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage is red, with a blue top left corner, so
+// that we can tell how the image got rotated.
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x < w/8 && y < h/8 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+// exifSegment builds a big endian EXIF APP1 segment with the orientation.
+func exifSegment(orient uint16) []byte {
+	tiff := []byte("MM\x00*\x00\x00\x00\x08")
+	tiff = append(tiff, 0, 1)
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry, exifOrientationTag)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orient)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+
+	return append(segment, payload...)
+}
+
+func testJPEG(t *testing.T, w, h int, orient uint16) []byte {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, testImage(w, h), &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	if orient == 0 {
+		return b.Bytes()
+	}
+
+	// The EXIF segment goes right after the SOI marker.
+	out := append([]byte{0xFF, 0xD8}, exifSegment(orient)...)
+	return append(out, b.Bytes()[2:]...)
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	var b bytes.Buffer
+	if err := png.Encode(&b, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func TestOrientation(t *testing.T) {
+	riff := func(chunks ...[]byte) []byte {
+		b := []byte("RIFF\x00\x00\x00\x00WEBP")
+		for _, c := range chunks {
+			b = append(b, c...)
+		}
+		return b
+	}
+
+	chunk := func(name string, payload []byte) []byte {
+		c := append([]byte(name), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+
+	tt := []struct {
+		input []byte
+		want  int
+		name  string
+	}{
+		{testJPEG(t, 4, 2, 6), 6, `JPEG rotated`},
+		{testJPEG(t, 4, 2, 0), 1, `JPEG without EXIF`},
+		{testJPEG(t, 4, 2, 42), 1, `JPEG invalid orientation`},
+		{riff(chunk("VP8X", make([]byte, 10)), chunk("EXIF", exifSegment(8)[10:])), 8, `WebP rotated`},
+		{testPNG(t, 4, 2), 1, `PNG`},
+		{[]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF}, 1, `Truncated JPEG`},
+	}
+
+	for _, tc := range tt {
+		if got := orientation(tc.input); got != tc.want {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNeedsConversion(t *testing.T) {
+	lrg, _ := ProfileByName("")
+	sm, _ := ProfileByName("JPEG_SM")
+
+	tt := []struct {
+		input     []byte
+		mediaType string
+		profile   Profile
+		want      bool
+		name      string
+	}{
+		{testJPEG(t, 800, 600, 0), "image/jpeg", lrg, false, `Upright JPEG`},
+		{testJPEG(t, 800, 600, 6), "image/jpeg", lrg, true, `Rotated JPEG`},
+		{testJPEG(t, 800, 600, 0), "image/jpeg", sm, true, `Large JPEG`},
+		{testPNG(t, 320, 200), "image/png", sm, false, `Small PNG`},
+		{nil, "image/webp", lrg, true, `WebP`},
+		{nil, "image/heif", lrg, true, `HEIC`},
+	}
+
+	for _, tc := range tt {
+		if got := NeedsConversion(bytes.NewReader(tc.input), tc.mediaType, tc.profile); got != tc.want {
+			t.Errorf("%s: got: %t, want: %t.", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPhoto(t *testing.T) {
+	sm, err := ProfileByName("JPEG_SM")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ProfileByName("JPEG_XL"); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+
+	tt := []struct {
+		input  []byte
+		width  int
+		height int
+		corner image.Point
+		name   string
+	}{
+		// The blue corner ends up at the top right.
+		{testJPEG(t, 400, 200, 6), 200, 400, image.Pt(199, 0), `Rotated JPEG`},
+		{testPNG(t, 1280, 480), 640, 240, image.Pt(0, 0), `Downsized PNG`},
+		{testJPEG(t, 1600, 1200, 8), 360, 480, image.Pt(0, 479), `Rotated and downsized JPEG`},
+	}
+
+	for _, tc := range tt {
+		b, err := NewBytes(tc.input, sm).JPEG()
+		if err != nil {
+			t.Errorf("%s: JPEG error: %s", tc.name, err)
+			continue
+		}
+
+		img, format, err := image.Decode(bytes.NewReader(b))
+		if err != nil || format != "jpeg" {
+			t.Errorf("%s: not a JPEG image: %v", tc.name, err)
+			continue
+		}
+
+		if got := img.Bounds().Size(); got != image.Pt(tc.width, tc.height) {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, image.Pt(tc.width, tc.height))
+		}
+
+		if r, _, b, _ := img.At(tc.corner.X, tc.corner.Y).RGBA(); b < r {
+			t.Errorf("%s: got: no blue corner at %s.", tc.name, tc.corner)
+		}
+	}
+
+	if _, err := NewBytes([]byte("not an image"), sm).JPEG(); err == nil {
+		t.Errorf("expected error for an invalid image")
+	}
+}
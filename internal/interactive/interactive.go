@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,6 +22,20 @@ type NewScreen struct {
 	TV         *soapcalls.TVPayload
 	mediaTitle string
 	lastAction string
+	// We can only finalize the terminal after we initialize it.
+	initialized     bool
+	quitting        bool
+	rendererStopped bool
+	slides          Slides
+}
+
+// Slides - The controls of a slideshow.
+type Slides interface {
+	Start() error
+	Next() error
+	Previous() error
+	TogglePause() bool
+	Position() (int, int)
 }
 
 var flipflop bool = true
@@ -83,17 +96,34 @@ func (p *NewScreen) EmitMsg(inputtext string) {
 	} else {
 		p.emitStr(w/2-len("MUTED")/2, h/2+2, blinkStyle, "MUTED")
 	}
+	p.mu.RLock()
+	slides := p.slides
+	p.mu.RUnlock()
+
+	if slides != nil {
+		pos, total := slides.Position()
+		slide := fmt.Sprintf("Slide %d/%d", pos, total)
+		p.emitStr(w/2-len(slide)/2, h/2-4, tcell.StyleDefault, slide)
+		p.emitStr(w/2-len(`"p" (Pause/Resume slideshow)`)/2, h/2+4, tcell.StyleDefault, `"p" (Pause/Resume slideshow)`)
+		p.emitStr(w/2-len(`"n" "b" (Next/Previous slide)`)/2, h/2+6, tcell.StyleDefault, `"n" "b" (Next/Previous slide)`)
+		s.Show()
+		return
+	}
+
 	p.emitStr(w/2-len(`"p" (Play/Pause)`)/2, h/2+4, tcell.StyleDefault, `"p" (Play/Pause)`)
 	p.emitStr(w/2-len(`"m" (Mute/Unmute)`)/2, h/2+6, tcell.StyleDefault, `"m" (Mute/Unmute)`)
 	p.emitStr(w/2-len(`"Page Up" "Page Down" (Volume Up/Down)`)/2, h/2+8, tcell.StyleDefault, `"Page Up" "Page Down" (Volume Up/Down)`)
 	s.Show()
 }
 
-// InterInit - Start the interactive terminal
-func (p *NewScreen) InterInit(tv *soapcalls.TVPayload) {
+// InterInit - Start the interactive terminal. It returns, with the
+// terminal restored, when the user exits, when the Media Renderer
+// stops or when we Quit. Ending the casting session is up to the caller.
+func (p *NewScreen) InterInit(tv *soapcalls.TVPayload) error {
 	p.TV = tv
 
 	muteChecker := time.NewTicker(1 * time.Second)
+	defer muteChecker.Stop()
 
 	go func() {
 		for range muteChecker.C {
@@ -112,8 +142,17 @@ func (p *NewScreen) InterInit(tv *soapcalls.TVPayload) {
 	encoding.Register()
 	s := p.Current
 	if err := s.Init(); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("InterInit error: %w", err)
+	}
+
+	p.mu.Lock()
+	p.initialized = true
+	quitting := p.quitting
+	p.mu.Unlock()
+
+	if quitting {
+		s.Fini()
+		return nil
 	}
 
 	defStyle := tcell.StyleDefault.
@@ -127,13 +166,16 @@ func (p *NewScreen) InterInit(tv *soapcalls.TVPayload) {
 	// Sending the Play1 action sooner may result
 	// in a panic error since we need to properly
 	// initialize the tcell window.
-	if err := tv.SendtoTV("Play1"); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+	if err := p.play(tv); err != nil {
+		s.Fini()
+		return fmt.Errorf("InterInit error #2: %w", err)
 	}
 
 	for {
 		switch ev := s.PollEvent().(type) {
+		case nil:
+			// The terminal got finalized.
+			return nil
 		case *tcell.EventResize:
 			s.Sync()
 			p.EmitMsg(p.getLastAction())
@@ -143,13 +185,65 @@ func (p *NewScreen) InterInit(tv *soapcalls.TVPayload) {
 	}
 }
 
+// play casts the media, or the first slide of the slideshow.
+func (p *NewScreen) play(tv *soapcalls.TVPayload) error {
+	p.mu.RLock()
+	slides := p.slides
+	p.mu.RUnlock()
+
+	if slides != nil {
+		return slides.Start()
+	}
+
+	return tv.SendtoTV("Play1")
+}
+
+// SetSlideshow - Control a slideshow instead of a single media.
+// InterInit starts the slideshow. Call it before InterInit.
+func (p *NewScreen) SetSlideshow(slides Slides) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.slides = slides
+}
+
+// SetTitle - Show another title, e.g. for the current slide.
+func (p *NewScreen) SetTitle(title string) {
+	p.mu.Lock()
+	p.mediaTitle = title
+	p.mu.Unlock()
+
+	p.EmitMsg(p.getLastAction())
+}
+
 // HandleKeyEvent Method to handle all key press events
 func (p *NewScreen) HandleKeyEvent(ev *tcell.EventKey) {
 	tv := p.TV
 
-	if ev.Key() == tcell.KeyEscape {
-		tv.SendtoTV("Stop")
-		p.Fini()
+	// The terminal is in raw mode, so Ctrl+C
+	// reaches us as a key instead of a signal.
+	if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC {
+		p.Quit()
+		return
+	}
+
+	p.mu.RLock()
+	slides := p.slides
+	p.mu.RUnlock()
+
+	if slides != nil {
+		switch ev.Rune() {
+		case 'p':
+			if slides.TogglePause() {
+				p.EmitMsg("Slideshow paused")
+				return
+			}
+			p.EmitMsg("Playing")
+		case 'n':
+			slides.Next()
+		case 'b':
+			slides.Previous()
+		}
+		return
 	}
 
 	if ev.Key() == tcell.KeyPgUp || ev.Key() == tcell.KeyPgDn {
@@ -197,10 +291,35 @@ func (p *NewScreen) HandleKeyEvent(ev *tcell.EventKey) {
 	}
 }
 
-// Fini Method to implement the screen interface
+// Fini Method to implement the screen interface.
+// The Media Renderer stopped the playback on its own.
 func (p *NewScreen) Fini() {
-	p.Current.Fini()
-	os.Exit(0)
+	p.mu.Lock()
+	p.rendererStopped = true
+	p.mu.Unlock()
+
+	p.Quit()
+}
+
+// Quit - Restore the terminal and return from InterInit,
+// e.g. on Esc or when we receive a signal.
+func (p *NewScreen) Quit() {
+	p.mu.Lock()
+	p.quitting = true
+	initialized := p.initialized
+	p.mu.Unlock()
+
+	if initialized {
+		p.Current.Fini()
+	}
+}
+
+// RendererStopped - The Media Renderer stopped the
+// playback on its own, so there's no need to stop it.
+func (p *NewScreen) RendererStopped() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.rendererStopped
 }
 
 // InitTcellNewScreen .
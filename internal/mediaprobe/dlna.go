@@ -0,0 +1,118 @@
+package mediaprobe
+
+// DLNAProfile - Pick the DLNA.ORG_PN media format profile that matches
+// the codecs and the resolution of the media. It's empty when no profile
+// matches, e.g. for HEVC, in which case we should omit the PN. Renderers
+// play media without a PN, but they reject media with the wrong one.
+func (i *Info) DLNAProfile() string {
+	switch i.Container {
+	case "mp4":
+		return i.mp4Profile()
+	case "ts", "m2ts":
+		return i.tsProfile()
+	case "mkv":
+		// Matroska isn't part of the DLNA guidelines, but a lot of
+		// renderers know this PN. We only use it for H.264 as that's
+		// what these renderers expect.
+		if i.VideoCodec == "h264" {
+			return "MATROSKA"
+		}
+	}
+
+	return ""
+}
+
+// resolution returns "SD" or "HD", or empty when
+// the video is too big for the DLNA profiles.
+func (i *Info) resolution() string {
+	switch {
+	case i.Width <= 720 && i.Height <= 576:
+		return "SD"
+	case i.Width <= 1920 && i.Height <= 1080:
+		return "HD"
+	}
+
+	return ""
+}
+
+func (i *Info) mp4Profile() string {
+	if i.VideoCodec == "" {
+		if i.AudioCodec == "aac" {
+			return "AAC_ISO"
+		}
+		return ""
+	}
+
+	// The AVC profiles go up to level 4.2.
+	if i.VideoCodec != "h264" || i.Level > 42 {
+		return ""
+	}
+
+	res := i.resolution()
+	switch {
+	case res == "SD" && i.Profile != "high":
+		switch i.AudioCodec {
+		case "aac", "":
+			return "AVC_MP4_MP_SD_AAC_MULT5"
+		case "ac3":
+			return "AVC_MP4_MP_SD_AC3"
+		case "mp3":
+			return "AVC_MP4_MP_SD_MPEG1_L3"
+		}
+	case res != "" && i.Profile == "high":
+		if i.AudioCodec == "aac" || i.AudioCodec == "" {
+			return "AVC_MP4_HP_HD_AAC"
+		}
+	case res == "HD" && (i.Profile == "main" || i.Profile == "baseline"):
+		if i.AudioCodec != "aac" && i.AudioCodec != "" {
+			return ""
+		}
+		if i.Height <= 720 {
+			return "AVC_MP4_MP_HD_720p_AAC"
+		}
+		return "AVC_MP4_MP_HD_1080i_AAC"
+	}
+
+	return ""
+}
+
+func (i *Info) tsProfile() string {
+	// TS files have no timestamps in front of the packets,
+	// M2TS files do. The DLNA profile tells them apart.
+	suffix := "_ISO"
+	if i.Container == "m2ts" {
+		suffix = "_T"
+	}
+
+	res := i.resolution()
+	if res == "" {
+		return ""
+	}
+
+	switch i.VideoCodec {
+	case "h264":
+		if i.Level > 42 {
+			return ""
+		}
+		switch i.AudioCodec {
+		case "aac", "":
+			return "AVC_TS_MP_" + res + "_AAC_MULT5" + suffix
+		case "ac3":
+			return "AVC_TS_MP_" + res + "_AC3" + suffix
+		}
+	case "mpeg2":
+		if i.AudioCodec != "ac3" && i.AudioCodec != "mp2" && i.AudioCodec != "" {
+			return ""
+		}
+		switch {
+		case res == "HD":
+			return "MPEG_TS_HD_NA" + suffix
+		case i.Height == 576:
+			return "MPEG_TS_SD_EU" + suffix
+		default:
+			return "MPEG_TS_SD_NA" + suffix
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,236 @@
+package mediaprobe
+
+import (
+	"bytes"
+)
+
+var startCode = []byte{0, 0, 1}
+
+func avcProfile(profileIdc byte) string {
+	switch profileIdc {
+	case 66:
+		return "baseline"
+	case 77:
+		return "main"
+	case 88:
+		return "extended"
+	case 100:
+		return "high"
+	case 110:
+		return "high10"
+	case 122:
+		return "high422"
+	case 244:
+		return "high444"
+	}
+
+	return ""
+}
+
+func hevcProfile(profileIdc byte) string {
+	switch profileIdc {
+	case 1:
+		return "main"
+	case 2:
+		return "main10"
+	case 3:
+		return "mainstillpicture"
+	case 4:
+		return "rext"
+	}
+
+	return ""
+}
+
+// findNAL returns the first complete NAL unit of the Annex B stream
+// that matches the header check. The NAL is complete once we see the
+// next start code.
+func findNAL(b []byte, match func(header byte) bool) []byte {
+	for {
+		i := bytes.Index(b, startCode)
+		if i < 0 || i+3 >= len(b) {
+			return nil
+		}
+		b = b[i+3:]
+
+		if !match(b[0]) {
+			continue
+		}
+
+		end := bytes.Index(b, startCode)
+		if end < 0 {
+			return nil
+		}
+
+		// The trailing zero belongs to a 4 bytes start code.
+		return bytes.TrimRight(b[:end], "\x00")
+	}
+}
+
+// parseSPS reads the profile, the level and the resolution of an
+// H.264 sequence parameter set NAL unit.
+func parseSPS(info *Info, nal []byte) bool {
+	if len(nal) < 4 {
+		return false
+	}
+
+	info.Profile = avcProfile(nal[1])
+	info.Level = int(nal[3])
+
+	br := &bitReader{b: unescapeRBSP(nal[4:])}
+	br.ue() // seq_parameter_set_id
+
+	chromaFormat := uint(1)
+	switch nal[1] {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = br.ue()
+		if chromaFormat == 3 {
+			br.bits(1) // separate_colour_plane_flag
+		}
+		br.ue()    // bit_depth_luma_minus8
+		br.ue()    // bit_depth_chroma_minus8
+		br.bits(1) // qpprime_y_zero_transform_bypass_flag
+
+		if br.bits(1) == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if br.bits(1) == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipScalingList(br, size)
+			}
+		}
+	}
+
+	br.ue() // log2_max_frame_num_minus4
+	switch br.ue() {
+	case 0:
+		br.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		br.bits(1) // delta_pic_order_always_zero_flag
+		br.se()    // offset_for_non_ref_pic
+		br.se()    // offset_for_top_to_bottom_field
+		for n := br.ue(); n > 0 && !br.eof; n-- {
+			br.se()
+		}
+	}
+
+	br.ue()    // max_num_ref_frames
+	br.bits(1) // gaps_in_frame_num_value_allowed_flag
+
+	widthMbs := br.ue() + 1
+	heightMaps := br.ue() + 1
+	frameMbsOnly := br.bits(1)
+	if frameMbsOnly == 0 {
+		br.bits(1) // mb_adaptive_frame_field_flag
+	}
+	br.bits(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom uint
+	if br.bits(1) == 1 {
+		cropLeft, cropRight, cropTop, cropBottom = br.ue(), br.ue(), br.ue(), br.ue()
+	}
+
+	if br.eof {
+		return false
+	}
+
+	// The crop units depend on the chroma subsampling.
+	cropX, cropY := uint(1), 2-frameMbsOnly
+	switch chromaFormat {
+	case 1:
+		cropX, cropY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropX = 2
+	}
+
+	info.Width = int(widthMbs*16 - cropX*(cropLeft+cropRight))
+	info.Height = int((2-frameMbsOnly)*heightMaps*16 - cropY*(cropTop+cropBottom))
+
+	return true
+}
+
+func skipScalingList(br *bitReader, size int) {
+	last, next := 8, 8
+	for j := 0; j < size && !br.eof; j++ {
+		if next != 0 {
+			next = (last + br.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// unescapeRBSP removes the emulation prevention bytes.
+func unescapeRBSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
+
+type bitReader struct {
+	b   []byte
+	pos uint
+	eof bool
+}
+
+func (br *bitReader) bits(n uint) uint {
+	var v uint
+	for i := uint(0); i < n; i++ {
+		if br.pos/8 >= uint(len(br.b)) {
+			br.eof = true
+			return 0
+		}
+		bit := br.b[br.pos/8] >> (7 - br.pos%8) & 1
+		v = v<<1 | uint(bit)
+		br.pos++
+	}
+
+	return v
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (br *bitReader) ue() uint {
+	zeros := uint(0)
+	for br.bits(1) == 0 {
+		if br.eof || zeros > 31 {
+			br.eof = true
+			return 0
+		}
+		zeros++
+	}
+
+	return 1<<zeros - 1 + br.bits(zeros)
+}
+
+// se reads a signed Exp-Golomb code.
+func (br *bitReader) se() int {
+	v := br.ue()
+	if v%2 == 1 {
+		return int(v+1) / 2
+	}
+
+	return -int(v / 2)
+}
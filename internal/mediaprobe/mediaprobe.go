@@ -0,0 +1,83 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+// ErrUnsupported - We can't probe this media.
+var ErrUnsupported = errors.New("media probing not supported for this media")
+
+// Info - The media details we need to pick the DLNA media format profile.
+// The codecs are lowercase short names, e.g. "h264", "hevc", "aac" or "ac3",
+// and empty when the media has no such track or when we don't know it.
+type Info struct {
+	// Container - "mp4", "mkv", "webm", "ts" or "m2ts".
+	Container  string
+	VideoCodec string
+	// Profile - The video codec profile, e.g. "main" or "high".
+	Profile string
+	// Level - The video codec level times ten, e.g. 41 for level 4.1.
+	Level      int
+	Width      int
+	Height     int
+	AudioCodec string
+}
+
+// Probe - Parse the container headers of the media to find the codecs,
+// the video profile, level and resolution. We only read what we need,
+// so this is cheap enough to run on every request.
+func Probe(r io.ReaderAt, size int64) (*Info, error) {
+	head := make([]byte, containers.TSHeadSize)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		return probeMP4(r, size)
+	case len(head) >= 4 && bytes.Equal(head[:4], ebmlMagic):
+		return probeMKV(r, size)
+	case containers.IsTS(head, containers.TSPacketSize, 0):
+		return probeTS(r, size, containers.TSPacketSize, 0)
+	case containers.IsTS(head, containers.M2TSPacketSize, 4):
+		return probeTS(r, size, containers.M2TSPacketSize, 4)
+	}
+
+	return nil, ErrUnsupported
+}
+
+// ProbeFile - Probe a media file.
+func ProbeFile(f string) (*Info, error) {
+	file, err := os.Open(f)
+	if err != nil {
+		return nil, fmt.Errorf("ProbeFile open error: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ProbeFile stat error: %w", err)
+	}
+
+	return Probe(file, stat.Size())
+}
+
+// DLNAProfile - The DLNA.ORG_PN media format profile of a media
+// file. It's empty when we can't probe the file or when it doesn't
+// match any profile, in which case we should omit the PN.
+func DLNAProfile(f string) string {
+	info, err := ProbeFile(f)
+	if err != nil {
+		return ""
+	}
+
+	return info.DLNAProfile()
+}
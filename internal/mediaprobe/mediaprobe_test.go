@@ -0,0 +1,279 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+func box(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	out := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint32(out, uint32(8+len(b)))
+	copy(out[4:], typ)
+	return append(out, b...)
+}
+
+func u16s(v ...uint16) []byte {
+	out := make([]byte, 2*len(v))
+	for i, x := range v {
+		binary.BigEndian.PutUint16(out[2*i:], x)
+	}
+	return out
+}
+
+// testMP4 builds the moov box of an MP4 file with a video
+// track of the sample entry and an AAC audio track.
+func testMP4(videoEntry []byte) []byte {
+	trak := func(handler string, entry []byte) []byte {
+		hdlr := box("hdlr", make([]byte, 8), []byte(handler), make([]byte, 12))
+		stsd := box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, entry)
+		return box("trak", box("mdia", hdlr, box("minf", box("stbl", stsd))))
+	}
+
+	// ES_Descriptor with a DecoderConfigDescriptor of the AAC object type.
+	esds := box("esds", make([]byte, 4), []byte{0x03, 0x0d, 0, 1, 0, 0x04, 0x08, 0x40, 0x15, 0, 0, 0, 0, 0, 0})
+	mp4a := box("mp4a", make([]byte, 28), esds)
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), make([]byte, 4)),
+		box("moov", trak("vide", videoEntry), trak("soun", mp4a)),
+		box("mdat", make([]byte, 64)),
+	}, nil)
+}
+
+func visualEntry(format string, width, height uint16, config []byte) []byte {
+	fields := make([]byte, 78)
+	copy(fields[24:], u16s(width, height))
+	return box(format, fields, config)
+}
+
+func ebml(id []byte, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	out := append([]byte{}, id...)
+	if len(b) < 0x7f {
+		out = append(out, 0x80|byte(len(b)))
+	} else {
+		out = append(out, 0x40|byte(len(b)>>8), byte(len(b)))
+	}
+	return append(out, b...)
+}
+
+func testMKV(docType, videoCodec string, width, height byte, private []byte) []byte {
+	video := ebml([]byte{0xae},
+		ebml([]byte{0x83}, []byte{1}),
+		ebml([]byte{0x86}, []byte(videoCodec)),
+		ebml([]byte{0x63, 0xa2}, private),
+		ebml([]byte{0xe0}, ebml([]byte{0xb0}, []byte{0x07, width}), ebml([]byte{0xba}, []byte{0x04, height})),
+	)
+	audio := ebml([]byte{0xae},
+		ebml([]byte{0x83}, []byte{2}),
+		ebml([]byte{0x86}, []byte("A_AC3")),
+	)
+
+	// A segment of unknown size, as live encoders write them.
+	segment := append([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		ebml([]byte{0x16, 0x54, 0xae, 0x6b}, video, audio)...)
+	segment = append(segment, ebml([]byte{0x1f, 0x43, 0xb6, 0x75}, make([]byte, 32))...)
+
+	return append(ebml(ebmlMagic, ebml([]byte{0x42, 0x82}, []byte(docType))), segment...)
+}
+
+type bitWriter struct {
+	b     []byte
+	nbits uint
+}
+
+func (bw *bitWriter) bit(v uint) {
+	if bw.nbits%8 == 0 {
+		bw.b = append(bw.b, 0)
+	}
+	bw.b[len(bw.b)-1] |= byte(v&1) << (7 - bw.nbits%8)
+	bw.nbits++
+}
+
+func (bw *bitWriter) ue(v uint) {
+	v++
+	n := uint(0)
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	for i := uint(0); i < n; i++ {
+		bw.bit(0)
+	}
+	for i := n + 1; i > 0; i-- {
+		bw.bit(v >> (i - 1))
+	}
+}
+
+// testSPS builds a Main profile level 4.0 SPS of a 1920x1080 video.
+func testSPS() []byte {
+	bw := &bitWriter{}
+	bw.ue(0)   // seq_parameter_set_id
+	bw.ue(0)   // log2_max_frame_num_minus4
+	bw.ue(0)   // pic_order_cnt_type
+	bw.ue(0)   // log2_max_pic_order_cnt_lsb_minus4
+	bw.ue(1)   // max_num_ref_frames
+	bw.bit(0)  // gaps_in_frame_num_value_allowed_flag
+	bw.ue(119) // pic_width_in_mbs_minus1
+	bw.ue(67)  // pic_height_in_map_units_minus1
+	bw.bit(1)  // frame_mbs_only_flag
+	bw.bit(1)  // direct_8x8_inference_flag
+	bw.bit(1)  // frame_cropping_flag
+	bw.ue(0)
+	bw.ue(0)
+	bw.ue(0)
+	bw.ue(4)
+	bw.bit(0) // vui_parameters_present_flag
+	bw.bit(1) // rbsp_stop_one_bit
+
+	return append([]byte{0x67, 77, 0x40, 40}, bw.b...)
+}
+
+func tsPacket(pid int, start bool, payload []byte) []byte {
+	p := bytes.Repeat([]byte{0xff}, containers.TSPacketSize)
+	p[0], p[1], p[2], p[3] = containers.TSSyncByte, byte(pid>>8), byte(pid), 0x10
+	if start {
+		p[1] |= 0x40
+	}
+	copy(p[4:], payload)
+	return p
+}
+
+func psi(tableID byte, body []byte) []byte {
+	length := 5 + len(body) + 4
+	s := []byte{0, tableID, 0xb0 | byte(length>>8), byte(length), 0, 1, 0xc1, 0, 0}
+	s = append(s, body...)
+	return append(s, 0, 0, 0, 0)
+}
+
+func testTS() []byte {
+	pat := psi(0x00, []byte{0, 1, 0xf0, 0x00})
+	pmt := psi(0x02, []byte{
+		0xe1, 0x00, 0xf0, 0x00,
+		0x1b, 0xe1, 0x00, 0xf0, 0x00,
+		0x06, 0xe1, 0x01, 0xf0, 0x03, 0x6a, 0x01, 0x00,
+	})
+
+	pes := []byte{0, 0, 1, 0xe0, 0, 0, 0x80, 0, 0}
+	es := bytes.Join([][]byte{{0, 0, 0, 1}, testSPS(), {0, 0, 0, 1, 0x68, 0xce}}, nil)
+
+	return bytes.Join([][]byte{
+		tsPacket(0, true, pat),
+		tsPacket(0x1000, true, pmt),
+		tsPacket(0x101, true, make([]byte, 16)),
+		tsPacket(0x100, true, append(pes, es...)),
+	}, nil)
+}
+
+func TestProbe(t *testing.T) {
+	tt := []struct {
+		name  string
+		input []byte
+		want  Info
+	}{
+		{
+			`Probe MP4 H.264`,
+			testMP4(visualEntry("avc1", 1920, 1080, box("avcC", []byte{1, 100, 0, 41}))),
+			Info{Container: "mp4", VideoCodec: "h264", Profile: "high", Level: 41, Width: 1920, Height: 1080, AudioCodec: "aac"},
+		},
+		{
+			`Probe MP4 HEVC`,
+			testMP4(visualEntry("hvc1", 3840, 2160, box("hvcC", []byte{1, 2}, make([]byte, 10), []byte{153}))),
+			Info{Container: "mp4", VideoCodec: "hevc", Profile: "main10", Level: 51, Width: 3840, Height: 2160, AudioCodec: "aac"},
+		},
+		{
+			`Probe MKV H.264`,
+			testMKV("matroska", "V_MPEG4/ISO/AVC", 0x80, 0x38, []byte{1, 77, 0, 40}),
+			Info{Container: "mkv", VideoCodec: "h264", Profile: "main", Level: 40, Width: 1920, Height: 1080, AudioCodec: "ac3"},
+		},
+		{
+			`Probe WebM VP9`,
+			testMKV("webm", "V_VP9", 0x80, 0x38, nil),
+			Info{Container: "webm", VideoCodec: "vp9", Width: 1920, Height: 1080, AudioCodec: "ac3"},
+		},
+		{
+			`Probe MPEG-TS H.264`,
+			testTS(),
+			Info{Container: "ts", VideoCodec: "h264", Profile: "main", Level: 40, Width: 1920, Height: 1080, AudioCodec: "ac3"},
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := Probe(bytes.NewReader(tc.input), int64(len(tc.input)))
+		if err != nil {
+			t.Errorf("%s: Probe error: %s", tc.name, err)
+			continue
+		}
+
+		if *out != tc.want {
+			t.Errorf("%s: got: %+v, want: %+v.", tc.name, *out, tc.want)
+		}
+	}
+
+	if _, err := Probe(bytes.NewReader([]byte("not a media file")), 16); err != ErrUnsupported {
+		t.Errorf("Probe unsupported: got: %v, want: %v.", err, ErrUnsupported)
+	}
+}
+
+func TestDLNAProfile(t *testing.T) {
+	tt := []struct {
+		name  string
+		input Info
+		want  string
+	}{
+		{
+			`DLNAProfile MP4 SD`,
+			Info{Container: "mp4", VideoCodec: "h264", Profile: "main", Level: 30, Width: 720, Height: 576, AudioCodec: "aac"},
+			"AVC_MP4_MP_SD_AAC_MULT5",
+		},
+		{
+			`DLNAProfile MP4 720p`,
+			Info{Container: "mp4", VideoCodec: "h264", Profile: "main", Level: 31, Width: 1280, Height: 720, AudioCodec: "aac"},
+			"AVC_MP4_MP_HD_720p_AAC",
+		},
+		{
+			`DLNAProfile MP4 High HD`,
+			Info{Container: "mp4", VideoCodec: "h264", Profile: "high", Level: 41, Width: 1920, Height: 1080, AudioCodec: "aac"},
+			"AVC_MP4_HP_HD_AAC",
+		},
+		{
+			`DLNAProfile MP4 4K`,
+			Info{Container: "mp4", VideoCodec: "h264", Profile: "high", Level: 51, Width: 3840, Height: 2160, AudioCodec: "aac"},
+			"",
+		},
+		{
+			`DLNAProfile MP4 HEVC`,
+			Info{Container: "mp4", VideoCodec: "hevc", Profile: "main10", Level: 51, Width: 3840, Height: 2160, AudioCodec: "aac"},
+			"",
+		},
+		{
+			`DLNAProfile MP4 audio`,
+			Info{Container: "mp4", AudioCodec: "aac"},
+			"AAC_ISO",
+		},
+		{
+			`DLNAProfile TS`,
+			Info{Container: "ts", VideoCodec: "h264", Profile: "high", Level: 40, Width: 1920, Height: 1080, AudioCodec: "ac3"},
+			"AVC_TS_MP_HD_AC3_ISO",
+		},
+		{
+			`DLNAProfile M2TS MPEG-2`,
+			Info{Container: "m2ts", VideoCodec: "mpeg2", Width: 720, Height: 576, AudioCodec: "mp2"},
+			"MPEG_TS_SD_EU_T",
+		},
+		{
+			`DLNAProfile MKV HEVC`,
+			Info{Container: "mkv", VideoCodec: "hevc"},
+			"",
+		},
+	}
+
+	for _, tc := range tt {
+		if out := tc.input.DLNAProfile(); out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package mediaprobe
+
+import (
+	"io"
+	"strings"
+)
+
+// The Tracks element comes right after the segment info in
+// practice, so the start of the file is all we need to read.
+const mkvHeadSize = 1 << 20
+
+const (
+	ebmlIDHeader      = 0x1a45dfa3
+	ebmlIDDocType     = 0x4282
+	mkvIDSegment      = 0x18538067
+	mkvIDTracks       = 0x1654ae6b
+	mkvIDCluster      = 0x1f43b675
+	mkvIDTrackEntry   = 0xae
+	mkvIDTrackType    = 0x83
+	mkvIDCodecID      = 0x86
+	mkvIDCodecPrivate = 0x63a2
+	mkvIDVideo        = 0xe0
+	mkvIDPixelWidth   = 0xb0
+	mkvIDPixelHeight  = 0xba
+	mkvTrackTypeVideo = 1
+	mkvTrackTypeAudio = 2
+)
+
+// Sizes with all their value bits set mean that the
+// size is unknown, e.g. for live streamed segments.
+const ebmlUnknownSize = -1
+
+var ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+type mkvTrack struct {
+	typ          uint64
+	codecID      string
+	codecPrivate []byte
+	width        int
+	height       int
+}
+
+func probeMKV(r io.ReaderAt, size int64) (*Info, error) {
+	n := size
+	if n > mkvHeadSize {
+		n = mkvHeadSize
+	}
+
+	head := make([]byte, n)
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	info := &Info{Container: "mkv"}
+	var tracks []mkvTrack
+
+	var walk func(b []byte) bool
+	walk = func(b []byte) bool {
+		for len(b) > 0 {
+			id, body, rest, ok := ebmlElement(b)
+			if !ok {
+				return false
+			}
+			b = rest
+
+			switch id {
+			case ebmlIDHeader:
+				eachEBML(body, func(id uint64, v []byte) {
+					if id == ebmlIDDocType && string(v) == "webm" {
+						info.Container = "webm"
+					}
+				})
+			case mkvIDSegment:
+				if !walk(body) {
+					return false
+				}
+			case mkvIDTracks:
+				eachEBML(body, func(id uint64, v []byte) {
+					if id == mkvIDTrackEntry {
+						tracks = append(tracks, parseTrackEntry(v))
+					}
+				})
+				return false
+			case mkvIDCluster:
+				// The media data. We're past the headers.
+				return false
+			}
+		}
+		return true
+	}
+	walk(head)
+
+	for _, t := range tracks {
+		switch t.typ {
+		case mkvTrackTypeVideo:
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = mkvCodec(t.codecID)
+			info.Width, info.Height = t.width, t.height
+
+			// The codec private data is the avcC or hvcC record.
+			switch {
+			case info.VideoCodec == "h264" && len(t.codecPrivate) >= 4:
+				info.Profile, info.Level = avcProfile(t.codecPrivate[1]), int(t.codecPrivate[3])
+			case info.VideoCodec == "hevc" && len(t.codecPrivate) >= 13:
+				info.Profile, info.Level = hevcProfile(t.codecPrivate[1]&0x1f), int(t.codecPrivate[12])/3
+			}
+		case mkvTrackTypeAudio:
+			if info.AudioCodec == "" {
+				info.AudioCodec = mkvCodec(t.codecID)
+			}
+		}
+	}
+
+	if info.VideoCodec == "" && info.AudioCodec == "" {
+		return nil, ErrUnsupported
+	}
+
+	return info, nil
+}
+
+func parseTrackEntry(b []byte) mkvTrack {
+	var t mkvTrack
+
+	eachEBML(b, func(id uint64, v []byte) {
+		switch id {
+		case mkvIDTrackType:
+			t.typ = ebmlUint(v)
+		case mkvIDCodecID:
+			t.codecID = strings.TrimRight(string(v), "\x00")
+		case mkvIDCodecPrivate:
+			t.codecPrivate = v
+		case mkvIDVideo:
+			eachEBML(v, func(id uint64, v []byte) {
+				switch id {
+				case mkvIDPixelWidth:
+					t.width = int(ebmlUint(v))
+				case mkvIDPixelHeight:
+					t.height = int(ebmlUint(v))
+				}
+			})
+		}
+	})
+
+	return t
+}
+
+func mkvCodec(codecID string) string {
+	codecs := map[string]string{
+		"V_MPEG4/ISO/AVC":  "h264",
+		"V_MPEGH/ISO/HEVC": "hevc",
+		"V_MPEG2":          "mpeg2",
+		"V_MPEG4/ISO/ASP":  "mpeg4",
+		"V_VP8":            "vp8",
+		"V_VP9":            "vp9",
+		"V_AV1":            "av1",
+		"A_AC3":            "ac3",
+		"A_EAC3":           "eac3",
+		"A_DTS":            "dts",
+		"A_MPEG/L3":        "mp3",
+		"A_MPEG/L2":        "mp2",
+		"A_OPUS":           "opus",
+		"A_VORBIS":         "vorbis",
+		"A_FLAC":           "flac",
+	}
+
+	if c, ok := codecs[codecID]; ok {
+		return c
+	}
+
+	// The AAC codec IDs carry the AAC profile, e.g. A_AAC/MPEG4/LC.
+	if strings.HasPrefix(codecID, "A_AAC") {
+		return "aac"
+	}
+
+	return strings.ToLower(codecID)
+}
+
+// eachEBML calls fn for every child element of b.
+func eachEBML(b []byte, fn func(id uint64, body []byte)) {
+	for len(b) > 0 {
+		id, body, rest, ok := ebmlElement(b)
+		if !ok {
+			return
+		}
+		fn(id, body)
+		b = rest
+	}
+}
+
+// ebmlElement splits the first element of b. Elements of
+// unknown size, or cut short, span to the end of b.
+func ebmlElement(b []byte) (uint64, []byte, []byte, bool) {
+	id, n := ebmlVint(b, 4, true)
+	if n == 0 {
+		return 0, nil, nil, false
+	}
+	b = b[n:]
+
+	size, n := ebmlVint(b, 8, false)
+	if n == 0 {
+		return 0, nil, nil, false
+	}
+	b = b[n:]
+
+	if size == ebmlUnknownSize || size > int64(len(b)) {
+		return uint64(id), b, nil, true
+	}
+
+	return uint64(id), b[:size], b[size:], true
+}
+
+// ebmlVint reads a variable length integer. The IDs keep their
+// length marker bit, the sizes don't. It returns the number
+// of bytes read, which is 0 for invalid integers.
+func ebmlVint(b []byte, maxLen int, keepMarker bool) (int64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+
+	length := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+
+	if length > maxLen || length > len(b) {
+		return 0, 0
+	}
+
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xff >> length)
+	}
+
+	allOnes := v == uint64(0xff>>length)
+	for _, c := range b[1:length] {
+		v = v<<8 | uint64(c)
+		allOnes = allOnes && c == 0xff
+	}
+
+	if !keepMarker && allOnes {
+		return ebmlUnknownSize, length
+	}
+
+	return int64(v), length
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
@@ -0,0 +1,224 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+// The sample descriptions are tiny. Anything
+// bigger than that is not a file we understand.
+const maxStsdSize = 1 << 20
+
+func readBox(r io.ReaderAt, b containers.Box) ([]byte, error) {
+	if b.Size > maxStsdSize {
+		return nil, ErrUnsupported
+	}
+
+	body := make([]byte, b.Size)
+	if _, err := r.ReadAt(body, b.Off); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func probeMP4(r io.ReaderAt, size int64) (*Info, error) {
+	moov, ok := containers.ChildBox(r, containers.Box{Size: size}, "moov")
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	traks, err := containers.ReadBoxes(r, moov.Off, moov.Off+moov.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Container: "mp4"}
+	for _, trak := range traks {
+		if trak.Type != "trak" {
+			continue
+		}
+
+		hdlrBox, ok := containers.ChildBox(r, trak, "mdia", "hdlr")
+		if !ok {
+			continue
+		}
+
+		stsdBox, ok := containers.ChildBox(r, trak, "mdia", "minf", "stbl", "stsd")
+		if !ok {
+			continue
+		}
+
+		hdlr, err := readBox(r, hdlrBox)
+		if err != nil || len(hdlr) < 12 {
+			continue
+		}
+
+		stsd, err := readBox(r, stsdBox)
+		if err != nil || len(stsd) < 16 {
+			continue
+		}
+
+		// We only care about the first sample
+		// entry, right after the entry count.
+		entry := stsd[8:]
+		entrySize := int(binary.BigEndian.Uint32(entry[:4]))
+		if entrySize < 8 || entrySize > len(entry) {
+			continue
+		}
+		format, body := string(entry[4:8]), entry[8:entrySize]
+
+		switch string(hdlr[8:12]) {
+		case "vide":
+			if info.VideoCodec == "" {
+				parseVisualEntry(info, format, body)
+			}
+		case "soun":
+			if info.AudioCodec == "" {
+				info.AudioCodec = parseAudioEntry(format, body)
+			}
+		}
+	}
+
+	if info.VideoCodec == "" && info.AudioCodec == "" {
+		return nil, ErrUnsupported
+	}
+
+	return info, nil
+}
+
+func parseVisualEntry(info *Info, format string, b []byte) {
+	switch format {
+	case "avc1", "avc3":
+		info.VideoCodec = "h264"
+	case "hvc1", "hev1", "dvh1", "dvhe":
+		info.VideoCodec = "hevc"
+	case "vp09":
+		info.VideoCodec = "vp9"
+	case "av01":
+		info.VideoCodec = "av1"
+	case "mp4v":
+		info.VideoCodec = "mpeg4"
+	default:
+		info.VideoCodec = format
+	}
+
+	// The VisualSampleEntry fields, followed by the codec
+	// configuration boxes at offset 78.
+	if len(b) < 78 {
+		return
+	}
+	info.Width = int(binary.BigEndian.Uint16(b[24:26]))
+	info.Height = int(binary.BigEndian.Uint16(b[26:28]))
+
+	containers.EachBox(b[78:], func(typ string, body []byte) {
+		switch typ {
+		case "avcC":
+			if len(body) >= 4 {
+				info.Profile, info.Level = avcProfile(body[1]), int(body[3])
+			}
+		case "hvcC":
+			if len(body) >= 13 {
+				info.Profile, info.Level = hevcProfile(body[1]&0x1f), int(body[12])/3
+			}
+		}
+	})
+}
+
+func parseAudioEntry(format string, b []byte) string {
+	switch format {
+	case "mp4a":
+		// The AudioSampleEntry fields are followed
+		// by the esds box at offset 28.
+		if len(b) >= 28 {
+			codec := "aac"
+			containers.EachBox(b[28:], func(typ string, body []byte) {
+				if typ == "esds" {
+					switch esdsObjectType(body) {
+					case 0x69, 0x6b:
+						codec = "mp3"
+					}
+				}
+			})
+			return codec
+		}
+		return "aac"
+	case "ac-3":
+		return "ac3"
+	case "ec-3":
+		return "eac3"
+	case "Opus":
+		return "opus"
+	case "fLaC":
+		return "flac"
+	case ".mp3":
+		return "mp3"
+	case "alac":
+		return "alac"
+	}
+
+	return format
+}
+
+// esdsObjectType returns the objectTypeIndication of the
+// DecoderConfigDescriptor, i.e. the actual audio codec.
+func esdsObjectType(b []byte) byte {
+	// Skip the version and the flags.
+	if len(b) < 4 {
+		return 0
+	}
+	b = b[4:]
+
+	for len(b) > 0 {
+		tag := b[0]
+		b = b[1:]
+
+		// The descriptor size uses 7 bits per byte.
+		var size int
+		for i := 0; i < 4 && len(b) > 0; i++ {
+			c := b[0]
+			b = b[1:]
+			size = size<<7 | int(c&0x7f)
+			if c&0x80 == 0 {
+				break
+			}
+		}
+
+		switch tag {
+		case 0x03:
+			// ES_Descriptor: ES_ID, flags and the optional fields.
+			if len(b) < 3 {
+				return 0
+			}
+			flags := b[2]
+			b = b[3:]
+			if flags&0x80 != 0 && len(b) >= 2 {
+				b = b[2:]
+			}
+			if flags&0x40 != 0 && len(b) >= 1 {
+				n := int(b[0]) + 1
+				if n > len(b) {
+					return 0
+				}
+				b = b[n:]
+			}
+			if flags&0x20 != 0 && len(b) >= 2 {
+				b = b[2:]
+			}
+		case 0x04:
+			if len(b) < 1 {
+				return 0
+			}
+			return b[0]
+		default:
+			if size > len(b) {
+				return 0
+			}
+			b = b[size:]
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,251 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+const (
+	// How far we scan for the program tables and the
+	// video sequence header. Encoders repeat them at
+	// least every few hundred milliseconds.
+	tsScanLimit = 4 << 20
+
+	// How much of the video stream we collect
+	// while looking for its sequence header.
+	tsVideoLimit = 256 << 10
+)
+
+type tsProbe struct {
+	info     *Info
+	pmtPID   int
+	videoPID int
+	video    []byte
+	// Set when we're done with the program map table.
+	streams bool
+}
+
+func probeTS(r io.ReaderAt, size int64, packetSize, prefix int) (*Info, error) {
+	p := &tsProbe{info: &Info{Container: "ts"}, pmtPID: -1, videoPID: -1}
+	if prefix > 0 {
+		p.info.Container = "m2ts"
+	}
+
+	buf := make([]byte, 1024*packetSize)
+
+	for off := int64(0); off < size && off < tsScanLimit; {
+		n, err := r.ReadAt(buf, off)
+		if n < packetSize {
+			break
+		}
+
+		for i := 0; i+packetSize <= n; i += packetSize {
+			pkt := buf[i+prefix : i+packetSize]
+			if pkt[0] != containers.TSSyncByte {
+				continue
+			}
+
+			if p.packet(pkt) {
+				return p.info, nil
+			}
+		}
+
+		if err != nil {
+			break
+		}
+		off += int64(n - n%packetSize)
+	}
+
+	if !p.streams {
+		return nil, ErrUnsupported
+	}
+
+	// We know the codecs, just not the video details.
+	return p.info, nil
+}
+
+// packet handles one TS packet. It returns true once we have all we need.
+func (p *tsProbe) packet(pkt []byte) bool {
+	pid := int(pkt[1]&0x1f)<<8 | int(pkt[2])
+	start := pkt[1]&0x40 != 0
+
+	payload := pkt[4:]
+	if pkt[3]&0x20 != 0 {
+		// Skip the adaptation field.
+		if len(payload) < 1 || int(payload[0])+1 > len(payload) {
+			return false
+		}
+		payload = payload[int(payload[0])+1:]
+	}
+	if pkt[3]&0x10 == 0 {
+		return false
+	}
+
+	switch {
+	case pid == 0 && start && p.pmtPID < 0:
+		p.pmtPID = parsePAT(payload)
+	case pid == p.pmtPID && start && !p.streams:
+		p.parsePMT(payload)
+		if p.streams && p.videoPID < 0 {
+			return true
+		}
+	case pid == p.videoPID && p.streams:
+		if start && len(p.video) == 0 {
+			payload = pesPayload(payload)
+		}
+		if len(p.video) > 0 || start {
+			p.video = append(p.video, payload...)
+		}
+
+		if p.parseVideo() || len(p.video) > tsVideoLimit {
+			return true
+		}
+	}
+
+	return false
+}
+
+// psiSection returns the PSI section after the pointer field,
+// without its 8 bytes header and its CRC.
+func psiSection(payload []byte) []byte {
+	if len(payload) < 1 || int(payload[0])+1 > len(payload) {
+		return nil
+	}
+	s := payload[int(payload[0])+1:]
+
+	if len(s) < 3 {
+		return nil
+	}
+	length := int(s[1]&0x0f)<<8 | int(s[2])
+	if length < 9 || 3+length > len(s) {
+		return nil
+	}
+
+	return s[8 : 3+length-4]
+}
+
+// parsePAT returns the PID of the first program map table.
+func parsePAT(payload []byte) int {
+	s := psiSection(payload)
+
+	for ; len(s) >= 4; s = s[4:] {
+		program := int(s[0])<<8 | int(s[1])
+		// Program 0 is the network information table.
+		if program != 0 {
+			return int(s[2]&0x1f)<<8 | int(s[3])
+		}
+	}
+
+	return -1
+}
+
+func (p *tsProbe) parsePMT(payload []byte) {
+	s := psiSection(payload)
+	if len(s) < 4 {
+		return
+	}
+
+	infoLen := int(s[2]&0x0f)<<8 | int(s[3])
+	if 4+infoLen > len(s) {
+		return
+	}
+	s = s[4+infoLen:]
+
+	for len(s) >= 5 {
+		streamType := s[0]
+		pid := int(s[1]&0x1f)<<8 | int(s[2])
+		esLen := int(s[3]&0x0f)<<8 | int(s[4])
+		if 5+esLen > len(s) {
+			break
+		}
+		descriptors := s[5 : 5+esLen]
+		s = s[5+esLen:]
+
+		video, audio := tsCodec(streamType, descriptors)
+		if video != "" && p.info.VideoCodec == "" {
+			p.info.VideoCodec, p.videoPID = video, pid
+		}
+		if audio != "" && p.info.AudioCodec == "" {
+			p.info.AudioCodec = audio
+		}
+	}
+
+	p.streams = true
+}
+
+// tsCodec maps the PMT stream types to codecs. Private data streams
+// use descriptors to tell us what they carry.
+func tsCodec(streamType byte, descriptors []byte) (string, string) {
+	switch streamType {
+	case 0x01, 0x02:
+		return "mpeg2", ""
+	case 0x10:
+		return "mpeg4", ""
+	case 0x1b:
+		return "h264", ""
+	case 0x24:
+		return "hevc", ""
+	case 0x03, 0x04:
+		return "", "mp2"
+	case 0x0f, 0x11:
+		return "", "aac"
+	case 0x81:
+		return "", "ac3"
+	case 0x87:
+		return "", "eac3"
+	case 0x06:
+		for d := descriptors; len(d) >= 2 && 2+int(d[1]) <= len(d); d = d[2+int(d[1]):] {
+			switch d[0] {
+			case 0x6a:
+				return "", "ac3"
+			case 0x7a:
+				return "", "eac3"
+			case 0x7b:
+				return "", "dts"
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// pesPayload skips the PES packet header.
+func pesPayload(b []byte) []byte {
+	if len(b) < 9 || !bytes.Equal(b[:3], []byte{0, 0, 1}) {
+		return nil
+	}
+
+	n := 9 + int(b[8])
+	if n > len(b) {
+		return nil
+	}
+
+	return b[n:]
+}
+
+// parseVideo looks for the sequence header in the collected video
+// stream. It returns true once we have the video details.
+func (p *tsProbe) parseVideo() bool {
+	switch p.info.VideoCodec {
+	case "h264":
+		sps := findNAL(p.video, func(h byte) bool { return h&0x1f == 7 })
+		if sps == nil {
+			return false
+		}
+		return parseSPS(p.info, sps)
+	case "mpeg2":
+		i := bytes.Index(p.video, []byte{0, 0, 1, 0xb3})
+		if i < 0 || i+7 > len(p.video) {
+			return false
+		}
+		h := p.video[i+4:]
+		p.info.Width = int(h[0])<<4 | int(h[1])>>4
+		p.info.Height = int(h[1]&0x0f)<<8 | int(h[2])
+		return true
+	}
+
+	// We can't parse the rest, so there's no point in looking.
+	return true
+}
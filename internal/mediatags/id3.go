@@ -0,0 +1,295 @@
+package mediatags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const id3HeaderSize = 10
+
+// The ID3v2.2 frames have three character IDs.
+var id3v22Frames = map[string]string{
+	"TT2": "TIT2",
+	"TP1": "TPE1",
+	"TAL": "TALB",
+	"PIC": "APIC",
+}
+
+func readID3(r io.ReaderAt, _ int64) (*Tags, error) {
+	hdr, err := readAt(r, 0, id3HeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	version, flags := hdr[3], hdr[5]
+	if version < 2 || version > 4 {
+		return nil, ErrUnsupported
+	}
+
+	body, err := readAt(r, id3HeaderSize, int64(syncsafe(hdr[6:10])))
+	if err != nil {
+		return nil, err
+	}
+
+	// The unsynchronisation inserts a zero byte after every 0xFF byte.
+	if flags&0x80 != 0 {
+		body = bytes.ReplaceAll(body, []byte{0xff, 0x00}, []byte{0xff})
+	}
+
+	// We don't need the extended header.
+	if flags&0x40 != 0 && version > 2 {
+		if len(body) < 4 {
+			return nil, errors.New("readID3: invalid extended header")
+		}
+
+		extSize := int(binary.BigEndian.Uint32(body[:4])) + 4
+		if version == 4 {
+			extSize = int(syncsafe(body[:4]))
+		}
+
+		if extSize > len(body) {
+			return nil, errors.New("readID3: invalid extended header")
+		}
+		body = body[extSize:]
+	}
+
+	t := &Tags{}
+
+	idLen, frameHdr := 4, 10
+	if version == 2 {
+		idLen, frameHdr = 3, 6
+	}
+
+	for len(body) >= frameHdr && body[0] != 0 {
+		id := string(body[:idLen])
+
+		var size int
+		var format byte
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+			id = id3v22Frames[id]
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+			format = body[9]
+		default:
+			size = int(syncsafe(body[4:8]))
+			format = body[9]
+		}
+
+		if size < 0 || frameHdr+size > len(body) {
+			break
+		}
+
+		data := body[frameHdr : frameHdr+size]
+		body = body[frameHdr+size:]
+
+		data, ok := id3FrameData(data, version, format)
+		if !ok {
+			continue
+		}
+
+		switch id {
+		case "TIT2":
+			t.Title = id3Text(data)
+		case "TPE1":
+			t.Artist = id3Text(data)
+		case "TALB":
+			t.Album = id3Text(data)
+		case "APIC":
+			cover, coverType, front := id3Picture(data, version == 2)
+			// The front cover wins over the rest of the pictures.
+			if cover != nil && (t.Cover == nil || front) {
+				t.Cover, t.CoverType = cover, coverType
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// syncsafe decodes the 28 bit integers of the ID3v2 headers,
+// where the top bit of every byte is zero.
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
+
+// id3FrameData strips what the frame format flags add to the frame
+// data. We skip the compressed and the encrypted frames.
+func id3FrameData(data []byte, version, format byte) ([]byte, bool) {
+	switch version {
+	case 3:
+		if format&0xc0 != 0 {
+			return nil, false
+		}
+
+		// The group identifier.
+		if format&0x20 != 0 {
+			if len(data) < 1 {
+				return nil, false
+			}
+			data = data[1:]
+		}
+	case 4:
+		if format&0x0c != 0 {
+			return nil, false
+		}
+
+		// The group identifier.
+		if format&0x40 != 0 {
+			if len(data) < 1 {
+				return nil, false
+			}
+			data = data[1:]
+		}
+
+		// The data length indicator.
+		if format&0x01 != 0 {
+			if len(data) < 4 {
+				return nil, false
+			}
+			data = data[4:]
+		}
+
+		if format&0x02 != 0 {
+			data = bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+		}
+	}
+
+	return data, true
+}
+
+// id3Text decodes the first string of a text frame.
+func id3Text(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+
+	s, _ := id3String(data[1:], data[0])
+
+	return strings.TrimSpace(s)
+}
+
+// id3Picture decodes an APIC frame, or a PIC frame of ID3v2.2,
+// and whether it's the front cover.
+func id3Picture(data []byte, v22 bool) ([]byte, string, bool) {
+	if len(data) < 2 {
+		return nil, "", false
+	}
+
+	enc := data[0]
+	data = data[1:]
+
+	var mediaType string
+	switch v22 {
+	case true:
+		// The image format, e.g. "JPG" or "PNG".
+		if len(data) < 3 {
+			return nil, "", false
+		}
+		mediaType = "image/" + strings.ToLower(string(data[:3]))
+		if mediaType == "image/jpg" {
+			mediaType = "image/jpeg"
+		}
+		data = data[3:]
+	default:
+		i := bytes.IndexByte(data, 0)
+		if i < 0 {
+			return nil, "", false
+		}
+		mediaType = strings.ToLower(string(data[:i]))
+		if mediaType == "image/jpg" {
+			mediaType = "image/jpeg"
+		}
+		data = data[i+1:]
+	}
+
+	// The picture is a link, not the image.
+	if mediaType == "-->" || mediaType == "image/-->" {
+		return nil, "", false
+	}
+
+	if len(data) < 1 {
+		return nil, "", false
+	}
+
+	front := data[0] == frontCover
+
+	// The description.
+	_, n := id3String(data[1:], enc)
+	data = data[1+n:]
+
+	if len(data) == 0 {
+		return nil, "", false
+	}
+
+	return data, mediaType, front
+}
+
+// id3String decodes a null terminated string of the encoding and
+// returns how many bytes it took, along with the terminator.
+func id3String(b []byte, enc byte) (string, int) {
+	switch enc {
+	case 1, 2:
+		// UTF-16 strings end with two zero bytes, on an even offset.
+		end := len(b)
+		n := len(b)
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				end, n = i, i+2
+				break
+			}
+		}
+
+		return decodeUTF16(b[:end], enc == 2), n
+	default:
+		end := bytes.IndexByte(b, 0)
+		n := end + 1
+		if end < 0 {
+			end, n = len(b), len(b)
+		}
+
+		if enc == 3 {
+			return string(b[:end]), n
+		}
+
+		return latin1(b[:end]), n
+	}
+}
+
+// decodeUTF16 decodes UTF-16 with a byte order mark, or
+// big endian UTF-16 without one.
+func decodeUTF16(b []byte, bigEndian bool) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xff && b[1] == 0xfe:
+			b, bigEndian = b[2:], false
+		case b[0] == 0xfe && b[1] == 0xff:
+			b, bigEndian = b[2:], true
+		}
+	}
+
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = binary.BigEndian.Uint16(b[2*i:])
+		} else {
+			u[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	}
+
+	return string(utf16.Decode(u))
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+
+	return string(r)
+}
@@ -0,0 +1,164 @@
+// Package mediatags reads the title, artist, album and cover art of audio
+// files from their ID3v2 tags, Vorbis comments (FLAC, Ogg Vorbis and Opus)
+// or MP4 metadata atoms, and the details and poster of videos from their
+// Kodi NFO and artwork sidecars, so that the Media Renderers get to show them.
+package mediatags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupported - We can't read the tags of this media.
+var ErrUnsupported = errors.New("media tags not supported for this media")
+
+// The tags, along with their embedded cover art, rarely
+// get bigger than a few MBs. Anything bigger than that
+// is not a tag we want to hold in memory.
+const maxTagSize = 16 << 20
+
+// The folder covers, in order of preference.
+var folderCovers = []string{"folder.jpg", "cover.jpg", "folder.png", "cover.png", "front.jpg"}
+
+// Tags - The details of a media file.
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string
+	// Date - The release date, or just the year, of a video.
+	Date   string
+	Plot   string
+	Genres []string
+	// Cover - The embedded cover art, if any, and its media type.
+	Cover     []byte
+	CoverType string
+	// CoverFile - The folder cover of an audio file, when it
+	// has no embedded cover art, or the poster of a video.
+	CoverFile string
+}
+
+// Read - Read the tags of an audio file. We only read the tags,
+// not the whole file.
+func Read(r io.ReaderAt, size int64) (*Tags, error) {
+	head := make([]byte, 12)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return readID3(r, size)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return readFLAC(r, size)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return readOgg(r, size)
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		return readMP4(r, size)
+	}
+
+	return nil, ErrUnsupported
+}
+
+// ReadFile - Read the tags of an audio file.
+func ReadFile(f string) (*Tags, error) {
+	file, err := os.Open(f)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile open error: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile stat error: %w", err)
+	}
+
+	return Read(file, stat.Size())
+}
+
+// Load - The tags of an audio file, falling back to its folder
+// cover when it has no embedded cover art. Files without tags
+// get empty tags, as they may still have a folder cover.
+func Load(f string) *Tags {
+	t, err := ReadFile(f)
+	if err != nil {
+		t = &Tags{}
+	}
+
+	if len(t.Cover) == 0 {
+		t.CoverFile = FolderCover(f)
+	}
+
+	return t
+}
+
+// FolderCover - The folder.jpg or cover.jpg, in any case,
+// next to the media file. It's empty when there's none.
+func FolderCover(f string) string {
+	return sidecar(filepath.Dir(f), folderCovers...)
+}
+
+// sidecar finds the first of the files, in any case, in dir.
+// It's empty when there's none.
+func sidecar(dir string, names ...string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, n := range names {
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(e.Name(), n) {
+				return filepath.Join(dir, e.Name())
+			}
+		}
+	}
+
+	return ""
+}
+
+// Artwork - The cover art to serve, i.e. the embedded cover art as
+// []byte or the folder cover as a path, along with a file name of
+// the right extension for its media path. ok is false when the
+// audio file has no cover art at all.
+func (t *Tags) Artwork() (name string, art interface{}, ok bool) {
+	switch {
+	case len(t.Cover) > 0:
+		mediaType := t.CoverType
+		if !strings.HasPrefix(mediaType, "image/") {
+			mediaType = http.DetectContentType(t.Cover)
+		}
+
+		name = "cover.jpg"
+		if mediaType == "image/png" {
+			name = "cover.png"
+		}
+
+		return name, t.Cover, true
+	case t.CoverFile != "":
+		return "cover" + strings.ToLower(filepath.Ext(t.CoverFile)), t.CoverFile, true
+	}
+
+	return "", nil, false
+}
+
+// readAt reads n bytes at off, within the tag size limit.
+func readAt(r io.ReaderAt, off int64, n int64) ([]byte, error) {
+	if n < 0 || n > maxTagSize {
+		return nil, errors.New("readAt: tag too large")
+	}
+
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
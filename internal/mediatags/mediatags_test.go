@@ -0,0 +1,360 @@
+package mediatags
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+)
+
+var testCover = []byte("\xff\xd8\xff\xe0 not quite a JPEG")
+
+func be32(v int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func le32(v int) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+func synchsafe32(v int) []byte {
+	return []byte{byte(v >> 21 & 0x7f), byte(v >> 14 & 0x7f), byte(v >> 7 & 0x7f), byte(v & 0x7f)}
+}
+
+func testID3(version byte) []byte {
+	frame := func(id string, body ...[]byte) []byte {
+		b := bytes.Join(body, nil)
+		size := be32(len(b))
+		if version == 4 {
+			size = synchsafe32(len(b))
+		}
+		return bytes.Join([][]byte{[]byte(id), size, {0, 0}, b}, nil)
+	}
+
+	frames := bytes.Join([][]byte{
+		// UTF-16 with a byte order mark.
+		frame("TIT2", []byte{1, 0xff, 0xfe, 'S', 0, 'o', 0, 'n', 0, 'g', 0}),
+		frame("TPE1", []byte{0}, []byte("Caf\xe9")),
+		frame("TALB", []byte{3}, []byte("Album\x00")),
+		frame("APIC", []byte{0}, []byte("image/png\x00"), []byte{4}, []byte("back\x00"), []byte("back cover")),
+		frame("APIC", []byte{0}, []byte("image/jpeg\x00"), []byte{3}, []byte("front\x00"), testCover),
+	}, nil)
+
+	// Some padding.
+	frames = append(frames, make([]byte, 32)...)
+
+	return bytes.Join([][]byte{[]byte("ID3"), {version, 0, 0}, synchsafe32(len(frames)), frames, []byte("audio")}, nil)
+}
+
+func testVorbisComments(pictureComment bool) []byte {
+	comments := []string{"title=Song", "ARTIST=Café", "ALBUM=Album", "NOEQUALSIGN"}
+	if pictureComment {
+		comments = append(comments, "METADATA_BLOCK_PICTURE="+base64.StdEncoding.EncodeToString(testFLACPicture()))
+	}
+
+	b := bytes.Join([][]byte{le32(6), []byte("vendor"), le32(len(comments))}, nil)
+	for _, c := range comments {
+		b = append(b, le32(len(c))...)
+		b = append(b, c...)
+	}
+
+	return b
+}
+
+func testFLACPicture() []byte {
+	return bytes.Join([][]byte{be32(3), be32(10), []byte("image/jpeg"), be32(0), make([]byte, 16), be32(len(testCover)), testCover}, nil)
+}
+
+func testFLAC() []byte {
+	block := func(typ byte, last bool, b []byte) []byte {
+		if last {
+			typ |= 0x80
+		}
+		return append([]byte{typ, byte(len(b) >> 16), byte(len(b) >> 8), byte(len(b))}, b...)
+	}
+
+	return bytes.Join([][]byte{
+		[]byte("fLaC"),
+		block(0, false, make([]byte, 34)),
+		block(flacVorbisComment, false, testVorbisComments(false)),
+		block(flacPicture, true, testFLACPicture()),
+		[]byte("audio"),
+	}, nil)
+}
+
+// testOgg builds an Opus file with the comment header split over
+// two pages, as the big ones are.
+func testOgg() []byte {
+	page := func(packet []byte, segments []byte) []byte {
+		hdr := make([]byte, oggPageHdr)
+		copy(hdr, "OggS")
+		binary.LittleEndian.PutUint32(hdr[14:], 1234)
+		hdr[26] = byte(len(segments))
+		return bytes.Join([][]byte{hdr, segments, packet}, nil)
+	}
+
+	tags := append([]byte("OpusTags"), testVorbisComments(true)...)
+
+	// Pad the comment header to two full segments and a bit more.
+	tags = append(tags, make([]byte, 600-len(tags))...)
+	full, rest := tags[:510], tags[510:]
+
+	return bytes.Join([][]byte{
+		page([]byte("OpusHead12345678901"), []byte{19}),
+		page(full, []byte{255, 255}),
+		page(rest, []byte{byte(len(rest))}),
+	}, nil)
+}
+
+func box(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	return bytes.Join([][]byte{be32(8 + len(b)), []byte(typ), b}, nil)
+}
+
+func testMP4() []byte {
+	item := func(typ string, dataType int, value []byte) []byte {
+		return box(typ, box("data", be32(dataType), be32(0), value))
+	}
+
+	ilst := box("ilst",
+		item("\xa9nam", mp4UTF8, []byte("Song")),
+		item("aART", mp4UTF8, []byte("Album Artist")),
+		item("\xa9ART", mp4UTF8, []byte("Café")),
+		item("\xa9alb", mp4UTF8, []byte("Album")),
+		item("covr", mp4JPEG, testCover),
+	)
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("M4A "), make([]byte, 4)),
+		box("moov", box("mvhd", make([]byte, 100)), box("udta", box("meta", make([]byte, 4), box("hdlr", make([]byte, 25)), ilst))),
+		box("mdat", make([]byte, 64)),
+	}, nil)
+}
+
+func TestRead(t *testing.T) {
+	tt := []struct {
+		input []byte
+		name  string
+	}{
+		{testID3(3), `ID3v2.3`},
+		{testID3(4), `ID3v2.4`},
+		{testFLAC(), `FLAC`},
+		{testOgg(), `Opus`},
+		{testMP4(), `MP4`},
+	}
+
+	for _, tc := range tt {
+		got, err := Read(bytes.NewReader(tc.input), int64(len(tc.input)))
+		if err != nil {
+			t.Errorf("%s: Read error: %s", tc.name, err)
+			continue
+		}
+
+		if got.Title != "Song" || got.Artist != "Café" || got.Album != "Album" {
+			t.Errorf("%s: got: %q/%q/%q, want: Song/Café/Album.", tc.name, got.Title, got.Artist, got.Album)
+		}
+
+		if !bytes.Equal(got.Cover, testCover) || got.CoverType != "image/jpeg" {
+			t.Errorf("%s: got cover: %q (%s), want: %q (image/jpeg).", tc.name, got.Cover, got.CoverType, testCover)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("RIFF....WAVE")), 12); err != ErrUnsupported {
+		t.Errorf("WAV: got: %v, want: %s.", err, ErrUnsupported)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, b []byte) string {
+		f := filepath.Join(dir, name)
+		if err := os.WriteFile(f, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	tagged := write("tagged.mp3", testID3(3))
+	plain := write("plain.wav", []byte("RIFF....WAVE"))
+
+	if tags := Load(plain); tags.CoverFile != "" {
+		t.Errorf("No cover: got: %s, want none.", tags.CoverFile)
+	}
+
+	write("notes.txt", nil)
+	cover := write("Cover.JPG", testCover)
+	write("front.jpg", testCover)
+
+	tt := []struct {
+		input    string
+		wantName string
+		name     string
+	}{
+		{tagged, "cover.jpg", `Embedded cover`},
+		{plain, "cover.jpg", `Folder cover`},
+	}
+
+	for _, tc := range tt {
+		name, art, ok := Load(tc.input).Artwork()
+		if !ok || name != tc.wantName {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, name, tc.wantName)
+			continue
+		}
+
+		switch a := art.(type) {
+		case []byte:
+			if !bytes.Equal(a, testCover) {
+				t.Errorf("%s: got: %q, want: %q.", tc.name, a, testCover)
+			}
+		case string:
+			if a != cover {
+				t.Errorf("%s: got: %s, want: %s.", tc.name, a, cover)
+			}
+		}
+	}
+}
+
+func TestReadNFO(t *testing.T) {
+	tt := []struct {
+		input      string
+		wantTitle  string
+		wantDate   string
+		wantPlot   string
+		wantGenres string
+		name       string
+	}{
+		{
+			`<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>
+<movie>
+  <title>Big Buck Bunny</title>
+  <year>2008</year>
+  <plot>A giant rabbit takes revenge.</plot>
+  <genre>Animation</genre>
+  <genre>Comedy / Short</genre>
+</movie>
+https://www.imdb.com/title/tt1254207/`,
+			"Big Buck Bunny", "2008", "A giant rabbit takes revenge.", "Animation,Comedy,Short", `Movie`,
+		},
+		{
+			`<episodedetails><title>Pilot</title><aired>2010-01-31</aired><outline>It begins.</outline></episodedetails>`,
+			"Pilot", "2010-01-31", "It begins.", "", `Episode`,
+		},
+	}
+
+	for _, tc := range tt {
+		got, err := ReadNFO(strings.NewReader(tc.input))
+		if err != nil {
+			t.Errorf("%s: ReadNFO error: %s", tc.name, err)
+			continue
+		}
+
+		if got.Title != tc.wantTitle || got.Date != tc.wantDate || got.Plot != tc.wantPlot || strings.Join(got.Genres, ",") != tc.wantGenres {
+			t.Errorf("%s: got: %q/%q/%q/%q, want: %q/%q/%q/%q.", tc.name, got.Title, got.Date, got.Plot, got.Genres,
+				tc.wantTitle, tc.wantDate, tc.wantPlot, tc.wantGenres)
+		}
+	}
+
+	if _, err := ReadNFO(strings.NewReader("https://www.imdb.com/title/tt1254207/")); err == nil {
+		t.Errorf("URL only NFO: expected error")
+	}
+}
+
+func TestLoadVideo(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range []string{"movie.mkv", "fanart.jpg", "Poster.JPG", "extra.mkv", "extra.nfo"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nfo := `<movie><title>Movie</title><year>2001</year></movie>`
+	if err := os.WriteFile(filepath.Join(dir, "movie.nfo"), []byte(nfo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := LoadVideo(filepath.Join(dir, "movie.mkv"))
+	if tags.Title != "Movie" || tags.Date != "2001" || tags.CoverFile != filepath.Join(dir, "Poster.JPG") {
+		t.Errorf("Movie: got: %q/%q/%q, want: Movie/2001/Poster.JPG.", tags.Title, tags.Date, tags.CoverFile)
+	}
+
+	// The extra.nfo is not an NFO file, so the poster is all we get.
+	tags = LoadVideo(filepath.Join(dir, "extra.mkv"))
+	if tags.Title != "" || tags.CoverFile != filepath.Join(dir, "Poster.JPG") {
+		t.Errorf("Extra: got: %q/%q, want: no title and Poster.JPG.", tags.Title, tags.CoverFile)
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	tt := []struct {
+		tags    *Tags
+		wantArt bool
+		name    string
+	}{
+		{nil, false, `No tags`},
+		{&Tags{Title: "Song", Artist: "Band"}, false, `No artwork`},
+		{&Tags{Title: "Song", Artist: "Band", Cover: testCover}, true, `Embedded cover`},
+	}
+
+	for _, tc := range tt {
+		tv := &soapcalls.TVPayload{}
+
+		artPath, art, err := tc.tags.SetMetadata(tv, "192.0.2.10:3500")
+		if err != nil {
+			t.Errorf("%s: SetMetadata error: %s", tc.name, err)
+			continue
+		}
+
+		if got := art != nil; got != tc.wantArt {
+			t.Errorf("%s: got artwork: %t, want: %t.", tc.name, got, tc.wantArt)
+			continue
+		}
+
+		if tc.tags != nil && tv.Metadata.Title != tc.tags.Title {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, tv.Metadata.Title, tc.tags.Title)
+		}
+
+		if !tc.wantArt {
+			continue
+		}
+
+		if want := "http://192.0.2.10:3500" + artPath; tv.Metadata.AlbumArtURL != want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, tv.Metadata.AlbumArtURL, want)
+		}
+	}
+}
+
+func TestLoadMedia(t *testing.T) {
+	dir := t.TempDir()
+
+	media := filepath.Join(dir, "media")
+	if err := os.WriteFile(media, testID3(3), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		mediaType string
+		want      bool
+		name      string
+	}{
+		{"audio/mpeg", true, `Audio tags`},
+		{"video/mp4", true, `Video details`},
+		{"image/jpeg", false, `No tags for photos`},
+	}
+
+	for _, tc := range tt {
+		if got := LoadMedia(media, tc.mediaType) != nil; got != tc.want {
+			t.Errorf("%s: got tags: %t, want: %t.", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package mediatags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+// LoadMedia - The tags of an audio file, or the details of a video,
+// depending on its media type. Other media get nil Tags.
+func LoadMedia(f, mediaType string) *Tags {
+	switch {
+	case strings.HasPrefix(mediaType, "audio/"):
+		return Load(f)
+	case strings.HasPrefix(mediaType, "video/"):
+		return LoadVideo(f)
+	}
+
+	return nil
+}
+
+// SetMetadata - Pass the details of an audio file or a video to the
+// Media Renderer, along with the URL of its album art or poster. It
+// returns the artwork to serve next to the media, if any, and its
+// URL path. Media without tags, i.e. nil Tags, get no details.
+func (t *Tags) SetMetadata(tvdata *soapcalls.TVPayload, whereToAdvertise string) (string, interface{}, error) {
+	if t == nil {
+		return "", nil, nil
+	}
+
+	tvdata.Metadata = soapcalls.ItemMetadata{
+		Title:       t.Title,
+		Artist:      t.Artist,
+		Album:       t.Album,
+		Date:        t.Date,
+		Description: t.Plot,
+		Genres:      t.Genres,
+	}
+
+	name, artwork, ok := t.Artwork()
+	if !ok {
+		return "", nil, nil
+	}
+
+	artPath, err := utils.TokenizedPath(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("SetMetadata error: %w", err)
+	}
+
+	tvdata.Metadata.AlbumArtURL = utils.BuildHTTPURL(whereToAdvertise, artPath)
+
+	return "/" + artPath, artwork, nil
+}
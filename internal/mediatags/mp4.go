@@ -0,0 +1,108 @@
+package mediatags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+// The data types of the iTunes metadata items.
+const (
+	mp4UTF8 = 1
+	mp4JPEG = 13
+	mp4PNG  = 14
+)
+
+// readMP4 reads the iTunes metadata items of the moov/udta/meta/ilst box.
+func readMP4(r io.ReaderAt, size int64) (*Tags, error) {
+	moov, ok := containers.ChildBox(r, containers.Box{Size: size}, "moov")
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	meta, ok := containers.ChildBox(r, moov, "udta", "meta")
+	if !ok || meta.Size < 4 {
+		return &Tags{}, nil
+	}
+
+	// The meta box is a full box in MP4 files, with a version
+	// and flags, but not in the QuickTime files.
+	head, err := readAt(r, meta.Off, 4)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(head, []byte{0, 0, 0, 0}) {
+		meta.Off, meta.Size = meta.Off+4, meta.Size-4
+	}
+
+	ilst, ok := containers.ChildBox(r, meta, "ilst")
+	if !ok {
+		return &Tags{}, nil
+	}
+
+	items, err := containers.ReadBoxes(r, ilst.Off, ilst.Off+ilst.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tags{}
+
+	for _, item := range items {
+		switch item.Type {
+		case "\xa9nam", "\xa9ART", "aART", "\xa9alb", "covr":
+		default:
+			continue
+		}
+
+		data, ok := containers.ChildBox(r, item, "data")
+		if !ok || data.Size < 8 {
+			continue
+		}
+
+		b, err := readAt(r, data.Off, data.Size)
+		if err != nil {
+			return nil, err
+		}
+
+		typ, value := binary.BigEndian.Uint32(b[:4])&0xffffff, b[8:]
+
+		switch item.Type {
+		case "\xa9nam":
+			t.Title = mp4Text(typ, value)
+		case "\xa9ART":
+			t.Artist = mp4Text(typ, value)
+		case "aART":
+			// The album artist, when there's no track artist.
+			if t.Artist == "" {
+				t.Artist = mp4Text(typ, value)
+			}
+		case "\xa9alb":
+			t.Album = mp4Text(typ, value)
+		case "covr":
+			if t.Cover != nil || len(value) == 0 {
+				continue
+			}
+
+			t.Cover = value
+			switch typ {
+			case mp4JPEG:
+				t.CoverType = "image/jpeg"
+			case mp4PNG:
+				t.CoverType = "image/png"
+			}
+		}
+	}
+
+	return t, nil
+}
+
+func mp4Text(typ uint32, value []byte) string {
+	if typ != mp4UTF8 {
+		return ""
+	}
+
+	return strings.TrimSpace(string(value))
+}
@@ -0,0 +1,108 @@
+package mediatags
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The NFO files rarely get bigger than a few KBs.
+const maxNFOSize = 1 << 20
+
+// nfo - The details we need of the Kodi NFO files of movies
+// (<movie>), episodes (<episodedetails>) and music videos
+// (<musicvideo>). They all share these elements.
+type nfo struct {
+	Title     string   `xml:"title"`
+	Year      string   `xml:"year"`
+	Premiered string   `xml:"premiered"`
+	Aired     string   `xml:"aired"`
+	Plot      string   `xml:"plot"`
+	Outline   string   `xml:"outline"`
+	Genres    []string `xml:"genre"`
+	Artist    string   `xml:"artist"`
+	Album     string   `xml:"album"`
+}
+
+// ReadNFO - Read the details of a video from its Kodi NFO file. Some
+// NFO files only hold a scraper URL, possibly after the XML, so we
+// only read the first element.
+func ReadNFO(r io.Reader) (*Tags, error) {
+	var n nfo
+
+	d := xml.NewDecoder(io.LimitReader(r, maxNFOSize))
+	// The NFO files are UTF-8, but some claim otherwise.
+	d.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	if err := d.Decode(&n); err != nil {
+		return nil, fmt.Errorf("ReadNFO decode error: %w", err)
+	}
+
+	t := &Tags{
+		Title:  strings.TrimSpace(n.Title),
+		Artist: strings.TrimSpace(n.Artist),
+		Album:  strings.TrimSpace(n.Album),
+		Plot:   strings.TrimSpace(n.Plot),
+	}
+
+	if t.Plot == "" {
+		t.Plot = strings.TrimSpace(n.Outline)
+	}
+
+	for _, d := range []string{n.Premiered, n.Aired, n.Year} {
+		if d = strings.TrimSpace(d); d != "" {
+			t.Date = d
+			break
+		}
+	}
+
+	for _, g := range n.Genres {
+		// Some scrapers put all the genres in one element.
+		for _, g := range strings.Split(g, " / ") {
+			if g = strings.TrimSpace(g); g != "" {
+				t.Genres = append(t.Genres, g)
+			}
+		}
+	}
+
+	if t.Title == "" && t.Plot == "" && t.Date == "" && len(t.Genres) == 0 {
+		return nil, errors.New("readNFO: no details")
+	}
+
+	return t, nil
+}
+
+// LoadVideo - The details of a video from the Kodi sidecars next to it,
+// i.e. <name>.nfo or movie.nfo, and <name>-poster.jpg or poster.jpg,
+// falling back to the fanart and the folder cover. Videos without an
+// NFO file get empty details, as they may still have a poster.
+func LoadVideo(f string) *Tags {
+	dir := filepath.Dir(f)
+	name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+
+	t := &Tags{}
+
+	if nfoFile := sidecar(dir, name+".nfo", "movie.nfo"); nfoFile != "" {
+		if file, err := os.Open(nfoFile); err == nil {
+			if n, err := ReadNFO(file); err == nil {
+				t = n
+			}
+			file.Close()
+		}
+	}
+
+	var artwork []string
+	for _, a := range []string{name + "-poster", "poster", name + "-fanart", "fanart", "folder", "cover"} {
+		artwork = append(artwork, a+".jpg", a+".png")
+	}
+
+	t.CoverFile = sidecar(dir, artwork...)
+
+	return t
+}
@@ -0,0 +1,244 @@
+package mediatags
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	flacVorbisComment = 4
+	flacPicture       = 6
+	// The front cover picture type of the FLAC and ID3v2 pictures.
+	frontCover = 3
+	oggPageHdr = 27
+)
+
+func readFLAC(r io.ReaderAt, size int64) (*Tags, error) {
+	t := &Tags{}
+	off := int64(4)
+
+	for off+4 <= size {
+		hdr, err := readAt(r, off, 4)
+		if err != nil {
+			return nil, err
+		}
+
+		last, typ := hdr[0]&0x80 != 0, hdr[0]&0x7f
+		blockSize := int64(hdr[1])<<16 | int64(hdr[2])<<8 | int64(hdr[3])
+		off += 4
+
+		switch typ {
+		case flacVorbisComment:
+			b, err := readAt(r, off, blockSize)
+			if err != nil {
+				return nil, err
+			}
+			vorbisComments(b, t)
+		case flacPicture:
+			b, err := readAt(r, off, blockSize)
+			if err != nil {
+				return nil, err
+			}
+			addFLACPicture(b, t)
+		}
+
+		if last {
+			break
+		}
+		off += blockSize
+	}
+
+	return t, nil
+}
+
+// readOgg reads the comment header of the first logical stream,
+// i.e. the second packet, of Ogg Vorbis and Opus files.
+func readOgg(r io.ReaderAt, size int64) (*Tags, error) {
+	var packet []byte
+	var serial uint32
+	packets := 0
+	off := int64(0)
+
+	for off+oggPageHdr <= size {
+		hdr, err := readAt(r, off, oggPageHdr)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(hdr[:4], []byte("OggS")) {
+			return nil, errors.New("readOgg: invalid page")
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(hdr[14:18])
+		if off == 0 {
+			serial = pageSerial
+		}
+
+		segments, err := readAt(r, off+oggPageHdr, int64(hdr[26]))
+		if err != nil {
+			return nil, err
+		}
+		off += oggPageHdr + int64(len(segments))
+
+		for _, seg := range segments {
+			if pageSerial == serial && packets == 1 {
+				b, err := readAt(r, off, int64(seg))
+				if err != nil {
+					return nil, err
+				}
+
+				if len(packet)+len(b) > maxTagSize {
+					return nil, errors.New("readOgg: tag too large")
+				}
+				packet = append(packet, b...)
+			}
+			off += int64(seg)
+
+			// A segment shorter than 255 bytes ends the packet.
+			if pageSerial == serial && seg < 255 {
+				packets++
+				if packets == 2 {
+					return oggComments(packet)
+				}
+			}
+		}
+	}
+
+	return nil, ErrUnsupported
+}
+
+func oggComments(packet []byte) (*Tags, error) {
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		packet = packet[7:]
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		packet = packet[8:]
+	default:
+		return nil, ErrUnsupported
+	}
+
+	t := &Tags{}
+	vorbisComments(packet, t)
+
+	return t, nil
+}
+
+// vorbisComments reads the comments of the FLAC, Ogg Vorbis and Opus
+// files. The cover art is a base64 encoded FLAC picture block.
+func vorbisComments(b []byte, t *Tags) {
+	next := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+
+		n := binary.LittleEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, false
+		}
+
+		v := b[4 : 4+n]
+		b = b[4+n:]
+
+		return v, true
+	}
+
+	// The vendor string.
+	if _, ok := next(); !ok || len(b) < 4 {
+		return
+	}
+
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	for i := uint32(0); i < count; i++ {
+		c, ok := next()
+		if !ok {
+			return
+		}
+
+		kv := strings.SplitN(string(c), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(kv[1])
+
+		switch strings.ToUpper(kv[0]) {
+		case "TITLE":
+			if t.Title == "" {
+				t.Title = value
+			}
+		case "ARTIST":
+			if t.Artist == "" {
+				t.Artist = value
+			}
+		case "ALBUM":
+			if t.Album == "" {
+				t.Album = value
+			}
+		case "METADATA_BLOCK_PICTURE":
+			pic, err := base64.StdEncoding.DecodeString(value)
+			if err == nil {
+				addFLACPicture(pic, t)
+			}
+		}
+	}
+}
+
+// addFLACPicture keeps the picture as the cover art, unless
+// we already have the front cover.
+func addFLACPicture(b []byte, t *Tags) {
+	if len(b) < 8 {
+		return
+	}
+
+	typ := binary.BigEndian.Uint32(b)
+	b = b[4:]
+
+	field := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, false
+		}
+
+		v := b[4 : 4+n]
+		b = b[4+n:]
+
+		return v, true
+	}
+
+	mediaType, ok := field()
+	if !ok {
+		return
+	}
+
+	// The description.
+	if _, ok := field(); !ok {
+		return
+	}
+
+	// The width, height, color depth and number of colors.
+	if len(b) < 16 {
+		return
+	}
+	b = b[16:]
+
+	data, ok := field()
+	if !ok || len(data) == 0 {
+		return
+	}
+
+	if t.Cover != nil && typ != frontCover {
+		return
+	}
+
+	t.Cover, t.CoverType = data, strings.ToLower(string(mediaType))
+}
@@ -0,0 +1,104 @@
+package slideshow
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/imageconv"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+// Caster - Casts the slides to the Media Renderer of a casting
+// session, via the session server. Each slide gets a new media
+// path, while the callback of the session stays the same.
+type Caster struct {
+	Server *httphandlers.HTTPserver
+	// The payload of the first slide. It holds the
+	// event subscriptions of the casting session.
+	TV *soapcalls.TVPayload
+	// The screen of the Media Renderer callbacks,
+	// usually wrapped by the Slideshow Screen.
+	Screen    httphandlers.Screen
+	Advertise string
+	// The photos that the Media Renderer can't show
+	// as they are get converted to this profile.
+	Profile imageconv.Profile
+	FFmpeg  string
+
+	mu      sync.Mutex
+	current *soapcalls.TVPayload
+}
+
+// Cast - Serve the slide and switch the Media Renderer to it.
+// We stop serving the previous slide.
+func (c *Caster) Cast(path string, resubscribe bool) error {
+	mediaType, err := utils.GetMimeDetailsFromFile(path)
+	if err != nil {
+		return fmt.Errorf("Cast error: %w", err)
+	}
+
+	var media interface{} = path
+	var dlnaProfile string
+	name := path
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cast open error: %w", err)
+	}
+	convert := imageconv.NeedsConversion(f, mediaType, c.Profile)
+	f.Close()
+
+	if convert {
+		media = imageconv.NewFile(path, c.Profile, c.FFmpeg)
+		mediaType = imageconv.MediaType
+		dlnaProfile = c.Profile.Name
+		name = imageconv.FileName(path)
+	}
+
+	// Tokenized paths, so that nobody can guess them.
+	mediaPath, err := utils.TokenizedPath(name)
+	if err != nil {
+		return fmt.Errorf("Cast error: %w", err)
+	}
+
+	next := c.TV.WithMedia(utils.BuildHTTPURL(c.Advertise, mediaPath), mediaType, dlnaProfile)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		c.current = c.TV
+	}
+
+	// The next slide takes over the callback path.
+	c.Server.RemoveItem(c.current)
+	if err := c.Server.AddItem(next, media, nil, c.Screen); err != nil {
+		return fmt.Errorf("Cast error: %w", err)
+	}
+	c.current = next
+
+	if resubscribe {
+		if err := c.TV.SubscribeSoapCall(""); err != nil {
+			return fmt.Errorf("Cast subscribe error: %w", err)
+		}
+	}
+
+	if err := next.ChangeMediaSoapCall(); err != nil {
+		return fmt.Errorf("Cast error: %w", err)
+	}
+
+	return nil
+}
+
+// Close - Stop serving the current slide.
+func (c *Caster) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		c.Server.RemoveItem(c.current)
+	}
+}
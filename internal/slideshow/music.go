@@ -0,0 +1,83 @@
+package slideshow
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/httphandlers"
+	"github.com/alexballas/go2tv/internal/soapcalls"
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+// CastMusic - Play the background music on a second Media Renderer.
+// We don't subscribe to its events, so its playback doesn't affect
+// the slideshow. The music is served from the slideshow server.
+func CastMusic(s *httphandlers.HTTPserver, file, dmrURL, whereToAdvertise string) (*soapcalls.TVPayload, error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	mediaType, err := utils.GetMimeDetailsFromFile(absFile)
+	if err != nil {
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	upnpServicesURLs, err := soapcalls.DMRextractor(dmrURL)
+	if err != nil {
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	callbackPath, err := utils.RandomString()
+	if err != nil {
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	mediaPath, err := utils.TokenizedPath(absFile)
+	if err != nil {
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	music := &soapcalls.TVPayload{
+		ControlURL:          upnpServicesURLs.AvtransportControlURL,
+		EventURL:            upnpServicesURLs.AvtransportEventSubURL,
+		RenderingControlURL: upnpServicesURLs.RenderingControlURL,
+		CallbackURL:         utils.BuildHTTPURL(whereToAdvertise, callbackPath),
+		MediaURL:            utils.BuildHTTPURL(whereToAdvertise, mediaPath),
+		MediaType:           mediaType,
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+
+	if err := s.AddItem(music, absFile, nil, musicScreen{}); err != nil {
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	if err := music.ChangeMediaSoapCall(); err != nil {
+		s.RemoveItem(music)
+		return nil, fmt.Errorf("CastMusic error: %w", err)
+	}
+
+	return music, nil
+}
+
+// StopMusic - Stop the background music and stop serving it. We
+// never subscribed to the events of its Media Renderer, so there
+// are no subscriptions to end.
+func StopMusic(s *httphandlers.HTTPserver, music *soapcalls.TVPayload) error {
+	err := music.SendtoTV("Stop")
+	s.RemoveItem(music)
+
+	if err != nil {
+		return fmt.Errorf("StopMusic error: %w", err)
+	}
+
+	return nil
+}
+
+// musicScreen - The screen of the background music. Without
+// a subscription, there are no events to show.
+type musicScreen struct{}
+
+func (musicScreen) EmitMsg(string) {}
+func (musicScreen) Fini()          {}
@@ -0,0 +1,312 @@
+// Package slideshow casts a folder, or a playlist, of photos to a Media
+// Renderer, one after the other, advancing on a timer.
+package slideshow
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The Media Renderers may report that they stopped while they switch
+// to the next slide. We ignore the stops for that long after a switch.
+const switchGrace = 5 * time.Second
+
+// Extensions - The photo formats of the slideshows.
+var Extensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".heif", ".bmp", ".tif", ".tiff"}
+
+// Screen - The screen of the casting session, i.e.
+// the httphandlers.Screen of the Media Renderer callbacks.
+type Screen interface {
+	EmitMsg(string)
+	Fini()
+}
+
+// Slideshow - Advances through the slides on a timer. The slides
+// wrap around, so the slideshow runs until we stop it.
+type Slideshow struct {
+	mu       sync.Mutex
+	slides   []string
+	pos      int
+	interval time.Duration
+	timer    *time.Timer
+	paused   bool
+	stopped  bool
+	// The last slide switch, and whether we missed a stop
+	// event of the Media Renderer during it.
+	switched    time.Time
+	resubscribe bool
+
+	// Only one slide switch at a time.
+	moveMu sync.Mutex
+	cast   func(path string, resubscribe bool) error
+
+	// OnSlide, when not nil, gets called after every slide switch.
+	OnSlide func(pos, total int, path string, err error)
+}
+
+// New - Create a new Slideshow. cast casts a slide to the Media
+// Renderer. resubscribe asks it to subscribe to the Media Renderer
+// events, i.e. for the first slide, and again when the callback
+// handler drops the subscriptions as the Media Renderer stops
+// between the slides.
+func New(slides []string, interval time.Duration, shuffle bool, cast func(path string, resubscribe bool) error) (*Slideshow, error) {
+	if len(slides) == 0 {
+		return nil, errors.New("new: no slides")
+	}
+
+	if interval <= 0 {
+		return nil, errors.New("new: invalid slide duration")
+	}
+
+	s := &Slideshow{
+		slides:   append([]string(nil), slides...),
+		interval: interval,
+		cast:     cast,
+		// We subscribe along with the first slide.
+		resubscribe: true,
+	}
+
+	if shuffle {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(s.slides), func(i, j int) {
+			s.slides[i], s.slides[j] = s.slides[j], s.slides[i]
+		})
+	}
+
+	return s, nil
+}
+
+// Start - Cast the first slide and then advance every slide duration.
+func (s *Slideshow) Start() error {
+	return s.move(func(int) int { return 0 })
+}
+
+// Next - Switch to the next slide.
+func (s *Slideshow) Next() error {
+	return s.move(func(pos int) int { return pos + 1 })
+}
+
+// Previous - Switch to the previous slide.
+func (s *Slideshow) Previous() error {
+	return s.move(func(pos int) int { return pos - 1 })
+}
+
+// TogglePause - Pause or resume the slideshow.
+// It returns whether the slideshow is paused.
+func (s *Slideshow) TogglePause() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = !s.paused
+	s.schedule()
+
+	return s.paused
+}
+
+// Stop - Stop advancing. The Media Renderer keeps the current slide.
+func (s *Slideshow) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	s.schedule()
+}
+
+// Position - The current slide, starting from 1, and the number of slides.
+func (s *Slideshow) Position() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pos + 1, len(s.slides)
+}
+
+func (s *Slideshow) move(to func(pos int) int) error {
+	s.moveMu.Lock()
+	defer s.moveMu.Unlock()
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	s.pos = (to(s.pos) + len(s.slides)) % len(s.slides)
+	pos, path := s.pos, s.slides[s.pos]
+	resubscribe := s.resubscribe
+	s.resubscribe = false
+	s.switched = time.Now()
+	s.mu.Unlock()
+
+	err := s.cast(path, resubscribe)
+
+	s.mu.Lock()
+	s.switched = time.Now()
+	s.schedule()
+	onSlide := s.OnSlide
+	s.mu.Unlock()
+
+	if onSlide != nil {
+		onSlide(pos+1, len(s.slides), path, err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("move error: %w", err)
+	}
+
+	return nil
+}
+
+// schedule expects the lock to be held.
+func (s *Slideshow) schedule() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	if s.paused || s.stopped {
+		return
+	}
+
+	s.timer = time.AfterFunc(s.interval, func() {
+		s.Next()
+	})
+}
+
+// switching - Whether we just switched slides.
+func (s *Slideshow) switching() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.stopped && time.Since(s.switched) < switchGrace
+}
+
+func (s *Slideshow) missedStop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resubscribe = true
+}
+
+// Screen - Wrap the screen of the casting session, so that the Media
+// Renderer stopping while it switches slides doesn't end the session.
+// When the Media Renderer stops for good, we stop the slideshow too.
+func (s *Slideshow) Screen(scr Screen) Screen {
+	return &slideScreen{Screen: scr, show: s}
+}
+
+type slideScreen struct {
+	Screen
+	show *Slideshow
+}
+
+func (w *slideScreen) EmitMsg(msg string) {
+	if msg == "Stopped" && w.show.switching() {
+		return
+	}
+
+	w.Screen.EmitMsg(msg)
+}
+
+func (w *slideScreen) Fini() {
+	if w.show.switching() {
+		w.show.missedStop()
+		return
+	}
+
+	w.show.Stop()
+	w.Screen.Fini()
+}
+
+// IsImage - Whether the file is a photo, by its extension.
+func IsImage(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadSlides - The photos of a folder, sorted by name, or the photos
+// of an M3U playlist, in order. Relative playlist entries are relative
+// to the playlist folder.
+func LoadSlides(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadSlides error: %w", err)
+	}
+
+	var slides []string
+
+	switch fi.IsDir() {
+	case true:
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("LoadSlides read error: %w", err)
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() && IsImage(e.Name()) {
+				slides = append(slides, filepath.Join(path, e.Name()))
+			}
+		}
+
+		sort.Strings(slides)
+	default:
+		slides, err = loadPlaylist(path)
+		if err != nil {
+			return nil, fmt.Errorf("LoadSlides error: %w", err)
+		}
+	}
+
+	if len(slides) == 0 {
+		return nil, errors.New("loadSlides: no photos in " + path)
+	}
+
+	return slides, nil
+}
+
+func loadPlaylist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadPlaylist open error: %w", err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+
+	var slides []string
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") || !IsImage(line) {
+			continue
+		}
+
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+
+		slides = append(slides, line)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("loadPlaylist read error: %w", err)
+	}
+
+	return slides, nil
+}
@@ -0,0 +1,197 @@
+package slideshow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadSlides(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"b.PNG", "a.jpg", "notes.txt", "c.heic"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.jpg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	playlist := filepath.Join(dir, "show.m3u")
+	m3u := "\ufeff#EXTM3U\n#EXTINF:-1,First\nc.heic\n\nsong.mp3\n/photos/abs.jpg\n"
+	if err := os.WriteFile(playlist, []byte(m3u), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		input string
+		want  []string
+		name  string
+	}{
+		{dir, []string{"a.jpg", "b.PNG", "c.heic"}, `Folder`},
+		{playlist, []string{"c.heic", "/photos/abs.jpg"}, `Playlist`},
+	}
+
+	for _, tc := range tt {
+		slides, err := LoadSlides(tc.input)
+		if err != nil {
+			t.Errorf("%s: LoadSlides error: %s", tc.name, err)
+			continue
+		}
+
+		var got []string
+		for _, s := range slides {
+			if strings.HasPrefix(s, dir) {
+				s = filepath.Base(s)
+			}
+			got = append(got, s)
+		}
+
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := LoadSlides(t.TempDir()); err == nil {
+		t.Errorf("expected error for a folder without photos")
+	}
+}
+
+type castLog struct {
+	mu    sync.Mutex
+	casts []string
+}
+
+func (c *castLog) cast(path string, resubscribe bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if resubscribe {
+		path += "+sub"
+	}
+	c.casts = append(c.casts, path)
+
+	return nil
+}
+
+func (c *castLog) get() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return strings.Join(c.casts, ",")
+}
+
+func TestSlideshow(t *testing.T) {
+	var log castLog
+
+	s, err := New([]string{"a", "b", "c"}, time.Hour, false, log.cast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+	s.Next()
+	s.Previous()
+	s.Previous()
+
+	if got, want := log.get(), "a+sub,b,a,c"; got != want {
+		t.Errorf("Navigation: got: %s, want: %s.", got, want)
+	}
+
+	if pos, total := s.Position(); pos != 3 || total != 3 {
+		t.Errorf("Position: got: %d/%d, want: 3/3.", pos, total)
+	}
+
+	if !s.TogglePause() || s.TogglePause() {
+		t.Errorf("TogglePause: expected paused and then resumed")
+	}
+
+	s.Stop()
+	s.Next()
+
+	if got, want := log.get(), "a+sub,b,a,c"; got != want {
+		t.Errorf("Stopped: got: %s, want: %s.", got, want)
+	}
+
+	if _, err := New(nil, time.Second, false, log.cast); err == nil {
+		t.Errorf("expected error for no slides")
+	}
+}
+
+func TestSlideshowTimer(t *testing.T) {
+	var log castLog
+
+	s, err := New([]string{"a", "b"}, 20*time.Millisecond, false, log.cast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	s.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(log.get()) < len("a+sub,b,a") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got, want := log.get(), "a+sub,b,a"; !strings.HasPrefix(got, want) {
+		t.Errorf("got: %s, want: %s.", got, want)
+	}
+}
+
+type fakeScreen struct {
+	msgs []string
+	fini int
+}
+
+func (f *fakeScreen) EmitMsg(msg string) { f.msgs = append(f.msgs, msg) }
+func (f *fakeScreen) Fini()              { f.fini++ }
+
+func TestScreen(t *testing.T) {
+	var log castLog
+
+	s, err := New([]string{"a", "b"}, time.Hour, false, log.cast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeScreen{}
+	scr := s.Screen(fake)
+
+	s.Start()
+
+	// The Media Renderer stops while it switches slides.
+	scr.EmitMsg("Stopped")
+	scr.Fini()
+	scr.EmitMsg("Playing")
+
+	if fake.fini != 0 || strings.Join(fake.msgs, ",") != "Playing" {
+		t.Errorf("Switching: got: %v and %d Fini calls, want: [Playing] and none.", fake.msgs, fake.fini)
+	}
+
+	// We subscribe again along with the next slide.
+	s.Next()
+	if got, want := log.get(), "a+sub,b+sub"; got != want {
+		t.Errorf("Resubscribe: got: %s, want: %s.", got, want)
+	}
+
+	// The Media Renderer stops for good.
+	s.mu.Lock()
+	s.switched = time.Now().Add(-switchGrace)
+	s.mu.Unlock()
+
+	scr.EmitMsg("Stopped")
+	scr.Fini()
+
+	if fake.fini != 1 {
+		t.Errorf("Stopped: got: %d Fini calls, want: 1.", fake.fini)
+	}
+
+	s.Next()
+	if got, want := log.get(), "a+sub,b+sub"; got != want {
+		t.Errorf("Stopped slideshow: got: %s, want: %s.", got, want)
+	}
+}
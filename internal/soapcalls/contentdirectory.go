@@ -0,0 +1,423 @@
+package soapcalls
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxBrowseEntries caps the number of entries we fetch
+// for a single container to keep huge libraries usable.
+const maxBrowseEntries = 5000
+
+// didlNamespace is the default namespace of the DIDL-Lite documents.
+const didlNamespace = "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/"
+
+// CDSextracted .
+type CDSextracted struct {
+	ContentDirectoryControlURL string
+}
+
+// CDSObject - A ContentDirectory container or item.
+type CDSObject struct {
+	ID          string
+	ParentID    string
+	Title       string
+	Class       string
+	Res         []CDSRes
+	IsContainer bool
+	// Metadata is the DIDL-Lite document of the item.
+	// It is empty for containers.
+	Metadata string
+}
+
+// CDSRes - A resource of a ContentDirectory item.
+type CDSRes struct {
+	URL          string
+	ProtocolInfo string
+}
+
+// MediaType returns the mime type part of the protocolInfo.
+func (r CDSRes) MediaType() string {
+	parts := strings.Split(r.ProtocolInfo, ":")
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return parts[2]
+}
+
+// PlayableRes returns the resource that matches the item
+// class, e.g. the video resource for video items. That way
+// we skip thumbnails and subtitles.
+func (o CDSObject) PlayableRes() (CDSRes, error) {
+	var prefix string
+	switch {
+	case strings.HasPrefix(o.Class, "object.item.videoItem"):
+		prefix = "video/"
+	case strings.HasPrefix(o.Class, "object.item.audioItem"):
+		prefix = "audio/"
+	case strings.HasPrefix(o.Class, "object.item.imageItem"):
+		prefix = "image/"
+	}
+
+	for _, r := range o.Res {
+		if prefix != "" && strings.HasPrefix(r.MediaType(), prefix) {
+			return r, nil
+		}
+	}
+
+	if len(o.Res) > 0 {
+		return o.Res[0], nil
+	}
+
+	return CDSRes{}, errors.New("no playable resource")
+}
+
+type didlDocument struct {
+	XMLName    xml.Name      `xml:"DIDL-Lite"`
+	Containers []didlElement `xml:"container"`
+	Items      []didlElement `xml:"item"`
+}
+
+type didlElement struct {
+	ID         string    `xml:"id,attr"`
+	ParentID   string    `xml:"parentID,attr"`
+	Restricted string    `xml:"restricted,attr"`
+	Title      string    `xml:"title"`
+	Class      string    `xml:"class"`
+	Res        []didlRes `xml:"res"`
+}
+
+type didlRes struct {
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type cdsRespEnvelope struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Body    struct {
+		Response struct {
+			Result         string `xml:"Result"`
+			NumberReturned int    `xml:"NumberReturned"`
+			TotalMatches   int    `xml:"TotalMatches"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// CDSextractor - Get the ContentDirectory control URL
+// from the main Media Server xml.
+func CDSextractor(dmsurl string) (*CDSextracted, error) {
+	var root Root
+
+	parsedURL, err := url.Parse(dmsurl)
+	if err != nil {
+		return nil, fmt.Errorf("CDSextractor parse error: %w", err)
+	}
+
+	client := &http.Client{}
+	req, err := http.NewRequest(http.MethodGet, dmsurl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CDSextractor GET error: %w", err)
+	}
+
+	req.Header.Set("Connection", "close")
+
+	xmlresp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("CDSextractor Do GET error: %w", err)
+	}
+	defer xmlresp.Body.Close()
+
+	xmlbody, err := io.ReadAll(xmlresp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("CDSextractor read error: %w", err)
+	}
+
+	if err := xml.Unmarshal(xmlbody, &root); err != nil {
+		return nil, fmt.Errorf("CDSextractor unmarshal error: %w", err)
+	}
+
+	for _, srv := range root.Device.ServiceList.Services {
+		if srv.ID == "urn:upnp-org:serviceId:ContentDirectory" {
+			return &CDSextracted{
+				ContentDirectoryControlURL: serviceURL(parsedURL, srv.ControlURL),
+			}, nil
+		}
+	}
+
+	return nil, errors.New("no ContentDirectory service - wrong Media Server URL?")
+}
+
+// BrowseSoapCall - Return the direct children of the container.
+// The root container of every Media Server has the "0" object ID.
+func BrowseSoapCall(controlURL, objectID string) ([]CDSObject, error) {
+	out, err := cdsPagedCall(controlURL, "Browse", func(start int) ([]byte, error) {
+		return browseSoapBuild(objectID, "BrowseDirectChildren", start, 0)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("BrowseSoapCall error: %w", err)
+	}
+
+	return out, nil
+}
+
+// SearchSoapCall - Search the container using the UPnP
+// search criteria, e.g. dc:title contains "holiday".
+func SearchSoapCall(controlURL, containerID, criteria string) ([]CDSObject, error) {
+	out, err := cdsPagedCall(controlURL, "Search", func(start int) ([]byte, error) {
+		return searchSoapBuild(containerID, criteria, start, 0)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SearchSoapCall error: %w", err)
+	}
+
+	return out, nil
+}
+
+// cdsPagedCall keeps calling the action until we get all
+// the entries. Many servers cap the returned entries, even
+// if we request all of them.
+func cdsPagedCall(controlURL, action string, build func(int) ([]byte, error)) ([]CDSObject, error) {
+	out := make([]CDSObject, 0)
+
+	for {
+		xmlbuilder, err := build(len(out))
+		if err != nil {
+			return nil, fmt.Errorf("cdsPagedCall build error: %w", err)
+		}
+
+		resp, err := cdsSoapCall(controlURL, action, xmlbuilder)
+		if err != nil {
+			return nil, err
+		}
+
+		objects, err := ParseDIDL(resp.Body.Response.Result)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, objects...)
+
+		if resp.Body.Response.NumberReturned == 0 || len(objects) == 0 ||
+			len(out) >= resp.Body.Response.TotalMatches || len(out) >= maxBrowseEntries {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+func cdsSoapCall(controlURL, action string, body []byte) (*cdsRespEnvelope, error) {
+	parsedControlURL, err := url.Parse(controlURL)
+	if err != nil {
+		return nil, fmt.Errorf("cdsSoapCall parse error: %w", err)
+	}
+
+	client := soapClient()
+	req, err := http.NewRequest("POST", parsedControlURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cdsSoapCall POST error: %w", err)
+	}
+
+	req.Header = http.Header{
+		"SOAPAction":   []string{`"urn:schemas-upnp-org:service:ContentDirectory:1#` + action + `"`},
+		"content-type": []string{"text/xml"},
+		"charset":      []string{"utf-8"},
+		"Connection":   []string{"close"},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cdsSoapCall Do POST error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("cdsSoapCall bad status code: " + resp.Status)
+	}
+
+	var envelope cdsRespEnvelope
+	if err := xml.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("cdsSoapCall XML Decode error: %w", err)
+	}
+
+	return &envelope, nil
+}
+
+// TitleSearchCriteria - The UPnP search criteria for the
+// objects whose title contains the query. Quotes and
+// backslashes need to be escaped.
+func TitleSearchCriteria(q string) string {
+	q = strings.ReplaceAll(q, `\`, `\\`)
+	q = strings.ReplaceAll(q, `"`, `\"`)
+	return `dc:title contains "` + q + `"`
+}
+
+// ParseDIDL - Parse the DIDL-Lite document of a Browse or Search result.
+func ParseDIDL(didl string) ([]CDSObject, error) {
+	var doc didlDocument
+	if err := xml.Unmarshal([]byte(didl), &doc); err != nil {
+		return nil, fmt.Errorf("ParseDIDL unmarshal error: %w", err)
+	}
+
+	metadata, err := itemsMetadata(didl)
+	if err != nil {
+		return nil, fmt.Errorf("ParseDIDL metadata error: %w", err)
+	}
+
+	if len(metadata) != len(doc.Items) {
+		return nil, errors.New("parseDIDL: unexpected item elements")
+	}
+
+	out := make([]CDSObject, 0, len(doc.Containers)+len(doc.Items))
+	for _, c := range doc.Containers {
+		out = append(out, CDSObject{
+			ID:          c.ID,
+			ParentID:    c.ParentID,
+			Title:       c.Title,
+			Class:       c.Class,
+			IsContainer: true,
+		})
+	}
+
+	for q, i := range doc.Items {
+		o := CDSObject{
+			ID:       i.ID,
+			ParentID: i.ParentID,
+			Title:    i.Title,
+			Class:    i.Class,
+			Metadata: metadata[q],
+		}
+
+		for _, r := range i.Res {
+			o.Res = append(o.Res, CDSRes{
+				URL:          strings.TrimSpace(r.Value),
+				ProtocolInfo: r.ProtocolInfo,
+			})
+		}
+
+		out = append(out, o)
+	}
+
+	return out, nil
+}
+
+// itemsMetadata wraps every item of the DIDL-Lite document in a
+// document of its own, so that we can pass it to SetAVTransportURI.
+// We re-encode the raw tokens, so the items keep their namespace
+// prefixes, along with the namespaces the DIDL-Lite element declares.
+func itemsMetadata(didl string) ([]string, error) {
+	d := xml.NewDecoder(strings.NewReader(didl))
+
+	var (
+		out   []string
+		root  []xml.Attr
+		item  *strings.Builder
+		depth int
+	)
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("itemsMetadata token error: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+
+			switch {
+			case depth == 1:
+				root = namespaceAttrs(t.Attr)
+			case depth == 2 && t.Name.Local == "item":
+				item = &strings.Builder{}
+				writeStartElement(item, xml.StartElement{Name: xml.Name{Local: "DIDL-Lite"}, Attr: root})
+
+				if !hasAttr(t.Attr, "restricted") {
+					t.Attr = append(t.Attr, xml.Attr{Name: xml.Name{Local: "restricted"}, Value: "1"})
+				}
+			}
+
+			if item != nil {
+				writeStartElement(item, t)
+			}
+		case xml.EndElement:
+			if item != nil {
+				item.WriteString("</" + qualifiedName(t.Name) + ">")
+
+				if depth == 2 {
+					item.WriteString("</DIDL-Lite>")
+					out = append(out, item.String())
+					item = nil
+				}
+			}
+
+			depth--
+		case xml.CharData:
+			if item != nil {
+				xml.EscapeText(item, t)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// namespaceAttrs returns the namespace declarations out of the
+// attributes. The default DIDL-Lite namespace is always there.
+func namespaceAttrs(attrs []xml.Attr) []xml.Attr {
+	out := []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: didlNamespace}}
+
+	for _, a := range attrs {
+		switch {
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			out[0].Value = a.Value
+		case a.Name.Space == "xmlns":
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+func hasAttr(attrs []xml.Attr, name string) bool {
+	for _, a := range attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// qualifiedName returns the name with its namespace prefix,
+// as the raw tokens have it.
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return n.Space + ":" + n.Local
+}
+
+func writeStartElement(b *strings.Builder, t xml.StartElement) {
+	b.WriteString("<" + qualifiedName(t.Name))
+
+	for _, a := range t.Attr {
+		b.WriteString(" " + qualifiedName(a.Name) + `="`)
+		xml.EscapeText(b, []byte(a.Value))
+		b.WriteString(`"`)
+	}
+
+	b.WriteString(">")
+}
@@ -0,0 +1,119 @@
+package soapcalls
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDIDL = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+	`<container id="64" parentID="0" restricted="1"><dc:title>Movies</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>` +
+	`<item id="64$1" parentID="64" restricted="1"><dc:title>Holiday</dc:title><upnp:class>object.item.videoItem</upnp:class>` +
+	`<res protocolInfo="http-get:*:image/jpeg:DLNA.ORG_PN=JPEG_TN">http://192.168.1.5:8200/Thumbnails/1.jpg</res>` +
+	`<res protocolInfo="http-get:*:video/mp4:*">http://192.168.1.5:8200/MediaItems/1.mp4</res></item>` +
+	`</DIDL-Lite>`
+
+func TestParseDIDL(t *testing.T) {
+	out, err := ParseDIDL(testDIDL)
+	if err != nil {
+		t.Fatalf("Failed to call ParseDIDL due to %s", err.Error())
+	}
+
+	if len(out) != 2 {
+		t.Fatalf("got: %d objects, want: 2.", len(out))
+	}
+
+	if !out[0].IsContainer || out[0].Title != "Movies" {
+		t.Errorf("got: %+v, want the Movies container.", out[0])
+	}
+
+	res, err := out[1].PlayableRes()
+	if err != nil {
+		t.Fatalf("Failed to call PlayableRes due to %s", err.Error())
+	}
+
+	if res.URL != "http://192.168.1.5:8200/MediaItems/1.mp4" {
+		t.Errorf("got: %s, want: %s.", res.URL, "http://192.168.1.5:8200/MediaItems/1.mp4")
+	}
+
+	if res.MediaType() != "video/mp4" {
+		t.Errorf("got: %s, want: %s.", res.MediaType(), "video/mp4")
+	}
+
+	want := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		`<item id="64$1" parentID="64" restricted="1"><dc:title>Holiday</dc:title>`
+	if !strings.HasPrefix(out[1].Metadata, want) {
+		t.Errorf("unexpected item metadata: %s", out[1].Metadata)
+	}
+
+	if _, err := ParseDIDL(out[1].Metadata); err != nil {
+		t.Errorf("item metadata is not a valid DIDL-Lite document: %s", err.Error())
+	}
+}
+
+func TestItemsMetadata(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			`Server namespaces`,
+			`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:pv="http://www.pv.com/pvns/">` +
+				`<item id="1" parentID="0"><dc:title>A &amp; B</dc:title><pv:rating x="&quot;"/></item></DIDL-Lite>`,
+			`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:pv="http://www.pv.com/pvns/">` +
+				`<item id="1" parentID="0" restricted="1"><dc:title>A &amp; B</dc:title><pv:rating x="&#34;"></pv:rating></item></DIDL-Lite>`,
+		},
+		{
+			`Item namespaces`,
+			`<DIDL-Lite><item id="1" parentID="0" restricted="0" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>A</dc:title></item></DIDL-Lite>`,
+			`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/">` +
+				`<item id="1" parentID="0" restricted="0" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>A</dc:title></item></DIDL-Lite>`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := itemsMetadata(tc.input)
+		if err != nil {
+			t.Errorf("%s: itemsMetadata error: %s", tc.name, err)
+			continue
+		}
+
+		if len(out) != 1 || out[0] != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+}
+
+func TestTitleSearchCriteria(t *testing.T) {
+	if out := TitleSearchCriteria(`say "hi" \o/`); out != `dc:title contains "say \"hi\" \\o/"` {
+		t.Errorf("got: %s, want: %s.", out, `dc:title contains "say \"hi\" \\o/"`)
+	}
+}
+
+func TestBrowseSoapCall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("SOAPAction") != `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"` {
+			http.Error(w, "wrong action", http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+			`<u:BrowseResponse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><Result>%s</Result>`+
+			`<NumberReturned>2</NumberReturned><TotalMatches>2</TotalMatches><UpdateID>1</UpdateID></u:BrowseResponse></s:Body></s:Envelope>`,
+			html.EscapeString(testDIDL))
+	}))
+	defer srv.Close()
+
+	out, err := BrowseSoapCall(srv.URL, "0")
+	if err != nil {
+		t.Fatalf("Failed to call BrowseSoapCall due to %s", err.Error())
+	}
+
+	if len(out) != 2 {
+		t.Errorf("got: %d objects, want: 2.", len(out))
+	}
+}
@@ -0,0 +1,89 @@
+package soapcalls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+var (
+	dumpMu     sync.Mutex
+	dumpWriter io.Writer
+)
+
+// DumpSOAP - Write the full SOAP and event subscription requests,
+// and the responses of the devices, to w, e.g. for bug reports.
+// A nil w stops the dumps.
+func DumpSOAP(w io.Writer) {
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	dumpWriter = w
+}
+
+// dumpTransport - Dump the requests that go through it,
+// along with their responses, when the dumps are enabled.
+type dumpTransport struct {
+	next http.RoundTripper
+}
+
+func (d dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dumpMu.Lock()
+	w := dumpWriter
+	dumpMu.Unlock()
+
+	if w == nil {
+		return d.next.RoundTrip(req)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s %s %s\n", time.Now().Format("2006-01-02 15:04:05"), req.Method, req.URL)
+
+	// The dumps restore the bodies that they read.
+	reqDump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return nil, fmt.Errorf("dumpTransport request error: %w", err)
+	}
+	b.Write(reqDump)
+	b.WriteString("\n\n")
+
+	resp, err := d.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "error: %s\n", err)
+	default:
+		respDump, dumpErr := httputil.DumpResponse(resp, true)
+		if dumpErr != nil {
+			fmt.Fprintf(&b, "response dump error: %s\n", dumpErr)
+		}
+		b.Write(respDump)
+		b.WriteString("\n\n")
+	}
+
+	// A single write, so that the concurrent dumps don't mix.
+	dumpMu.Lock()
+	w.Write(b.Bytes())
+	dumpMu.Unlock()
+
+	return resp, err
+}
+
+// soapClient - The client for the calls that we don't retry.
+func soapClient() *http.Client {
+	return &http.Client{Transport: dumpTransport{next: http.DefaultTransport}}
+}
+
+// soapRetryClient - The client for the calls that we retry.
+func soapRetryClient() *http.Client {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 3
+	retryClient.Logger = nil
+	retryClient.HTTPClient.Transport = dumpTransport{next: retryClient.HTTPClient.Transport}
+
+	return retryClient.StandardClient()
+}
@@ -0,0 +1,59 @@
+package soapcalls
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDumpSOAP(t *testing.T) {
+	device := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte("<reply>"), body...))
+	}))
+	defer device.Close()
+
+	var dump bytes.Buffer
+	DumpSOAP(&dump)
+	defer DumpSOAP(nil)
+
+	for _, client := range []*http.Client{soapClient(), soapRetryClient()} {
+		dump.Reset()
+
+		resp, err := client.Post(device.URL, "text/xml", strings.NewReader("<request/>"))
+		if err != nil {
+			t.Fatalf("Post error: %s", err)
+		}
+
+		// The device still gets the request body and
+		// we still get the response body.
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != "<reply><request/>" {
+			t.Errorf("Response body: got: %s, want: %s.", body, "<reply><request/>")
+		}
+
+		for _, want := range []string{"POST / HTTP/1.1", "<request/>", "200 OK", "<reply><request/>"} {
+			if !strings.Contains(dump.String(), want) {
+				t.Errorf("Dump: %q missing from: %s.", want, dump.String())
+			}
+		}
+	}
+
+	DumpSOAP(nil)
+	dump.Reset()
+
+	resp, err := soapClient().Post(device.URL, "text/xml", strings.NewReader("<request/>"))
+	if err != nil {
+		t.Fatalf("Post error: %s", err)
+	}
+	resp.Body.Close()
+
+	if dump.Len() != 0 {
+		t.Errorf("Disabled dump: got: %s, want nothing.", dump.String())
+	}
+}
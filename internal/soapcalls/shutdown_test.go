@@ -0,0 +1,137 @@
+package soapcalls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	var mu sync.Mutex
+	var actions []string
+
+	device := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		actions = append(actions, r.Header.Get("SOAPAction")+" "+string(body))
+		mu.Unlock()
+	}))
+	defer device.Close()
+
+	tt := []struct {
+		stop  bool
+		calls int
+		name  string
+	}{
+		{true, 1, `Stop the Media Renderer`},
+		{false, 0, `Media Renderer already stopped`},
+	}
+
+	for _, tc := range tt {
+		mu.Lock()
+		actions = nil
+		mu.Unlock()
+
+		tv := &TVPayload{
+			ControlURL:    device.URL,
+			CurrentTimers: make(map[string]*time.Timer),
+		}
+
+		fired := make(chan struct{}, 1)
+		tv.setTimer("uuid:1", time.AfterFunc(50*time.Millisecond, func() { fired <- struct{}{} }))
+
+		if err := tv.Shutdown(tc.stop); err != nil {
+			t.Fatalf("%s: Shutdown error: %s", tc.name, err)
+		}
+
+		// No refresh timers once we shut down.
+		tv.setTimer("uuid:2", time.AfterFunc(50*time.Millisecond, func() { fired <- struct{}{} }))
+		if len(tv.CurrentTimers) != 0 {
+			t.Errorf("%s: got: %d timers, want: 0.", tc.name, len(tv.CurrentTimers))
+		}
+
+		select {
+		case <-fired:
+			t.Errorf("%s: a timer fired after Shutdown", tc.name)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		mu.Lock()
+		calls := len(actions)
+		if calls == 1 && !strings.Contains(actions[0], "Stop") {
+			t.Errorf("%s: got: %s, want: a Stop action.", tc.name, actions[0])
+		}
+		mu.Unlock()
+
+		if calls != tc.calls {
+			t.Errorf("%s: got: %d calls, want: %d.", tc.name, calls, tc.calls)
+		}
+	}
+}
+
+func TestShutdownUnsubscribe(t *testing.T) {
+	var mu sync.Mutex
+	sids := make(map[string]string)
+
+	renderer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "UNSUBSCRIBE" {
+				return
+			}
+			mu.Lock()
+			sids[r.Header.Get("SID")] = name
+			mu.Unlock()
+		}))
+	}
+
+	first := renderer("first")
+	defer first.Close()
+
+	second := renderer("second")
+	defer second.Close()
+
+	CreateMRstate("shutdown-first", first.URL)
+	CreateMRstate("shutdown-second", second.URL)
+
+	tv := &TVPayload{
+		ControlURL:    first.URL,
+		EventURL:      first.URL,
+		CurrentTimers: make(map[string]*time.Timer),
+	}
+	tv.addSID("shutdown-first")
+
+	if err := tv.Shutdown(false); err != nil {
+		t.Fatalf("Shutdown error: %s", err)
+	}
+
+	unsubscribed := func(sid string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return sids[sid]
+	}
+
+	if got := unsubscribed("uuid:shutdown-first"); got != "first" {
+		t.Errorf("Subscription of the payload: got: %s, want: first.", got)
+	}
+
+	// The subscriptions of the other payloads survive.
+	if got := unsubscribed("uuid:shutdown-second"); got != "" {
+		t.Errorf("Subscription of another renderer: got: %s, want: none.", got)
+	}
+
+	if _, err := GetSequence("shutdown-second"); err != nil {
+		t.Errorf("Subscription of another renderer: state removed on Shutdown.")
+	}
+
+	if err := UnsubscribeAll(); err != nil {
+		t.Fatalf("UnsubscribeAll error: %s", err)
+	}
+
+	if got := unsubscribed("uuid:shutdown-second"); got != "second" {
+		t.Errorf("UnsubscribeAll: got: %s, want: second.", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/alexballas/go2tv/internal/utils"
 	"github.com/pkg/errors"
 )
 
@@ -124,6 +125,13 @@ type DIDLLiteItem struct {
 	Restricted       string           `xml:"restricted,attr"`
 	UPNPClass        string           `xml:"upnp:class"`
 	DCtitle          string           `xml:"dc:title"`
+	DCcreator        string           `xml:"dc:creator,omitempty"`
+	UPNPArtist       string           `xml:"upnp:artist,omitempty"`
+	UPNPAlbum        string           `xml:"upnp:album,omitempty"`
+	UPNPGenre        []string         `xml:"upnp:genre,omitempty"`
+	DCdate           string           `xml:"dc:date,omitempty"`
+	DCdescription    string           `xml:"dc:description,omitempty"`
+	UPNPAlbumArtURI  string           `xml:"upnp:albumArtURI,omitempty"`
 	ID               string           `xml:"id,attr"`
 	ParentID         string           `xml:"parentID,attr"`
 	ResNode          []ResNode        `xml:"res"`
@@ -240,7 +248,59 @@ type SetVolumeAction struct {
 	DesiredVolume    string
 }
 
-func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, error) {
+// BrowseEnvelope .
+type BrowseEnvelope struct {
+	XMLName    xml.Name   `xml:"s:Envelope"`
+	Schema     string     `xml:"xmlns:s,attr"`
+	Encoding   string     `xml:"s:encodingStyle,attr"`
+	BrowseBody BrowseBody `xml:"s:Body"`
+}
+
+// BrowseBody .
+type BrowseBody struct {
+	XMLName      xml.Name     `xml:"s:Body"`
+	BrowseAction BrowseAction `xml:"u:Browse"`
+}
+
+// BrowseAction .
+type BrowseAction struct {
+	XMLName          xml.Name `xml:"u:Browse"`
+	ContentDirectory string   `xml:"xmlns:u,attr"`
+	ObjectID         string
+	BrowseFlag       string
+	Filter           string
+	StartingIndex    int
+	RequestedCount   int
+	SortCriteria     string
+}
+
+// SearchEnvelope .
+type SearchEnvelope struct {
+	XMLName    xml.Name   `xml:"s:Envelope"`
+	Schema     string     `xml:"xmlns:s,attr"`
+	Encoding   string     `xml:"s:encodingStyle,attr"`
+	SearchBody SearchBody `xml:"s:Body"`
+}
+
+// SearchBody .
+type SearchBody struct {
+	XMLName      xml.Name     `xml:"s:Body"`
+	SearchAction SearchAction `xml:"u:Search"`
+}
+
+// SearchAction .
+type SearchAction struct {
+	XMLName          xml.Name `xml:"u:Search"`
+	ContentDirectory string   `xml:"xmlns:u,attr"`
+	ContainerID      string
+	SearchCriteria   string
+	Filter           string
+	StartingIndex    int
+	RequestedCount   int
+	SortCriteria     string
+}
+
+func setAVTransportSoapBuild(mediaURL, mediaType, dlnaProfile, subtitleURL string, meta ItemMetadata) ([]byte, error) {
 	mediaTypeSlice := strings.Split(mediaType, "/")
 
 	var class string
@@ -265,6 +325,19 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 	}
 	mediaTitle = re.ReplaceAllString(mediaTitle, "")
 
+	// The media tags make for a better title than the media path.
+	if title := re.ReplaceAllString(meta.Title, ""); title != "" {
+		mediaTitle = title
+	}
+	artist := re.ReplaceAllString(meta.Artist, "")
+
+	var genres []string
+	for _, g := range meta.Genres {
+		if g = re.ReplaceAllString(g, ""); g != "" {
+			genres = append(genres, g)
+		}
+	}
+
 	l := DIDLLite{
 		XMLName:    xml.Name{},
 		SchemaDIDL: "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
@@ -278,9 +351,18 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 			Restricted: "false",
 			UPNPClass:  class,
 			DCtitle:    mediaTitle,
+			DCcreator:  artist,
+			UPNPArtist: artist,
+			UPNPAlbum:  re.ReplaceAllString(meta.Album, ""),
+			UPNPGenre:  genres,
+			DCdate:     re.ReplaceAllString(meta.Date, ""),
+			// The plot of a video.
+			DCdescription: re.ReplaceAllString(meta.Description, ""),
+			// The Media Renderers show the album art, or the poster, as the thumbnail.
+			UPNPAlbumArtURI: meta.AlbumArtURL,
 			ResNode: []ResNode{{
 				XMLName:      xml.Name{},
-				ProtocolInfo: fmt.Sprintf("http-get:*:%s:*", mediaType),
+				ProtocolInfo: utils.ProtocolInfo(mediaType, dlnaProfile),
 				Value:        mediaURL,
 			}, {
 				XMLName:      xml.Name{},
@@ -305,6 +387,26 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 		return nil, fmt.Errorf("setAVTransportSoapBuild #1 Marshal error: %w", err)
 	}
 
+	b, err := setAVTransportEnvelopeBuild(mediaURL, a)
+	if err != nil {
+		return nil, fmt.Errorf("setAVTransportSoapBuild #2 error: %w", err)
+	}
+
+	return b, nil
+}
+
+// setAVTransportMetadataSoapBuild passes the DIDL-Lite metadata
+// straight through. Used for items that we get from Media Servers.
+func setAVTransportMetadataSoapBuild(mediaURL, metadata string) ([]byte, error) {
+	b, err := setAVTransportEnvelopeBuild(mediaURL, []byte(metadata))
+	if err != nil {
+		return nil, fmt.Errorf("setAVTransportMetadataSoapBuild error: %w", err)
+	}
+
+	return b, nil
+}
+
+func setAVTransportEnvelopeBuild(mediaURL string, metadata []byte) ([]byte, error) {
 	d := SetAVTransportEnvelope{
 		XMLName:  xml.Name{},
 		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
@@ -318,7 +420,7 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 				CurrentURI:  mediaURL,
 				CurrentURIMetaData: CurrentURIMetaData{
 					XMLName: xml.Name{},
-					Value:   a,
+					Value:   metadata,
 				},
 			},
 		},
@@ -326,7 +428,7 @@ func setAVTransportSoapBuild(mediaURL, mediaType, subtitleURL string) ([]byte, e
 	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
 	b, err := xml.Marshal(d)
 	if err != nil {
-		return nil, fmt.Errorf("setAVTransportSoapBuild #2 Marshal error: %w", err)
+		return nil, fmt.Errorf("setAVTransportEnvelopeBuild Marshal error: %w", err)
 	}
 
 	// Samsung TV hack.
@@ -509,3 +611,63 @@ func setVolumeSoapBuild(v string) ([]byte, error) {
 
 	return append(xmlStart, b...), nil
 }
+
+func browseSoapBuild(objectID, browseFlag string, start, count int) ([]byte, error) {
+	if browseFlag != "BrowseDirectChildren" && browseFlag != "BrowseMetadata" {
+		return nil, errors.New("browseSoapBuild input error. Was expecting BrowseDirectChildren or BrowseMetadata.")
+	}
+
+	d := BrowseEnvelope{
+		XMLName:  xml.Name{},
+		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
+		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
+		BrowseBody: BrowseBody{
+			XMLName: xml.Name{},
+			BrowseAction: BrowseAction{
+				XMLName:          xml.Name{},
+				ContentDirectory: "urn:schemas-upnp-org:service:ContentDirectory:1",
+				ObjectID:         objectID,
+				BrowseFlag:       browseFlag,
+				Filter:           "*",
+				StartingIndex:    start,
+				RequestedCount:   count,
+				SortCriteria:     "",
+			},
+		},
+	}
+	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
+	b, err := xml.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("browseSoapBuild Marshal error: %w", err)
+	}
+
+	return append(xmlStart, b...), nil
+}
+
+func searchSoapBuild(containerID, criteria string, start, count int) ([]byte, error) {
+	d := SearchEnvelope{
+		XMLName:  xml.Name{},
+		Schema:   "http://schemas.xmlsoap.org/soap/envelope/",
+		Encoding: "http://schemas.xmlsoap.org/soap/encoding/",
+		SearchBody: SearchBody{
+			XMLName: xml.Name{},
+			SearchAction: SearchAction{
+				XMLName:          xml.Name{},
+				ContentDirectory: "urn:schemas-upnp-org:service:ContentDirectory:1",
+				ContainerID:      containerID,
+				SearchCriteria:   criteria,
+				Filter:           "*",
+				StartingIndex:    start,
+				RequestedCount:   count,
+				SortCriteria:     "",
+			},
+		},
+	}
+	xmlStart := []byte("<?xml version='1.0' encoding='utf-8'?>")
+	b, err := xml.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("searchSoapBuild Marshal error: %w", err)
+	}
+
+	return append(xmlStart, b...), nil
+}
@@ -9,20 +9,51 @@ func TestSetAVTransportSoapBuild(t *testing.T) {
 		name        string
 		mediaURL    string
 		mediaType   string
+		dlnaProfile string
 		subtitleURL string
+		meta        ItemMetadata
 		want        string
 	}{
 		{
 			`setAVTransportSoapBuild Test #1`,
 			`http://192.168.88.250:3500/video%20%26%20%27example%27.mp4`,
 			"video/mp4",
+			"",
 			"http://192.168.88.250:3500/video_example.srt",
+			ItemMetadata{},
 			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><CurrentURI>http://192.168.88.250:3500/video%20%26%20%27example%27.mp4</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sec="http://www.sec.co.kr/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"&gt;&lt;item restricted="false" id="0" parentID="-1"&gt;&lt;sec:CaptionInfo sec:type="srt"&gt;http://192.168.88.250:3500/video_example.srt&lt;/sec:CaptionInfo&gt;&lt;sec:CaptionInfoEx sec:type="srt"&gt;http://192.168.88.250:3500/video_example.srt&lt;/sec:CaptionInfoEx&gt;&lt;upnp:class&gt;object.item.videoItem.movie&lt;/upnp:class&gt;&lt;dc:title&gt;video  &#39;example&#39;.mp4&lt;/dc:title&gt;&lt;res protocolInfo="http-get:*:video/mp4:*"&gt;http://192.168.88.250:3500/video%20%26%20%27example%27.mp4&lt;/res&gt;&lt;res protocolInfo="http-get:*:text/srt:*"&gt;http://192.168.88.250:3500/video_example.srt&lt;/res&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;</CurrentURIMetaData></u:SetAVTransportURI></s:Body></s:Envelope>`,
 		},
+		{
+			`setAVTransportSoapBuild Test #2`,
+			`http://192.168.88.250:3500/video.mp4`,
+			"video/mp4",
+			"AVC_MP4_HP_HD_AAC",
+			"http://192.168.88.250:3500/video.srt",
+			ItemMetadata{},
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><CurrentURI>http://192.168.88.250:3500/video.mp4</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sec="http://www.sec.co.kr/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"&gt;&lt;item restricted="false" id="0" parentID="-1"&gt;&lt;sec:CaptionInfo sec:type="srt"&gt;http://192.168.88.250:3500/video.srt&lt;/sec:CaptionInfo&gt;&lt;sec:CaptionInfoEx sec:type="srt"&gt;http://192.168.88.250:3500/video.srt&lt;/sec:CaptionInfoEx&gt;&lt;upnp:class&gt;object.item.videoItem.movie&lt;/upnp:class&gt;&lt;dc:title&gt;video.mp4&lt;/dc:title&gt;&lt;res protocolInfo="http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_HD_AAC"&gt;http://192.168.88.250:3500/video.mp4&lt;/res&gt;&lt;res protocolInfo="http-get:*:text/srt:*"&gt;http://192.168.88.250:3500/video.srt&lt;/res&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;</CurrentURIMetaData></u:SetAVTransportURI></s:Body></s:Envelope>`,
+		},
+		{
+			`setAVTransportSoapBuild Test #3`,
+			`http://192.168.88.250:3500/song.mp3`,
+			"audio/mpeg",
+			"MP3",
+			"",
+			ItemMetadata{Title: "Song & Dance", Artist: "Artist", Album: "Album", AlbumArtURL: "http://192.168.88.250:3500/cover.jpg"},
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><CurrentURI>http://192.168.88.250:3500/song.mp3</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sec="http://www.sec.co.kr/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"&gt;&lt;item restricted="false" id="0" parentID="-1"&gt;&lt;sec:CaptionInfo sec:type="srt"&gt;&lt;/sec:CaptionInfo&gt;&lt;sec:CaptionInfoEx sec:type="srt"&gt;&lt;/sec:CaptionInfoEx&gt;&lt;upnp:class&gt;object.item.audioItem.musicTrack&lt;/upnp:class&gt;&lt;dc:title&gt;Song  Dance&lt;/dc:title&gt;&lt;dc:creator&gt;Artist&lt;/dc:creator&gt;&lt;upnp:artist&gt;Artist&lt;/upnp:artist&gt;&lt;upnp:album&gt;Album&lt;/upnp:album&gt;&lt;upnp:albumArtURI&gt;http://192.168.88.250:3500/cover.jpg&lt;/upnp:albumArtURI&gt;&lt;res protocolInfo="http-get:*:audio/mpeg:DLNA.ORG_PN=MP3"&gt;http://192.168.88.250:3500/song.mp3&lt;/res&gt;&lt;res protocolInfo="http-get:*:text/srt:*"&gt;&lt;/res&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;</CurrentURIMetaData></u:SetAVTransportURI></s:Body></s:Envelope>`,
+		},
+		{
+			`setAVTransportSoapBuild Test #4`,
+			`http://192.168.88.250:3500/movie.mkv`,
+			"video/x-matroska",
+			"",
+			"",
+			ItemMetadata{Title: "Movie", Date: "2008", Description: "A <giant> rabbit.", Genres: []string{"Animation", "Comedy"}, AlbumArtURL: "http://192.168.88.250:3500/cover.jpg"},
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><CurrentURI>http://192.168.88.250:3500/movie.mkv</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sec="http://www.sec.co.kr/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"&gt;&lt;item restricted="false" id="0" parentID="-1"&gt;&lt;sec:CaptionInfo sec:type="srt"&gt;&lt;/sec:CaptionInfo&gt;&lt;sec:CaptionInfoEx sec:type="srt"&gt;&lt;/sec:CaptionInfoEx&gt;&lt;upnp:class&gt;object.item.videoItem.movie&lt;/upnp:class&gt;&lt;dc:title&gt;Movie&lt;/dc:title&gt;&lt;upnp:genre&gt;Animation&lt;/upnp:genre&gt;&lt;upnp:genre&gt;Comedy&lt;/upnp:genre&gt;&lt;dc:date&gt;2008&lt;/dc:date&gt;&lt;dc:description&gt;A giant rabbit.&lt;/dc:description&gt;&lt;upnp:albumArtURI&gt;http://192.168.88.250:3500/cover.jpg&lt;/upnp:albumArtURI&gt;&lt;res protocolInfo="http-get:*:video/x-matroska:*"&gt;http://192.168.88.250:3500/movie.mkv&lt;/res&gt;&lt;res protocolInfo="http-get:*:text/srt:*"&gt;&lt;/res&gt;&lt;/item&gt;&lt;/DIDL-Lite&gt;</CurrentURIMetaData></u:SetAVTransportURI></s:Body></s:Envelope>`,
+		},
 	}
 
 	for _, tc := range tt {
-		out, err := setAVTransportSoapBuild(tc.mediaURL, tc.mediaType, tc.subtitleURL)
+		out, err := setAVTransportSoapBuild(tc.mediaURL, tc.mediaType, tc.dlnaProfile, tc.subtitleURL, tc.meta)
 		if err != nil {
 			t.Errorf("%s: Failed to call setAVTransportSoapBuild due to %s", tc.name, err.Error())
 			return
@@ -88,3 +119,35 @@ func TestGetVolumeSoapBuild(t *testing.T) {
 		}
 	}
 }
+
+func TestBrowseSoapBuild(t *testing.T) {
+	tt := []struct {
+		name     string
+		objectID string
+		flag     string
+		want     string
+	}{
+		{
+			`browseSoapBuild Test #1`,
+			"0",
+			"BrowseDirectChildren",
+			`<?xml version='1.0' encoding='utf-8'?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><ObjectID>0</ObjectID><BrowseFlag>BrowseDirectChildren</BrowseFlag><Filter>*</Filter><StartingIndex>0</StartingIndex><RequestedCount>0</RequestedCount><SortCriteria></SortCriteria></u:Browse></s:Body></s:Envelope>`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := browseSoapBuild(tc.objectID, tc.flag, 0, 0)
+		if err != nil {
+			t.Errorf("%s: Failed to call browseSoapBuild due to %s", tc.name, err.Error())
+			return
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+			return
+		}
+	}
+
+	if _, err := browseSoapBuild("0", "Wrong", 0, 0); err == nil {
+		t.Errorf("browseSoapBuild Test #2: expected error for wrong browse flag")
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 )
 
@@ -20,6 +19,9 @@ type states struct {
 	previousState string
 	newState      string
 	sequence      int
+	// The subscription belongs to the Media Renderer
+	// at eventURL, which we unsubscribe from.
+	eventURL string
 }
 
 var (
@@ -39,6 +41,33 @@ type TVPayload struct {
 	RenderingControlURL string
 	MediaURL            string
 	MediaType           string
+	DLNAProfile         string
+	MediaMetadata       string
+	// Metadata - The details of the media, e.g. from
+	// the audio tags, for the DIDL-Lite metadata.
+	Metadata ItemMetadata
+	// The subscription refresh timers fire on their own
+	// goroutines. We stop refreshing once we shut down.
+	timersMu sync.Mutex
+	closed   bool
+	// sids - The event subscriptions that this payload created,
+	// which we end on Shutdown. Also guarded by timersMu.
+	sids map[string]bool
+}
+
+// ItemMetadata - The details of the media that we pass to the Media
+// Renderer along with the media URL. The empty fields are left out.
+type ItemMetadata struct {
+	Title  string
+	Artist string
+	Album  string
+	// Date - The release date, or just the year.
+	Date        string
+	Description string
+	Genres      []string
+	// AlbumArtURL - The URL of the album art, or the poster
+	// of a video, which we serve next to the media.
+	AlbumArtURL string
 }
 
 // GetMuteRespBody - Build the GetMute response body
@@ -79,15 +108,20 @@ func (p *TVPayload) setAVTransportSoapCall() error {
 		return fmt.Errorf("setAVTransportSoapCall parse error: %w", err)
 	}
 
-	xml, err := setAVTransportSoapBuild(p.MediaURL, p.MediaType, p.SubtitlesURL)
+	// When we have the DIDL-Lite metadata from a Media Server,
+	// we pass it as-is instead of building our own.
+	var xml []byte
+	switch p.MediaMetadata {
+	case "":
+		xml, err = setAVTransportSoapBuild(p.MediaURL, p.MediaType, p.DLNAProfile, p.SubtitlesURL, p.Metadata)
+	default:
+		xml, err = setAVTransportMetadataSoapBuild(p.MediaURL, p.MediaMetadata)
+	}
 	if err != nil {
 		return fmt.Errorf("setAVTransportSoapCall soap build error: %w", err)
 	}
 
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
-	retryClient.Logger = nil
-	client := retryClient.StandardClient()
+	client := soapRetryClient()
 
 	req, err := http.NewRequest("POST", parsedURLtransport.String(), bytes.NewReader(xml))
 	if err != nil {
@@ -132,13 +166,10 @@ func (p *TVPayload) playStopPauseSoapCall(action string) error {
 		return fmt.Errorf("playStopPauseSoapCall action error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := soapClient()
 
 	if retry {
-		retryClient := retryablehttp.NewClient()
-		retryClient.RetryMax = 3
-		retryClient.Logger = nil
-		client = retryClient.StandardClient()
+		client = soapRetryClient()
 	}
 
 	req, err := http.NewRequest("POST", parsedURLtransport.String(), bytes.NewReader(xml))
@@ -164,7 +195,7 @@ func (p *TVPayload) playStopPauseSoapCall(action string) error {
 // SubscribeSoapCall - Subscribe to a media renderer
 // If we explicitly pass the uuid, then we refresh it instead.
 func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
-	delete(p.CurrentTimers, uuidInput)
+	p.deleteTimer(uuidInput)
 
 	parsedURLcontrol, err := url.Parse(p.EventURL)
 	if err != nil {
@@ -176,11 +207,7 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 		return fmt.Errorf("SubscribeSoapCall #2 parse error: %w", err)
 	}
 
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
-	retryClient.Logger = nil
-
-	client := retryClient.StandardClient()
+	client := soapRetryClient()
 
 	req, err := http.NewRequest("SUBSCRIBE", parsedURLcontrol.String(), nil)
 	if err != nil {
@@ -221,7 +248,7 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 			// we clean up any remaining states for the specific
 			// uuid. The actual UNSUBSCRIBE request to the media
 			// renderer may still fail with error 412, but it's fine.
-			p.UnsubscribeSoapCall(uuid)
+			p.UnsubscribeSoapCall(uuidInput)
 		}
 		return nil
 	}
@@ -239,7 +266,8 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 	// We don't really need to initialize or set
 	// the State if we're just refreshing the uuid.
 	if uuidInput == "" {
-		CreateMRstate(uuid)
+		CreateMRstate(uuid, parsedURLcontrol.String())
+		p.addSID(uuid)
 	}
 
 	timeoutReply := "300"
@@ -255,18 +283,56 @@ func (p *TVPayload) SubscribeSoapCall(uuidInput string) error {
 // UnsubscribeSoapCall - exported that as we use
 // it for the callback stuff in the httphandlers package.
 func (p *TVPayload) UnsubscribeSoapCall(uuid string) error {
+	p.deleteSID(uuid)
+
+	if err := unsubscribe(p.EventURL, uuid); err != nil {
+		return fmt.Errorf("UnsubscribeSoapCall error: %w", err)
+	}
+
+	return nil
+}
+
+// UnsubscribeAll - End all the event subscriptions, of all
+// the Media Renderers, e.g. when we exit. We go through all
+// of them even when some fail, and return the first error.
+func UnsubscribeAll() error {
+	mu.RLock()
+	uuids := make([]string, 0, len(mediaRenderersStates))
+	for uuid := range mediaRenderersStates {
+		uuids = append(uuids, uuid)
+	}
+	mu.RUnlock()
+
+	var firstErr error
+	for _, uuid := range uuids {
+		if err := unsubscribe("", uuid); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("UnsubscribeAll error: %w", err)
+		}
+	}
+
+	return firstErr
+}
+
+// unsubscribe sends the UNSUBSCRIBE request to the Media Renderer
+// that we subscribed to, falling back to eventURL for the uuids
+// that we no longer track.
+func unsubscribe(eventURL, uuid string) error {
+	if u, exists := mrEventURL(uuid); exists {
+		eventURL = u
+	}
+
 	DeleteMRstate(uuid)
 
-	parsedURLcontrol, err := url.Parse(p.EventURL)
+	parsedURLcontrol, err := url.Parse(eventURL)
 	if err != nil {
-		return fmt.Errorf("UnsubscribeSoapCall parse error: %w", err)
+		return fmt.Errorf("unsubscribe parse error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := soapClient()
 
 	req, err := http.NewRequest("UNSUBSCRIBE", parsedURLcontrol.String(), nil)
 	if err != nil {
-		return fmt.Errorf("UnsubscribeSoapCall UNSUBSCRIBE error: %w", err)
+		return fmt.Errorf("unsubscribe UNSUBSCRIBE error: %w", err)
 	}
 
 	req.Header = http.Header{
@@ -278,7 +344,7 @@ func (p *TVPayload) UnsubscribeSoapCall(uuid string) error {
 
 	_, err = client.Do(req)
 	if err != nil {
-		return fmt.Errorf("UnsubscribeSoapCall Do UNSUBSCRIBE error: %w", err)
+		return fmt.Errorf("unsubscribe Do UNSUBSCRIBE error: %w", err)
 	}
 
 	return nil
@@ -302,7 +368,7 @@ func (p *TVPayload) RefreshLoopUUIDSoapCall(uuid, timeout string) error {
 	// function arguments.
 	f := p.refreshLoopUUIDAsyncSoapCall(uuid)
 	timer := time.AfterFunc(triggerTimefunc, f)
-	p.CurrentTimers[uuid] = timer
+	p.setTimer(uuid, timer)
 
 	return nil
 }
@@ -327,7 +393,7 @@ func (p *TVPayload) GetMuteSoapCall() (string, error) {
 		return "", fmt.Errorf("GetMuteSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := soapClient()
 	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
 	if err != nil {
 		return "", fmt.Errorf("GetMuteSoapCall POST error: %w", err)
@@ -369,7 +435,7 @@ func (p *TVPayload) SetMuteSoapCall(number string) error {
 		return fmt.Errorf("SetMuteSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := soapClient()
 	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
 	if err != nil {
 		return fmt.Errorf("SetMuteSoapCall POST error: %w", err)
@@ -404,7 +470,7 @@ func (p *TVPayload) GetVolumeSoapCall() (int, error) {
 		return 0, fmt.Errorf("GetVolumeSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := soapClient()
 	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
 	if err != nil {
 		return 0, fmt.Errorf("GetVolumeSoapCall POST error: %w", err)
@@ -455,7 +521,7 @@ func (p *TVPayload) SetVolumeSoapCall(v string) error {
 		return fmt.Errorf("SetMuteSoapCall build error: %w", err)
 	}
 
-	client := &http.Client{}
+	client := soapClient()
 	req, err := http.NewRequest("POST", parsedRenderingControlURL.String(), bytes.NewReader(xmlbuilder))
 	if err != nil {
 		return fmt.Errorf("SetMuteSoapCall POST error: %w", err)
@@ -507,10 +573,7 @@ func (p *TVPayload) SendtoTV(action string) error {
 		// Clear timers on Stop to avoid errors responses
 		// from the media renderers. If we don't clear those, we
 		// might receive a "412 Precondition Failed" error.
-		for uuid, timer := range p.CurrentTimers {
-			timer.Stop()
-			delete(p.CurrentTimers, uuid)
-		}
+		p.stopTimers()
 	}
 	err := p.playStopPauseSoapCall(action)
 	if err != nil {
@@ -520,6 +583,130 @@ func (p *TVPayload) SendtoTV(action string) error {
 	return nil
 }
 
+// WithMedia - A payload for another media of the same casting
+// session, e.g. the next slide of a slideshow. The event
+// subscriptions of the session stay with p.
+func (p *TVPayload) WithMedia(mediaURL, mediaType, dlnaProfile string) *TVPayload {
+	return &TVPayload{
+		ControlURL:          p.ControlURL,
+		EventURL:            p.EventURL,
+		RenderingControlURL: p.RenderingControlURL,
+		CallbackURL:         p.CallbackURL,
+		MediaURL:            mediaURL,
+		MediaType:           mediaType,
+		DLNAProfile:         dlnaProfile,
+		CurrentTimers:       make(map[string]*time.Timer),
+	}
+}
+
+// ChangeMediaSoapCall - Switch the Media Renderer to our media and
+// play it. Unlike Play1, we don't subscribe to the events again, as
+// we expect the subscriptions of the casting session to remain.
+func (p *TVPayload) ChangeMediaSoapCall() error {
+	if err := p.setAVTransportSoapCall(); err != nil {
+		return fmt.Errorf("ChangeMediaSoapCall set AVT Transport error: %w", err)
+	}
+
+	if err := p.playStopPauseSoapCall("Play"); err != nil {
+		return fmt.Errorf("ChangeMediaSoapCall play error: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown - End the casting session. We cancel the subscription
+// refresh timers, send Stop when the Media Renderer still plays
+// the media and end the event subscriptions that we created. The
+// subscriptions of the other payloads stay, see UnsubscribeAll.
+// Unlike SendtoTV("Stop"), we go through all the steps even
+// when some of them fail, and return the first error.
+func (p *TVPayload) Shutdown(stop bool) error {
+	p.timersMu.Lock()
+	p.closed = true
+	p.timersMu.Unlock()
+
+	p.stopTimers()
+
+	var firstErr error
+
+	if stop {
+		if err := p.playStopPauseSoapCall("Stop"); err != nil {
+			firstErr = fmt.Errorf("Shutdown stop error: %w", err)
+		}
+	}
+
+	for _, uuid := range p.ownSIDs() {
+		if err := p.UnsubscribeSoapCall(uuid); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Shutdown unsubscribe error: %w", err)
+		}
+	}
+
+	return firstErr
+}
+
+func (p *TVPayload) addSID(uuid string) {
+	p.timersMu.Lock()
+	defer p.timersMu.Unlock()
+
+	if p.sids == nil {
+		p.sids = make(map[string]bool)
+	}
+	p.sids[uuid] = true
+}
+
+func (p *TVPayload) deleteSID(uuid string) {
+	p.timersMu.Lock()
+	defer p.timersMu.Unlock()
+	delete(p.sids, uuid)
+}
+
+// ownSIDs returns the subscriptions of the payload that are
+// still active, e.g. not ended by a STOPPED event.
+func (p *TVPayload) ownSIDs() []string {
+	p.timersMu.Lock()
+	defer p.timersMu.Unlock()
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	uuids := make([]string, 0, len(p.sids))
+	for uuid := range p.sids {
+		if initialMediaRenderersStates[uuid] {
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	return uuids
+}
+
+func (p *TVPayload) setTimer(uuid string, timer *time.Timer) {
+	p.timersMu.Lock()
+	defer p.timersMu.Unlock()
+
+	if p.closed {
+		timer.Stop()
+		return
+	}
+
+	p.CurrentTimers[uuid] = timer
+}
+
+func (p *TVPayload) deleteTimer(uuid string) {
+	p.timersMu.Lock()
+	defer p.timersMu.Unlock()
+	delete(p.CurrentTimers, uuid)
+}
+
+func (p *TVPayload) stopTimers() {
+	p.timersMu.Lock()
+	defer p.timersMu.Unlock()
+
+	for uuid, timer := range p.CurrentTimers {
+		timer.Stop()
+		delete(p.CurrentTimers, uuid)
+	}
+}
+
 // UpdateMRstate - Update the mediaRenderersStates map
 // with the state. Return true or false to verify that
 // the actual update took place.
@@ -544,7 +731,7 @@ func UpdateMRstate(previous, new, uuid string) bool {
 }
 
 // CreateMRstate .
-func CreateMRstate(uuid string) {
+func CreateMRstate(uuid, eventURL string) {
 	mu.Lock()
 	defer mu.Unlock()
 	initialMediaRenderersStates[uuid] = true
@@ -552,9 +739,20 @@ func CreateMRstate(uuid string) {
 		previousState: "",
 		newState:      "",
 		sequence:      0,
+		eventURL:      eventURL,
 	}
 }
 
+func mrEventURL(uuid string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if st, exists := mediaRenderersStates[uuid]; exists && st.eventURL != "" {
+		return st.eventURL, true
+	}
+
+	return "", false
+}
+
 // DeleteMRstate .
 func DeleteMRstate(uuid string) {
 	mu.Lock()
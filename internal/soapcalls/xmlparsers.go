@@ -99,12 +99,12 @@ func DMRextractor(dmrurl string) (*DMRextracted, error) {
 	xml.Unmarshal(xmlbody, &root)
 	for i := 0; i < len(root.Device.ServiceList.Services); i++ {
 		if root.Device.ServiceList.Services[i].ID == "urn:upnp-org:serviceId:AVTransport" {
-			ex.AvtransportControlURL = parsedURL.Scheme + "://" + parsedURL.Host + root.Device.ServiceList.Services[i].ControlURL
-			ex.AvtransportEventSubURL = parsedURL.Scheme + "://" + parsedURL.Host + root.Device.ServiceList.Services[i].EventSubURL
+			ex.AvtransportControlURL = serviceURL(parsedURL, root.Device.ServiceList.Services[i].ControlURL)
+			ex.AvtransportEventSubURL = serviceURL(parsedURL, root.Device.ServiceList.Services[i].EventSubURL)
 		}
 
 		if root.Device.ServiceList.Services[i].ID == "urn:upnp-org:serviceId:RenderingControl" {
-			ex.RenderingControlURL = parsedURL.Scheme + "://" + parsedURL.Host + root.Device.ServiceList.Services[i].ControlURL
+			ex.RenderingControlURL = serviceURL(parsedURL, root.Device.ServiceList.Services[i].ControlURL)
 		}
 	}
 
@@ -115,6 +115,25 @@ func DMRextractor(dmrurl string) (*DMRextracted, error) {
 	return nil, errors.New("something broke somewhere - wrong DMR URL?")
 }
 
+// serviceURL builds the service URL using the scheme and host
+// of the description URL. We rely on url.URL to build the
+// string so that IPv6 hosts and zones are properly escaped.
+func serviceURL(base *url.URL, p string) string {
+	ref, err := url.Parse(p)
+	if err != nil || ref.IsAbs() {
+		return p
+	}
+
+	u := url.URL{
+		Scheme:   base.Scheme,
+		Host:     base.Host,
+		Path:     ref.Path,
+		RawQuery: ref.RawQuery,
+	}
+
+	return u.String()
+}
+
 // EventNotifyParser - Parse the Notify messages from the media renderer.
 func EventNotifyParser(xmlbody string) (string, string, error) {
 	var root EventPropertySet
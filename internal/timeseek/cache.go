@@ -0,0 +1,78 @@
+package timeseek
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// The Media Renderers send a request for every seek, and
+// building the index can mean reading a few MBs, so we keep
+// the indexes of the recently served files around.
+const maxCachedIndexes = 64
+
+type cacheKey struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+type cacheEntry struct {
+	idx Index
+	err error
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[cacheKey]cacheEntry)
+)
+
+// OpenFile - The time seek Index of the media file. The index is
+// cached until the file gets modified.
+func OpenFile(path string) (Index, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("OpenFile stat error: %w", err)
+	}
+
+	key := cacheKey{path: path, modTime: info.ModTime(), size: info.Size()}
+
+	cacheMu.Lock()
+	entry, ok := cache[key]
+	cacheMu.Unlock()
+
+	if ok {
+		return entry.idx, entry.err
+	}
+
+	// The MPEG-TS indexes read the file on every
+	// seek, so they can't hold on to an open file.
+	idx, err := Open(&fileReader{path: path}, info.Size())
+
+	cacheMu.Lock()
+	if len(cache) >= maxCachedIndexes {
+		for k := range cache {
+			delete(cache, k)
+		}
+	}
+	cache[key] = cacheEntry{idx: idx, err: err}
+	cacheMu.Unlock()
+
+	return idx, err
+}
+
+// fileReader opens the file for every read.
+type fileReader struct {
+	path string
+}
+
+func (f *fileReader) ReadAt(p []byte, off int64) (int, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return file.ReadAt(p, off)
+}
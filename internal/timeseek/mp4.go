@@ -0,0 +1,290 @@
+package timeseek
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+// The moov box holds the sample tables. It's usually a few
+// hundred KBs, but long movies can get to a few MBs.
+const maxMoovSize = 64 << 20
+
+type mp4Track struct {
+	handler      string
+	timescale    uint32
+	duration     uint64
+	sttsCounts   []uint32
+	sttsDeltas   []uint32
+	syncSamples  []uint32
+	stscFirst    []uint32
+	stscSamples  []uint32
+	sampleSize   uint32
+	sampleSizes  []uint32
+	chunkOffsets []uint64
+}
+
+type mp4Index struct {
+	track    *mp4Track
+	duration time.Duration
+}
+
+func openMP4(r io.ReaderAt, size int64) (Index, error) {
+	moov, err := findMoov(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []*mp4Track
+	containers.EachBox(moov, func(typ string, b []byte) {
+		if typ == "trak" {
+			tracks = append(tracks, parseTrak(b))
+		}
+	})
+
+	// We seek on the video track, as we want to start at a
+	// keyframe. Audio only files use their audio track.
+	var track *mp4Track
+	for _, handler := range []string{"vide", "soun"} {
+		for _, t := range tracks {
+			if t.handler == handler && track == nil {
+				track = t
+			}
+		}
+	}
+
+	if track == nil || track.timescale == 0 || len(track.chunkOffsets) == 0 {
+		return nil, ErrUnsupported
+	}
+
+	// The sync samples are numbered from 1.
+	for _, s := range track.syncSamples {
+		if s == 0 {
+			return nil, errors.New("openMP4: invalid sync sample table")
+		}
+	}
+
+	return &mp4Index{
+		track:    track,
+		duration: ticksToDuration(track.duration, track.timescale),
+	}, nil
+}
+
+func (m *mp4Index) Duration() time.Duration {
+	return m.duration
+}
+
+func (m *mp4Index) Offset(t time.Duration) (int64, time.Duration, error) {
+	tr := m.track
+	ticks := uint64(t.Seconds() * float64(tr.timescale))
+
+	// Find the sample that plays at t.
+	var sample, elapsed uint64
+	for i, count := range tr.sttsCounts {
+		delta := uint64(tr.sttsDeltas[i])
+		span := uint64(count) * delta
+		if delta > 0 && elapsed+span > ticks {
+			sample += (ticks - elapsed) / delta
+			break
+		}
+		sample += uint64(count)
+		elapsed += span
+	}
+
+	// Go back to the closest keyframe. Without a sync
+	// sample table, every sample is a keyframe.
+	if len(tr.syncSamples) > 0 {
+		sync := uint64(tr.syncSamples[0] - 1)
+		for _, s := range tr.syncSamples {
+			if uint64(s-1) > sample {
+				break
+			}
+			sync = uint64(s - 1)
+		}
+		sample = sync
+	}
+
+	offset, err := tr.sampleOffset(sample)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return offset, ticksToDuration(tr.sampleTime(sample), tr.timescale), nil
+}
+
+func (tr *mp4Track) sampleTime(sample uint64) uint64 {
+	var elapsed, n uint64
+	for i, count := range tr.sttsCounts {
+		if n+uint64(count) > sample {
+			return elapsed + (sample-n)*uint64(tr.sttsDeltas[i])
+		}
+		n += uint64(count)
+		elapsed += uint64(count) * uint64(tr.sttsDeltas[i])
+	}
+
+	return elapsed
+}
+
+func (tr *mp4Track) sampleOffset(sample uint64) (int64, error) {
+	chunks := uint64(len(tr.chunkOffsets))
+
+	var first uint64
+	for i := range tr.stscFirst {
+		next := chunks + 1
+		if i+1 < len(tr.stscFirst) {
+			next = uint64(tr.stscFirst[i+1])
+		}
+
+		perChunk := uint64(tr.stscSamples[i])
+		run := (next - uint64(tr.stscFirst[i])) * perChunk
+		if perChunk == 0 || sample >= first+run {
+			first += run
+			continue
+		}
+
+		chunk := uint64(tr.stscFirst[i]) - 1 + (sample-first)/perChunk
+		if chunk >= chunks {
+			break
+		}
+
+		// The sample offset is the chunk offset plus the
+		// sizes of the chunk samples before it.
+		offset := tr.chunkOffsets[chunk]
+		for s := first + (sample-first)/perChunk*perChunk; s < sample; s++ {
+			offset += uint64(tr.size(s))
+		}
+
+		return int64(offset), nil
+	}
+
+	return 0, errors.New("sampleOffset: sample out of range")
+}
+
+func (tr *mp4Track) size(sample uint64) uint32 {
+	if tr.sampleSize != 0 {
+		return tr.sampleSize
+	}
+
+	if sample < uint64(len(tr.sampleSizes)) {
+		return tr.sampleSizes[sample]
+	}
+
+	return 0
+}
+
+func findMoov(r io.ReaderAt, size int64) ([]byte, error) {
+	moov, ok := containers.ChildBox(r, containers.Box{Size: size}, "moov")
+	if !ok || moov.Size > maxMoovSize {
+		return nil, ErrUnsupported
+	}
+
+	b := make([]byte, moov.Size)
+	if _, err := r.ReadAt(b, moov.Off); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func parseTrak(b []byte) *mp4Track {
+	t := &mp4Track{}
+
+	var walk func(b []byte)
+	walk = func(b []byte) {
+		containers.EachBox(b, func(typ string, body []byte) {
+			switch typ {
+			case "mdia", "minf", "stbl":
+				walk(body)
+			case "mdhd":
+				t.timescale, t.duration = parseMdhd(body)
+			case "hdlr":
+				if len(body) >= 12 {
+					t.handler = string(body[8:12])
+				}
+			case "stts":
+				pairs := fullBoxTable(body, 8)
+				for i := 0; i+1 < len(pairs); i += 2 {
+					t.sttsCounts = append(t.sttsCounts, pairs[i])
+					t.sttsDeltas = append(t.sttsDeltas, pairs[i+1])
+				}
+			case "stss":
+				t.syncSamples = fullBoxTable(body, 4)
+			case "stsc":
+				entries := fullBoxTable(body, 12)
+				for i := 0; i+2 < len(entries); i += 3 {
+					t.stscFirst = append(t.stscFirst, entries[i])
+					t.stscSamples = append(t.stscSamples, entries[i+1])
+				}
+			case "stsz":
+				if len(body) >= 12 {
+					t.sampleSize = binary.BigEndian.Uint32(body[4:8])
+					if t.sampleSize == 0 {
+						t.sampleSizes = fullBoxTable(body[4:], 4)
+					}
+				}
+			case "stco":
+				for _, o := range fullBoxTable(body, 4) {
+					t.chunkOffsets = append(t.chunkOffsets, uint64(o))
+				}
+			case "co64":
+				if len(body) >= 8 {
+					count := uint64(binary.BigEndian.Uint32(body[4:8]))
+					data := body[8:]
+					for i := uint64(0); i < count && (i+1)*8 <= uint64(len(data)); i++ {
+						t.chunkOffsets = append(t.chunkOffsets, binary.BigEndian.Uint64(data[i*8:]))
+					}
+				}
+			}
+		})
+	}
+	walk(b)
+
+	return t
+}
+
+func parseMdhd(b []byte) (uint32, uint64) {
+	if len(b) < 24 {
+		return 0, 0
+	}
+
+	if b[0] == 1 {
+		if len(b) < 36 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(b[20:24]), binary.BigEndian.Uint64(b[24:32])
+	}
+
+	return binary.BigEndian.Uint32(b[12:16]), uint64(binary.BigEndian.Uint32(b[16:20]))
+}
+
+// fullBoxTable reads the uint32 values of a full box table, i.e.
+// version and flags, the entry count and then the entries.
+func fullBoxTable(b []byte, entrySize int) []uint32 {
+	if len(b) < 8 {
+		return nil
+	}
+
+	count := int(binary.BigEndian.Uint32(b[4:8]))
+	data := b[8:]
+	if count*entrySize > len(data) || count < 0 {
+		count = len(data) / entrySize
+	}
+
+	out := make([]uint32, 0, count*entrySize/4)
+	for i := 0; i < count*entrySize; i += 4 {
+		out = append(out, binary.BigEndian.Uint32(data[i:]))
+	}
+
+	return out
+}
+
+func ticksToDuration(ticks uint64, timescale uint32) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+
+	return time.Duration(float64(ticks) / float64(timescale) * float64(time.Second))
+}
@@ -0,0 +1,171 @@
+package timeseek
+
+import (
+	"io"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+const (
+	// The PCR is a 33 bit counter of a 90kHz clock.
+	pcrClock = 90000
+	pcrWrap  = 1 << 33
+
+	// How far we scan for a PCR. Encoders have to
+	// send one at least every 100ms.
+	pcrScanLimit = 4 << 20
+)
+
+type tsIndex struct {
+	r          io.ReaderAt
+	size       int64
+	packetSize int64
+	// The packet prefix of M2TS files, i.e. a 4 byte timestamp.
+	prefix   int64
+	pcrPID   int
+	firstPCR int64
+	duration time.Duration
+}
+
+func openTS(r io.ReaderAt, size int64) (Index, error) {
+	head := make([]byte, containers.TSHeadSize)
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	t := &tsIndex{r: r, size: size, packetSize: containers.TSPacketSize, pcrPID: -1}
+	if !containers.IsTS(head, containers.TSPacketSize, 0) {
+		t.packetSize, t.prefix = containers.M2TSPacketSize, 4
+	}
+
+	first, _, ok := t.scanPCR(0, pcrScanLimit, false)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	t.firstPCR = first
+
+	// The last PCR is close to the end of the file.
+	start := t.align(size - pcrScanLimit)
+	if start < 0 {
+		start = 0
+	}
+
+	last, _, ok := t.scanPCR(start, size-start, true)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	t.duration = pcrToDuration(t.unwrap(last) - first)
+
+	return t, nil
+}
+
+func (t *tsIndex) Duration() time.Duration {
+	return t.duration
+}
+
+// Offset - The PCR grows roughly linearly with the byte offset,
+// so a binary search on the packets gets us close enough.
+func (t *tsIndex) Offset(d time.Duration) (int64, time.Duration, error) {
+	target := t.firstPCR + int64(d.Seconds()*pcrClock)
+
+	// The packet at lo always plays at or before the target.
+	lo, hi := int64(0), t.size/t.packetSize
+	at, pcr := int64(0), t.firstPCR
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+
+		v, vAt, ok := t.scanPCR(mid*t.packetSize, pcrScanLimit, false)
+		if !ok || t.unwrap(v) > target {
+			hi = mid
+			continue
+		}
+
+		lo = mid
+		at, pcr = vAt, t.unwrap(v)
+	}
+
+	return at, pcrToDuration(pcr - t.firstPCR), nil
+}
+
+func (t *tsIndex) align(off int64) int64 {
+	return off - off%t.packetSize
+}
+
+// unwrap handles the PCR wrapping around, for
+// streams that started close to the wrap point.
+func (t *tsIndex) unwrap(pcr int64) int64 {
+	if pcr < t.firstPCR {
+		return pcr + pcrWrap
+	}
+
+	return pcr
+}
+
+// scanPCR scans the packets from off for a PCR of the PCR PID. It
+// returns the first one found, or the last one when last is true,
+// along with the offset of its packet.
+func (t *tsIndex) scanPCR(off, limit int64, last bool) (int64, int64, bool) {
+	buf := make([]byte, 1024*t.packetSize)
+
+	var (
+		found   bool
+		pcr, at int64
+	)
+
+	for end := off + limit; off < end && off < t.size; {
+		n, err := t.r.ReadAt(buf, off)
+		if n < int(t.packetSize) {
+			break
+		}
+
+		for i := int64(0); i+t.packetSize <= int64(n); i += t.packetSize {
+			p := buf[i+t.prefix : i+t.packetSize]
+			if p[0] != containers.TSSyncByte {
+				continue
+			}
+
+			v, pid, ok := packetPCR(p)
+			if !ok || (t.pcrPID >= 0 && pid != t.pcrPID) {
+				continue
+			}
+
+			if t.pcrPID < 0 {
+				t.pcrPID = pid
+			}
+
+			pcr, at, found = v, off+i, true
+			if !last {
+				return pcr, at, true
+			}
+		}
+
+		if err != nil {
+			break
+		}
+		off += int64(n) - int64(n)%t.packetSize
+	}
+
+	return pcr, at, found
+}
+
+// packetPCR returns the PCR base of the packet, if it has one.
+func packetPCR(p []byte) (int64, int, bool) {
+	pid := int(p[1]&0x1f)<<8 | int(p[2])
+
+	// The adaptation field has to be present,
+	// long enough and flagged to carry a PCR.
+	if p[3]&0x20 == 0 || p[4] < 7 || p[5]&0x10 == 0 {
+		return 0, pid, false
+	}
+
+	pcr := int64(p[6])<<25 | int64(p[7])<<17 | int64(p[8])<<9 | int64(p[9])<<1 | int64(p[10])>>7
+
+	return pcr, pid, true
+}
+
+func pcrToDuration(pcr int64) time.Duration {
+	return time.Duration(float64(pcr) / pcrClock * float64(time.Second))
+}
@@ -0,0 +1,44 @@
+package timeseek
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+// ErrUnsupported - We can't map times to byte offsets for this media.
+var ErrUnsupported = errors.New("time seek not supported for this media")
+
+// Index - Maps media times to byte offsets, so that we can serve
+// the TimeSeekRange.dlna.org requests with plain byte ranges.
+type Index interface {
+	// Duration - The media duration.
+	Duration() time.Duration
+	// Offset - The byte offset to start streaming from, to play
+	// the media from t. We also return the actual time of that
+	// offset, as we can only start at keyframes or packets.
+	Offset(t time.Duration) (int64, time.Duration, error)
+}
+
+// Open - Build the time seek Index of the media. We support MP4 files
+// via their sample tables and MPEG-TS files via their PCR timestamps.
+func Open(r io.ReaderAt, size int64) (Index, error) {
+	head := make([]byte, containers.TSHeadSize)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		return openMP4(r, size)
+	case containers.IsTS(head, containers.TSPacketSize, 0), containers.IsTS(head, containers.M2TSPacketSize, 4):
+		return openTS(r, size)
+	}
+
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,209 @@
+package timeseek
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/containers"
+)
+
+func box(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	out := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint32(out, uint32(8+len(b)))
+	copy(out[4:], typ)
+	return append(out, b...)
+}
+
+func u32s(v ...uint32) []byte {
+	out := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.BigEndian.PutUint32(out[4*i:], x)
+	}
+	return out
+}
+
+// testMP4 builds a 10 seconds video track with 1 sample
+// per second, 2 samples per chunk and keyframes at 0s, 4s and 8s.
+func testMP4() []byte {
+	ftyp := box("ftyp", []byte("isom"), u32s(0))
+
+	// Each sample is 100 bytes, each chunk 200 bytes.
+	stbl := func(mdatStart uint32) []byte {
+		var offsets []uint32
+		for c := uint32(0); c < 5; c++ {
+			offsets = append(offsets, mdatStart+c*200)
+		}
+		return box("stbl",
+			box("stts", u32s(0, 1, 10, 1000)),
+			box("stss", u32s(0, 3, 1, 5, 9)),
+			box("stsc", u32s(0, 1, 1, 2, 1)),
+			box("stsz", u32s(0, 100, 10)),
+			box("stco", u32s(append([]uint32{0, 5}, offsets...)...)),
+		)
+	}
+
+	moov := func(mdatStart uint32) []byte {
+		return box("moov", box("trak", box("mdia",
+			box("mdhd", u32s(0, 0, 0, 1000, 10000, 0)),
+			box("hdlr", u32s(0, 0), []byte("vide"), u32s(0, 0, 0)),
+			box("minf", stbl(mdatStart)),
+		)))
+	}
+
+	// The mdat box comes after the moov box.
+	mdatStart := uint32(len(ftyp)+len(moov(0))) + 8
+	mdat := box("mdat", make([]byte, 1000))
+
+	return bytes.Join([][]byte{ftyp, moov(mdatStart), mdat}, nil)
+}
+
+func TestMP4Index(t *testing.T) {
+	data := testMP4()
+	mdatStart := int64(len(data) - 1000)
+
+	idx, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+
+	if idx.Duration() != 10*time.Second {
+		t.Errorf("Duration: got: %s, want: %s.", idx.Duration(), 10*time.Second)
+	}
+
+	tt := []struct {
+		input      time.Duration
+		wantOffset int64
+		wantTime   time.Duration
+		name       string
+	}{
+		{0, mdatStart, 0, `Start`},
+		{5500 * time.Millisecond, mdatStart + 400, 4 * time.Second, `Middle of a chunk`},
+		{9 * time.Second, mdatStart + 800, 8 * time.Second, `Last keyframe`},
+	}
+
+	for _, tc := range tt {
+		offset, at, err := idx.Offset(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call Offset due to %s", tc.name, err.Error())
+			continue
+		}
+		if offset != tc.wantOffset || at != tc.wantTime {
+			t.Errorf("%s: got: %d/%s, want: %d/%s.", tc.name, offset, at, tc.wantOffset, tc.wantTime)
+		}
+	}
+}
+
+// testTS builds a MPEG-TS stream with a PCR every 10ms.
+func testTS(packets int, firstPCR int64) []byte {
+	var out bytes.Buffer
+	for i := 0; i < packets; i++ {
+		p := make([]byte, containers.TSPacketSize)
+		p[0] = containers.TSSyncByte
+		p[1], p[2] = 0x01, 0x00
+		p[3] = 0x30
+		p[4] = 7
+		p[5] = 0x10
+
+		pcr := firstPCR + int64(i)*900
+		p[6] = byte(pcr >> 25)
+		p[7] = byte(pcr >> 17)
+		p[8] = byte(pcr >> 9)
+		p[9] = byte(pcr >> 1)
+		p[10] = byte(pcr<<7) | 0x7e
+
+		out.Write(p)
+	}
+
+	return out.Bytes()
+}
+
+func TestTSIndex(t *testing.T) {
+	data := testTS(1001, 123456)
+
+	idx, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+
+	if idx.Duration() != 10*time.Second {
+		t.Errorf("Duration: got: %s, want: %s.", idx.Duration(), 10*time.Second)
+	}
+
+	tt := []struct {
+		input      time.Duration
+		wantOffset int64
+		wantTime   time.Duration
+		name       string
+	}{
+		{0, 0, 0, `Start`},
+		{2 * time.Second, 200 * containers.TSPacketSize, 2 * time.Second, `Exact`},
+		{2505 * time.Millisecond, 250 * containers.TSPacketSize, 2500 * time.Millisecond, `Between PCRs`},
+	}
+
+	for _, tc := range tt {
+		offset, at, err := idx.Offset(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call Offset due to %s", tc.name, err.Error())
+			continue
+		}
+		if offset != tc.wantOffset || at != tc.wantTime {
+			t.Errorf("%s: got: %d/%s, want: %d/%s.", tc.name, offset, at, tc.wantOffset, tc.wantTime)
+		}
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	data := []byte("RIFF....AVI LIST")
+
+	if _, err := Open(bytes.NewReader(data), int64(len(data))); err != ErrUnsupported {
+		t.Errorf("got: %v, want: %v.", err, ErrUnsupported)
+	}
+}
+
+func TestInvalidSyncSamples(t *testing.T) {
+	// The sync samples are numbered from 1.
+	data := bytes.Replace(testMP4(), u32s(0, 3, 1, 5, 9), u32s(0, 3, 0, 5, 9), 1)
+
+	if _, err := Open(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Errorf("got: no error, want: an invalid sync sample table error.")
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "clip.ts")
+	if err := os.WriteFile(f, testTS(1001, 0), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := OpenFile(f)
+	if err != nil {
+		t.Fatalf("OpenFile: %s", err)
+	}
+
+	if again, _ := OpenFile(f); again != idx {
+		t.Errorf("got: a new index, want: the cached one.")
+	}
+
+	// A modified file gets a new index.
+	if err := os.WriteFile(f, testTS(501, 0), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err = OpenFile(f)
+	if err != nil {
+		t.Fatalf("OpenFile: %s", err)
+	}
+
+	if idx.Duration() != 5*time.Second {
+		t.Errorf("got: %s, want: %s.", idx.Duration(), 5*time.Second)
+	}
+
+	if _, at, err := idx.Offset(2 * time.Second); err != nil || at != 2*time.Second {
+		t.Errorf("got: %s (%v), want: %s.", at, err, 2*time.Second)
+	}
+}
@@ -0,0 +1,151 @@
+package transcoder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultProfile - H.264/AAC in MPEG-TS plays
+// on almost every Media Renderer.
+const DefaultProfile = "mpegts"
+
+// Profile - An ffmpeg output profile. The Args go between
+// the input and the output of the ffmpeg command.
+type Profile struct {
+	Name      string   `json:"name"`
+	MediaType string   `json:"mediaType"`
+	Args      []string `json:"args"`
+}
+
+var builtinProfiles = []Profile{
+	{
+		Name:      "mpegts",
+		MediaType: "video/mp2t",
+		Args: []string{
+			"-map", "0:v:0?", "-map", "0:a:0?",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+			"-pix_fmt", "yuv420p", "-profile:v", "high", "-level", "4.1",
+			"-c:a", "aac", "-b:a", "192k", "-ac", "2",
+			"-f", "mpegts",
+		},
+	},
+	{
+		Name:      "mp4",
+		MediaType: "video/mp4",
+		Args: []string{
+			"-map", "0:v:0?", "-map", "0:a:0?",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+			"-pix_fmt", "yuv420p", "-profile:v", "high", "-level", "4.1",
+			"-c:a", "aac", "-b:a", "192k", "-ac", "2",
+			"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+			"-f", "mp4",
+		},
+	},
+	{
+		Name:      "mp3",
+		MediaType: "audio/mpeg",
+		Args: []string{
+			"-vn", "-c:a", "libmp3lame", "-b:a", "320k",
+			"-f", "mp3",
+		},
+	},
+}
+
+// Config - The transcoding settings. Renderers maps the Media
+// Renderer friendly names, URLs or hosts to profile names.
+// Custom profiles override the built-in ones with the same name.
+// ImageProfiles maps them to the DLNA JPEG profile, e.g. JPEG_MED,
+// that the converted photos need to fit in.
+type Config struct {
+	FFmpeg        string            `json:"ffmpeg"`
+	Renderers     map[string]string `json:"renderers"`
+	Profiles      []Profile         `json:"profiles"`
+	ImageProfiles map[string]string `json:"imageProfiles"`
+}
+
+// configFile can be overridden in tests.
+var configFile = defaultConfigFile
+
+func defaultConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("configFile config dir error: %w", err)
+	}
+
+	return filepath.Join(dir, "go2tv", "transcode.json"), nil
+}
+
+// LoadConfig - Read the transcoding settings.
+// A missing file is not an error.
+func LoadConfig() (*Config, error) {
+	f, err := configFile()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+
+	b, err := os.ReadFile(f)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig read error: %w", err)
+	}
+
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("LoadConfig unmarshal error: %w", err)
+	}
+
+	return c, nil
+}
+
+// Profile - Find a profile by name.
+func (c *Config) Profile(name string) (Profile, error) {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	for _, p := range builtinProfiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return Profile{}, errors.New("profile: unknown transcoding profile " + name)
+}
+
+// ProfileFor - Find the profile of the Media Renderer. We check the
+// renderer identifiers in order (e.g. friendly name, URL, host), and
+// fall back to the default profile.
+func (c *Config) ProfileFor(renderer ...string) (Profile, error) {
+	for _, r := range renderer {
+		if name, ok := c.Renderers[r]; ok && r != "" {
+			return c.Profile(name)
+		}
+	}
+
+	return c.Profile(DefaultProfile)
+}
+
+// ImageProfileFor - Find the JPEG profile name of the Media Renderer,
+// in the same way as ProfileFor. An empty name means the default one.
+func (c *Config) ImageProfileFor(renderer ...string) string {
+	for _, r := range renderer {
+		if name, ok := c.ImageProfiles[r]; ok && r != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// New - Create a new Transcoder with the configured ffmpeg binary.
+func (c *Config) New(input string, p Profile) (*Transcoder, error) {
+	return New(c.FFmpeg, input, p)
+}
@@ -0,0 +1,128 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexballas/go2tv/internal/utils"
+)
+
+var durationRe = regexp.MustCompile(`Duration: (\d+:\d{2}:\d{2}(?:\.\d+)?)`)
+
+// Transcoder - On the fly transcoding of a media file or URL through
+// an external ffmpeg binary. Every Start call runs a new encoder, so
+// that we can serve time based seek requests by restarting it at
+// the requested offset.
+type Transcoder struct {
+	FFmpeg  string
+	Input   string
+	Profile Profile
+
+	durationOnce sync.Once
+	duration     time.Duration
+	durationErr  error
+}
+
+// New - Create a new Transcoder. We make sure
+// that the ffmpeg binary exists upfront.
+func New(ffmpeg, input string, p Profile) (*Transcoder, error) {
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
+	path, err := exec.LookPath(ffmpeg)
+	if err != nil {
+		return nil, fmt.Errorf("transcoder New error: %w", err)
+	}
+
+	return &Transcoder{
+		FFmpeg:  path,
+		Input:   input,
+		Profile: p,
+	}, nil
+}
+
+// Start - Start the encoder at the offset. The encoder gets killed
+// when the context is done or when the output gets closed.
+func (t *Transcoder) Start(ctx context.Context, offset time.Duration) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	cmd := exec.CommandContext(ctx, t.FFmpeg, t.args(offset)...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("transcoder Start pipe error: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("transcoder Start error: %w", err)
+	}
+
+	return &encoderOutput{ReadCloser: out, cmd: cmd, cancel: cancel}, nil
+}
+
+func (t *Transcoder) args(offset time.Duration) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+
+	// Seeking before the input is fast, as ffmpeg
+	// jumps to the closest keyframe.
+	if offset > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64))
+	}
+
+	args = append(args, "-i", t.Input)
+	args = append(args, t.Profile.Args...)
+
+	return append(args, "pipe:1")
+}
+
+// Duration - The media duration, as reported by ffmpeg.
+func (t *Transcoder) Duration() (time.Duration, error) {
+	t.durationOnce.Do(func() {
+		// ffmpeg exits with an error as there's no output
+		// file, but it still prints the input details.
+		out, _ := exec.Command(t.FFmpeg, "-hide_banner", "-nostdin", "-i", t.Input).CombinedOutput()
+		t.duration, t.durationErr = parseDuration(string(out))
+	})
+
+	return t.duration, t.durationErr
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, errors.New("parseDuration: unknown media duration")
+	}
+
+	d, err := utils.ParseNPT(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("parseDuration error: %w", err)
+	}
+
+	return d, nil
+}
+
+type encoderOutput struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// Close - Kill the encoder and wait for it to exit.
+func (e *encoderOutput) Close() error {
+	e.once.Do(func() {
+		e.cancel()
+		e.cmd.Wait()
+	})
+
+	return nil
+}
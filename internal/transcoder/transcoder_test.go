@@ -0,0 +1,139 @@
+package transcoder
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArgs(t *testing.T) {
+	tr := &Transcoder{
+		FFmpeg:  "ffmpeg",
+		Input:   "movie.mkv",
+		Profile: Profile{Args: []string{"-f", "mpegts"}},
+	}
+
+	tt := []struct {
+		offset time.Duration
+		want   string
+		name   string
+	}{
+		{0, "-hide_banner -loglevel error -nostdin -i movie.mkv -f mpegts pipe:1", `From the start`},
+		{90500 * time.Millisecond, "-hide_banner -loglevel error -nostdin -ss 90.500 -i movie.mkv -f mpegts pipe:1", `With offset`},
+	}
+
+	for _, tc := range tt {
+		if got := strings.Join(tr.args(tc.offset), " "); got != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	out := "Input #0, matroska,webm, from 'movie.mkv':\n" +
+		"  Duration: 01:02:03.50, start: 0.000000, bitrate: 5000 kb/s\n" +
+		"At least one output file must be specified\n"
+
+	d, err := parseDuration(out)
+	if err != nil {
+		t.Fatalf("parseDuration: %s", err)
+	}
+
+	want := time.Hour + 2*time.Minute + 3500*time.Millisecond
+	if d != want {
+		t.Errorf("got: %s, want: %s.", d, want)
+	}
+
+	if _, err := parseDuration("  Duration: N/A, bitrate: N/A\n"); err == nil {
+		t.Errorf("expected error for unknown duration")
+	}
+}
+
+func TestProfileFor(t *testing.T) {
+	dir := t.TempDir()
+	configFile = func() (string, error) {
+		return filepath.Join(dir, "transcode.json"), nil
+	}
+	defer func() { configFile = defaultConfigFile }()
+
+	config := `{
+  "renderers": {"Living Room TV": "mp4", "192.168.1.20": "old-tv"},
+  "imageProfiles": {"192.168.1.20": "JPEG_MED"},
+  "profiles": [{"name": "old-tv", "mediaType": "video/mpeg", "args": ["-c:v", "mpeg2video", "-f", "mpegts"]}]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "transcode.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %s", err)
+	}
+
+	tt := []struct {
+		input []string
+		want  string
+		name  string
+	}{
+		{[]string{"Living Room TV", "http://192.168.1.10/dmr", "192.168.1.10"}, "mp4", `By name`},
+		{[]string{"Bedroom TV", "http://192.168.1.20/dmr", "192.168.1.20"}, "old-tv", `By host`},
+		{[]string{"Kitchen TV", "http://192.168.1.30/dmr", "192.168.1.30"}, DefaultProfile, `Default`},
+	}
+
+	for _, tc := range tt {
+		p, err := cfg.ProfileFor(tc.input...)
+		if err != nil {
+			t.Errorf("%s: Failed to call ProfileFor due to %s", tc.name, err.Error())
+			continue
+		}
+		if p.Name != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, p.Name, tc.want)
+		}
+	}
+
+	if got := cfg.ImageProfileFor("Bedroom TV", "http://192.168.1.20/dmr", "192.168.1.20"); got != "JPEG_MED" {
+		t.Errorf("Image profile by host: got: %s, want: %s.", got, "JPEG_MED")
+	}
+
+	if got := cfg.ImageProfileFor("Kitchen TV"); got != "" {
+		t.Errorf("Default image profile: got: %s, want: nothing.", got)
+	}
+
+	if _, err := cfg.Profile("missing"); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+}
+
+func TestStart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	// The fake ffmpeg echoes its arguments.
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\necho \"$@\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := New(ffmpeg, "movie.mkv", Profile{Args: []string{"-f", "mpegts"}})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	out, err := tr.Start(context.Background(), 10*time.Second)
+	if err != nil {
+		t.Fatalf("Start: %s", err)
+	}
+	defer out.Close()
+
+	got, _ := io.ReadAll(out)
+	want := "-hide_banner -loglevel error -nostdin -ss 10.000 -i movie.mkv -f mpegts pipe:1\n"
+	if string(got) != want {
+		t.Errorf("got: %q, want: %q.", got, want)
+	}
+}
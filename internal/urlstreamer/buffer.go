@@ -0,0 +1,294 @@
+package urlstreamer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// DefaultBufferLimit - The maximum size of the disk buffer
+// of a URL stream. Past that point, the media is streamed
+// straight from the origin, without buffering.
+var DefaultBufferLimit int64 = 4 << 30
+
+var (
+	// ErrBufferLimit - The requested bytes are past the disk buffer
+	// size limit, and they are not the next ones of the origin stream.
+	ErrBufferLimit = errors.New("disk buffer size limit reached")
+
+	// ErrBufferClosed - The disk buffer was closed.
+	ErrBufferClosed = errors.New("disk buffer closed")
+)
+
+// BufferedStream - A URL stream from an origin without byte range
+// support. We tee the download to a temporary file, so that repeated
+// and ranged Media Renderer requests can be served from what has been
+// downloaded so far. Reads past that point block until the download
+// catches up. When the disk buffer gets full, the rest of the media
+// is read straight from the origin, so the sequential reads still get
+// the whole media.
+type BufferedStream struct {
+	ContentType string
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	src    io.ReadCloser
+	file   *os.File
+	length int64
+	size   int64
+	done   bool
+	full   bool
+	closed bool
+	err    error
+
+	// rest - The origin stream past the disk buffer, and
+	// restOffset the media offset of its next byte.
+	restMu     sync.Mutex
+	rest       io.Reader
+	restOffset int64
+}
+
+// BufferURL - Start downloading the media URL to a disk buffer.
+// A limit of 0 or less means no limit.
+func BufferURL(ctx context.Context, s string, limit int64) (*BufferedStream, error) {
+	resp, err := getURL(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("BufferURL error: %w", err)
+	}
+
+	b, err := newBufferedStream(resp.Body, resp.ContentLength, limit)
+	if err != nil {
+		return nil, fmt.Errorf("BufferURL error: %w", err)
+	}
+
+	b.ContentType = resp.Header.Get("Content-Type")
+
+	return b, nil
+}
+
+// newBufferedStream - A negative length means that
+// the origin did not provide the media size.
+func newBufferedStream(src io.ReadCloser, length, limit int64) (*BufferedStream, error) {
+	f, err := os.CreateTemp("", "go2tv-*")
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("newBufferedStream temp file error: %w", err)
+	}
+
+	// The open file remains usable, and the OS cleans it up
+	// even if we never get to call Close. Windows can't
+	// remove open files, so we do it in Close instead.
+	if runtime.GOOS != "windows" {
+		os.Remove(f.Name())
+	}
+
+	b := &BufferedStream{
+		src:    src,
+		file:   f,
+		length: length,
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	go b.download(limit)
+
+	return b, nil
+}
+
+func (b *BufferedStream) download(limit int64) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := b.src.Read(buf)
+		if n > 0 {
+			b.mu.Lock()
+			offset := b.size
+			b.mu.Unlock()
+
+			if limit > 0 && offset+int64(n) > limit {
+				b.overflow(buf[:n], offset, limit-offset, err)
+				return
+			}
+
+			if _, werr := b.file.WriteAt(buf[:n], offset); werr != nil {
+				b.finish(werr)
+				return
+			}
+
+			b.mu.Lock()
+			b.size += int64(n)
+			b.mu.Unlock()
+			b.cond.Broadcast()
+		}
+
+		if err == io.EOF {
+			b.finish(nil)
+			return
+		}
+
+		if err != nil {
+			b.finish(err)
+			return
+		}
+	}
+}
+
+// overflow buffers what fits of the last read, and leaves the
+// rest of it, along with the rest of the origin stream, to the
+// sequential reads past the disk buffer.
+func (b *BufferedStream) overflow(last []byte, offset, fit int64, readErr error) {
+	if _, err := b.file.WriteAt(last[:fit], offset); err != nil {
+		b.finish(err)
+		return
+	}
+
+	pending := append([]byte(nil), last[fit:]...)
+
+	var src io.Reader = b.src
+	if readErr != nil {
+		src = &errReader{err: readErr}
+	}
+
+	b.restMu.Lock()
+	b.rest = io.MultiReader(bytes.NewReader(pending), src)
+	b.restOffset = offset + fit
+	b.restMu.Unlock()
+
+	b.mu.Lock()
+	b.size = offset + fit
+	b.full = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// readRest reads from the origin stream past the disk buffer.
+// Only the next bytes of the origin stream can be read.
+func (b *BufferedStream) readRest(p []byte, off int64) (int, error) {
+	b.restMu.Lock()
+	defer b.restMu.Unlock()
+
+	if off != b.restOffset {
+		return 0, ErrBufferLimit
+	}
+
+	n, err := io.ReadFull(b.rest, p)
+	b.restOffset += int64(n)
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+func (b *BufferedStream) finish(err error) {
+	defer b.src.Close()
+
+	b.mu.Lock()
+	if err == nil {
+		b.done = true
+		b.length = b.size
+	} else {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// Len - The size of the media, or -1 when the origin did not
+// provide it and the download is still in progress.
+func (b *BufferedStream) Len() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.length
+}
+
+// ReadAt - Read from the disk buffer. Blocks until
+// the requested bytes get downloaded.
+func (b *BufferedStream) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	for off+int64(len(p)) > b.size && !b.done && !b.full && b.err == nil && !b.closed {
+		b.cond.Wait()
+	}
+
+	size, done, full, closed, berr := b.size, b.done, b.full, b.closed, b.err
+	b.mu.Unlock()
+
+	if closed {
+		return 0, ErrBufferClosed
+	}
+
+	if full && off+int64(len(p)) > size {
+		var n int
+		if off < size {
+			var err error
+			n, err = b.file.ReadAt(p[:size-off], off)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		m, err := b.readRest(p[n:], off+int64(n))
+		return n + m, err
+	}
+
+	avail := size - off
+	if avail <= 0 {
+		if done {
+			return 0, io.EOF
+		}
+		return 0, berr
+	}
+
+	want := len(p)
+	if int64(want) > avail {
+		want = int(avail)
+	}
+
+	n, err := b.file.ReadAt(p[:want], off)
+	if err != nil {
+		return n, err
+	}
+
+	if n < len(p) {
+		if done {
+			return n, io.EOF
+		}
+		return n, berr
+	}
+
+	return n, nil
+}
+
+// Close - Stop the download and remove the disk buffer.
+func (b *BufferedStream) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+
+	b.src.Close()
+	b.file.Close()
+
+	if err := os.Remove(b.file.Name()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Close error: %w", err)
+	}
+
+	return nil
+}
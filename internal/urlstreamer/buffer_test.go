@@ -0,0 +1,128 @@
+package urlstreamer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// slowReader hands out the data in small chunks,
+// like a slow origin would.
+type slowReader struct {
+	data []byte
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if len(s.data) == 0 {
+		return 0, io.EOF
+	}
+
+	time.Sleep(time.Millisecond)
+
+	n := copy(p[:min(len(p), 100)], s.data)
+	s.data = s.data[n:]
+
+	return n, nil
+}
+
+func (s *slowReader) Close() error {
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestBufferedStream(t *testing.T) {
+	tt := []struct {
+		length int64
+		name   string
+	}{
+		{int64(len(testMedia)), `Known length`},
+		{-1, `Unknown length`},
+	}
+
+	for _, tc := range tt {
+		b, err := newBufferedStream(&slowReader{data: testMedia}, tc.length, 0)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		// The end of the media blocks until it gets downloaded.
+		end := make([]byte, 10)
+		if _, err := b.ReadAt(end, int64(len(testMedia)-10)); err != nil {
+			t.Errorf("%s: ReadAt: %s", tc.name, err)
+		}
+
+		if string(end) != "0123456789" {
+			t.Errorf("%s: got: %q, want: %q.", tc.name, end, "0123456789")
+		}
+
+		// Repeated requests get the whole media.
+		for i := 0; i < 2; i++ {
+			out, err := io.ReadAll(io.NewSectionReader(b, 0, 1<<20))
+			if err != nil {
+				t.Errorf("%s: ReadAll: %s", tc.name, err)
+			}
+
+			if !bytes.Equal(out, testMedia) {
+				t.Errorf("%s: got: %d bytes, want: %d bytes.", tc.name, len(out), len(testMedia))
+			}
+		}
+
+		if b.Len() != int64(len(testMedia)) {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, b.Len(), len(testMedia))
+		}
+
+		if err := b.Close(); err != nil {
+			t.Errorf("%s: Close: %s", tc.name, err)
+		}
+
+		if _, err := b.ReadAt(end, 0); err != ErrBufferClosed {
+			t.Errorf("%s: got: %v, want: %v.", tc.name, err, ErrBufferClosed)
+		}
+	}
+}
+
+func TestBufferedStreamLimit(t *testing.T) {
+	tt := []struct {
+		length int64
+		name   string
+	}{
+		{int64(len(testMedia)), `Length above the limit`},
+		{-1, `Unknown length`},
+	}
+
+	for _, tc := range tt {
+		b, err := newBufferedStream(&slowReader{data: testMedia}, tc.length, 500)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		// The sequential reads get the rest of the media
+		// straight from the origin.
+		out, err := io.ReadAll(io.NewSectionReader(b, 0, 1<<20))
+		if err != nil {
+			t.Errorf("%s: ReadAll: %s", tc.name, err)
+		}
+
+		if !bytes.Equal(out, testMedia) {
+			t.Errorf("%s: got: %d bytes, want: %d bytes.", tc.name, len(out), len(testMedia))
+		}
+
+		// The buffered part can be read again, but not the rest.
+		if _, err := b.ReadAt(make([]byte, 10), 490); err != nil {
+			t.Errorf("%s: got: %v, want: no error.", tc.name, err)
+		}
+
+		if _, err := b.ReadAt(make([]byte, 10), 600); err != ErrBufferLimit {
+			t.Errorf("%s: got: %v, want: %v.", tc.name, err, ErrBufferLimit)
+		}
+
+		b.Close()
+	}
+}
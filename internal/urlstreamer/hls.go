@@ -0,0 +1,497 @@
+package urlstreamer
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HLSMediaType - HLS playlists are served to the
+// Media Renderer as one continuous MPEG-TS stream.
+const HLSMediaType = "video/mp2t"
+
+// MaxHLSBandwidth - The highest variant bandwidth (bits/s) we pick
+// from a HLS master playlist. 0 picks the highest one available.
+var MaxHLSBandwidth = 0
+
+var errNotPlaylist = errors.New("not a HLS playlist")
+
+// The media types of the HLS playlists. Some origins
+// also serve them as plain text or binary data.
+var playlistTypes = map[string]bool{
+	"application/vnd.apple.mpegurl": true,
+	"application/x-mpegurl":         true,
+	"audio/mpegurl":                 true,
+	"audio/x-mpegurl":               true,
+}
+
+// Playlists are small text files. Anything bigger
+// is certainly not a playlist.
+const maxPlaylistSize = 4 << 20
+
+type hlsVariant struct {
+	bandwidth int
+	uri       string
+}
+
+type hlsKey struct {
+	method string
+	uri    string
+	iv     []byte
+}
+
+type hlsSegment struct {
+	uri string
+	seq int64
+	key *hlsKey
+}
+
+type hlsPlaylist struct {
+	variants       []hlsVariant
+	segments       []hlsSegment
+	targetDuration time.Duration
+	endList        bool
+	fmp4           bool
+}
+
+// OpenHLS - Open a HLS playlist URL and return its segments as one
+// continuous MPEG-TS stream. For master playlists, we pick a variant
+// based on MaxHLSBandwidth. Live playlists get reloaded until the
+// stream gets closed.
+func OpenHLS(ctx context.Context, s string) (io.ReadCloser, error) {
+	client := &http.Client{}
+
+	pl, base, err := fetchPlaylist(ctx, client, s)
+	if err != nil {
+		return nil, fmt.Errorf("OpenHLS error: %w", err)
+	}
+
+	if len(pl.variants) > 0 {
+		variant := pickVariant(pl.variants, MaxHLSBandwidth)
+		pl, base, err = fetchPlaylist(ctx, client, variant.uri)
+		if err != nil {
+			return nil, fmt.Errorf("OpenHLS variant error: %w", err)
+		}
+	}
+
+	if pl.fmp4 {
+		return nil, errors.New("OpenHLS error: fMP4 segments are not supported")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+
+	h := &hlsStream{
+		client:  client,
+		pipe:    pw,
+		keys:    make(map[string][]byte),
+		nextSeq: -1,
+	}
+
+	go func() {
+		pw.CloseWithError(h.run(ctx, base, pl))
+	}()
+
+	return &hlsReadCloser{PipeReader: pr, cancel: cancel}, nil
+}
+
+type hlsReadCloser struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+// Close - Stop fetching the segments.
+func (h *hlsReadCloser) Close() error {
+	h.cancel()
+	return h.PipeReader.Close()
+}
+
+type hlsStream struct {
+	client  *http.Client
+	pipe    *io.PipeWriter
+	keys    map[string][]byte
+	nextSeq int64
+}
+
+func (h *hlsStream) run(ctx context.Context, playlistURL string, pl *hlsPlaylist) error {
+	// Like most players, we start live streams
+	// close to their live edge.
+	if !pl.endList && len(pl.segments) > 3 {
+		h.nextSeq = pl.segments[len(pl.segments)-3].seq
+	}
+
+	for {
+		var fetched bool
+		for _, seg := range pl.segments {
+			if seg.seq < h.nextSeq {
+				continue
+			}
+
+			if err := h.writeSegment(ctx, seg); err != nil {
+				return err
+			}
+
+			h.nextSeq = seg.seq + 1
+			fetched = true
+		}
+
+		if pl.endList {
+			return nil
+		}
+
+		// The spec asks us to wait for half the target
+		// duration when the playlist has not changed.
+		wait := pl.targetDuration
+		if !fetched {
+			wait /= 2
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		var err error
+		pl, _, err = fetchPlaylist(ctx, h.client, playlistURL)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (h *hlsStream) writeSegment(ctx context.Context, seg hlsSegment) error {
+	body, err := h.get(ctx, seg.uri)
+	if err != nil {
+		return fmt.Errorf("writeSegment error: %w", err)
+	}
+
+	if seg.key != nil {
+		body, err = h.decrypt(ctx, seg, body)
+		if err != nil {
+			return fmt.Errorf("writeSegment error: %w", err)
+		}
+	}
+
+	if _, err := h.pipe.Write(body); err != nil {
+		return fmt.Errorf("writeSegment error: %w", err)
+	}
+
+	return nil
+}
+
+func (h *hlsStream) decrypt(ctx context.Context, seg hlsSegment, data []byte) ([]byte, error) {
+	key, ok := h.keys[seg.key.uri]
+	if !ok {
+		var err error
+		key, err = h.get(ctx, seg.key.uri)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt key error: %w", err)
+		}
+		h.keys[seg.key.uri] = key
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt cipher error: %w", err)
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("decrypt: invalid segment size")
+	}
+
+	// Without an explicit IV, the IV is the
+	// segment media sequence number.
+	iv := seg.key.iv
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(seg.seq))
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	return unpad(out)
+}
+
+// unpad strips the PKCS7 padding. All the padding
+// bytes hold the padding length.
+func unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("unpad: invalid padding")
+	}
+
+	pad := int(b[len(b)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(b) {
+		return nil, errors.New("unpad: invalid padding")
+	}
+
+	for _, c := range b[len(b)-pad:] {
+		if int(c) != pad {
+			return nil, errors.New("unpad: invalid padding")
+		}
+	}
+
+	return b[:len(b)-pad], nil
+}
+
+func (h *hlsStream) get(ctx context.Context, s string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.New("bad status code: " + resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// mayBePlaylist checks if the media URL may point to a HLS playlist,
+// so that we need to peek into it. DASH manifests and the audio,
+// video and image media types are passed through as they are.
+func mayBePlaylist(s, contentType string) bool {
+	ct, _, _ := mime.ParseMediaType(contentType)
+	ct = strings.ToLower(ct)
+
+	if playlistTypes[ct] {
+		return true
+	}
+
+	var p string
+	if u, err := url.Parse(s); err == nil {
+		p = strings.ToLower(u.Path)
+	}
+
+	switch {
+	case ct == "application/dash+xml" || strings.HasSuffix(p, ".mpd"):
+		return false
+	case strings.HasPrefix(ct, "video/"), strings.HasPrefix(ct, "audio/"), strings.HasPrefix(ct, "image/"):
+		return false
+	}
+
+	return true
+}
+
+// isPlaylist checks the start of the media. Playlists start with #EXTM3U.
+func isPlaylist(head []byte) bool {
+	return strings.HasPrefix(strings.TrimPrefix(string(head), "\ufeff"), "#EXTM3U")
+}
+
+func fetchPlaylist(ctx context.Context, client *http.Client, s string) (*hlsPlaylist, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetchPlaylist error: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetchPlaylist error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, "", errors.New("fetchPlaylist bad status code: " + resp.Status)
+	}
+
+	// Relative URIs are relative to the
+	// playlist URL, after any redirects.
+	base := resp.Request.URL
+
+	pl, err := parsePlaylist(base, io.LimitReader(resp.Body, maxPlaylistSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("fetchPlaylist error: %w", err)
+	}
+
+	return pl, base.String(), nil
+}
+
+func parsePlaylist(base *url.URL, r io.Reader) (*hlsPlaylist, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxPlaylistSize)
+
+	if !scanner.Scan() || strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "\ufeff") != "#EXTM3U" {
+		return nil, errNotPlaylist
+	}
+
+	pl := &hlsPlaylist{}
+
+	var (
+		mediaSeq    int64
+		key         *hlsKey
+		nextVariant *hlsVariant
+		nextSegment bool
+		isHLS       bool
+	)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bw, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			nextVariant = &hlsVariant{bandwidth: bw}
+			isHLS = true
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			secs, _ := strconv.ParseFloat(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"), 64)
+			pl.targetDuration = time.Duration(secs * float64(time.Second))
+			isHLS = true
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			mediaSeq, _ = strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			switch attrs["METHOD"] {
+			case "NONE":
+				key = nil
+			case "AES-128":
+				k, err := newKey(base, attrs)
+				if err != nil {
+					return nil, err
+				}
+				key = k
+			default:
+				return nil, errors.New("parsePlaylist: unsupported encryption method " + attrs["METHOD"])
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			pl.fmp4 = true
+		case strings.HasPrefix(line, "#EXTINF:"):
+			nextSegment = true
+		case line == "#EXT-X-ENDLIST":
+			pl.endList = true
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("parsePlaylist URI error: %w", err)
+			}
+
+			switch {
+			case nextVariant != nil:
+				nextVariant.uri = u.String()
+				pl.variants = append(pl.variants, *nextVariant)
+				nextVariant = nil
+			case nextSegment:
+				pl.segments = append(pl.segments, hlsSegment{
+					uri: u.String(),
+					seq: mediaSeq + int64(len(pl.segments)),
+					key: key,
+				})
+				nextSegment = false
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parsePlaylist error: %w", err)
+	}
+
+	// Plain M3U playlists also start with #EXTM3U,
+	// but have none of the HLS tags.
+	if !isHLS {
+		return nil, errNotPlaylist
+	}
+
+	return pl, nil
+}
+
+func newKey(base *url.URL, attrs map[string]string) (*hlsKey, error) {
+	u, err := base.Parse(attrs["URI"])
+	if err != nil || attrs["URI"] == "" {
+		return nil, errors.New("parsePlaylist: invalid key URI")
+	}
+
+	k := &hlsKey{method: attrs["METHOD"], uri: u.String()}
+
+	if iv := attrs["IV"]; iv != "" {
+		iv = strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X")
+		b, err := hex.DecodeString(iv)
+		if err != nil || len(b) != aes.BlockSize {
+			return nil, errors.New("parsePlaylist: invalid key IV")
+		}
+		k.iv = b
+	}
+
+	return k, nil
+}
+
+// parseAttributes parses the HLS attribute lists, e.g.
+// BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2".
+// Quoted values may contain commas.
+func parseAttributes(s string) map[string]string {
+	out := make(map[string]string)
+
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+		}
+
+		out[name] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+
+	return out
+}
+
+// pickVariant picks the highest bandwidth variant that
+// does not exceed limit. A limit of 0 means no limit.
+func pickVariant(variants []hlsVariant, limit int) hlsVariant {
+	sorted := make([]hlsVariant, len(variants))
+	copy(sorted, variants)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].bandwidth > sorted[j].bandwidth
+	})
+
+	for _, v := range sorted {
+		if limit <= 0 || v.bandwidth <= limit {
+			return v
+		}
+	}
+
+	// Nothing fits, so we go with the lowest one.
+	return sorted[len(sorted)-1]
+}
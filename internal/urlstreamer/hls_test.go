@@ -0,0 +1,279 @@
+package urlstreamer
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAttributes(t *testing.T) {
+	got := parseAttributes(`BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720`)
+
+	tt := []struct {
+		input string
+		want  string
+	}{
+		{"BANDWIDTH", "1280000"},
+		{"CODECS", "avc1.4d401f,mp4a.40.2"},
+		{"RESOLUTION", "1280x720"},
+	}
+
+	for _, tc := range tt {
+		if got[tc.input] != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.input, got[tc.input], tc.want)
+		}
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []hlsVariant{
+		{800000, "mid.m3u8"},
+		{2400000, "high.m3u8"},
+		{200000, "low.m3u8"},
+	}
+
+	tt := []struct {
+		limit int
+		want  string
+		name  string
+	}{
+		{0, "high.m3u8", `No limit`},
+		{1000000, "mid.m3u8", `Limit`},
+		{100000, "low.m3u8", `Limit below all variants`},
+	}
+
+	for _, tc := range tt {
+		if got := pickVariant(variants, tc.limit); got.uri != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got.uri, tc.want)
+		}
+	}
+}
+
+func TestParsePlaylist(t *testing.T) {
+	base, _ := url.Parse("http://example.com/live/index.m3u8")
+
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:6\n" +
+		"#EXT-X-MEDIA-SEQUENCE:100\n" +
+		"#EXTINF:6.0,\n" +
+		"seg100.ts\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/1\",IV=0x000102030405060708090a0b0c0d0e0f\n" +
+		"#EXTINF:6.0,\n" +
+		"http://cdn.example.com/seg101.ts\n"
+
+	pl, err := parsePlaylist(base, strings.NewReader(playlist))
+	if err != nil {
+		t.Fatalf("parsePlaylist: %s", err)
+	}
+
+	if pl.endList || len(pl.segments) != 2 {
+		t.Fatalf("parsePlaylist: got: %+v, want: 2 segments of a live playlist.", pl)
+	}
+
+	tt := []struct {
+		got  string
+		want string
+		name string
+	}{
+		{pl.segments[0].uri, "http://example.com/live/seg100.ts", `Relative URI`},
+		{pl.segments[1].uri, "http://cdn.example.com/seg101.ts", `Absolute URI`},
+		{fmt.Sprint(pl.segments[1].seq), "101", `Media sequence`},
+		{pl.segments[1].key.uri, "http://example.com/keys/1", `Key URI`},
+	}
+
+	for _, tc := range tt {
+		if tc.got != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, tc.got, tc.want)
+		}
+	}
+
+	if pl.segments[0].key != nil {
+		t.Errorf("Unencrypted segment: got: a key, want: none.")
+	}
+
+	// Plain M3U playlists are not HLS.
+	if _, err := parsePlaylist(base, strings.NewReader("#EXTM3U\n#EXTINF:123,Song\nsong.mp3\n")); err != errNotPlaylist {
+		t.Errorf("Plain M3U: got: %v, want: %v.", err, errNotPlaylist)
+	}
+}
+
+func TestOpenHLS(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	// The second segment is AES-128 encrypted with
+	// the media sequence number as the IV.
+	encrypted := encryptSegment(t, key, 2, []byte("segment two"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=200000\nlow/index.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=900000\nhigh/index.m3u8\n")
+	})
+	mux.HandleFunc("/high/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:1\n"+
+			"#EXTINF:2,\n1.ts\n"+
+			"#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:2,\n2.ts\n"+
+			"#EXT-X-KEY:METHOD=NONE\n#EXTINF:2,\n3.ts\n"+
+			"#EXT-X-ENDLIST\n")
+	})
+	mux.HandleFunc("/high/1.ts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "segment one|")
+	})
+	mux.HandleFunc("/high/2.ts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encrypted)
+	})
+	mux.HandleFunc("/high/3.ts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "|segment three")
+	})
+	mux.HandleFunc("/high/key", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(key)
+	})
+	mux.HandleFunc("/manifest.mpd", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<MPD></MPD>")
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	src, err := OpenURL(context.Background(), srv.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatalf("OpenURL: %s", err)
+	}
+
+	b, ok := src.(*BufferedStream)
+	if !ok || b.ContentType != HLSMediaType {
+		t.Fatalf("OpenURL: got: %T, want: a %s BufferedStream.", src, HLSMediaType)
+	}
+	defer b.Close()
+
+	out, err := io.ReadAll(io.NewSectionReader(b, 0, 1<<20))
+	if err != nil {
+		t.Fatalf("ReadAll: %s", err)
+	}
+
+	want := "segment one|segment two|segment three"
+	if string(out) != want {
+		t.Errorf("HLS stream: got: %q, want: %q.", out, want)
+	}
+
+	// DASH manifests are passed through as they are.
+	dash, err := OpenURL(context.Background(), srv.URL+"/manifest.mpd")
+	if err != nil {
+		t.Fatalf("DASH manifest: %s", err)
+	}
+
+	if b, ok := dash.(*BufferedStream); !ok || b.ContentType == HLSMediaType {
+		t.Errorf("DASH manifest: got: %T, want: a pass-through BufferedStream.", dash)
+	} else {
+		b.Close()
+	}
+}
+
+func TestOpenURLSniffing(t *testing.T) {
+	var fullGets int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+			fullGets++
+		}
+
+		content := testMedia
+		switch r.URL.Path {
+		case "/index.m3u8":
+			content = []byte("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2,\n1.ts\n#EXT-X-ENDLIST\n")
+		case "/clip.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+		}
+
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	tt := []struct {
+		path string
+		hls  bool
+		name string
+	}{
+		{"/clip.mp4", false, `Video`},
+		{"/media", false, `Unknown media type`},
+		{"/index.m3u8", true, `HLS playlist`},
+	}
+
+	for _, tc := range tt {
+		fullGets = 0
+
+		src, err := OpenURL(context.Background(), srv.URL+tc.path)
+		if err != nil {
+			t.Errorf("%s: OpenURL: %s", tc.name, err)
+			continue
+		}
+
+		switch s := src.(type) {
+		case *BufferedStream:
+			if !tc.hls || s.ContentType != HLSMediaType {
+				t.Errorf("%s: got: a %s BufferedStream, want: a RangeSource.", tc.name, s.ContentType)
+			}
+			s.Close()
+			continue
+		case *RangeSource:
+			if tc.hls {
+				t.Errorf("%s: got: a RangeSource, want: a %s BufferedStream.", tc.name, HLSMediaType)
+			}
+		}
+
+		if fullGets != 0 {
+			t.Errorf("%s: got: %d full GET requests, want: 0.", tc.name, fullGets)
+		}
+	}
+}
+
+func TestUnpad(t *testing.T) {
+	tt := []struct {
+		input []byte
+		want  string
+		ok    bool
+		name  string
+	}{
+		{[]byte("data\x03\x03\x03"), "data", true, `Valid padding`},
+		{[]byte("data\x01\x02\x03"), "", false, `Inconsistent padding`},
+		{[]byte("data\x00"), "", false, `Zero padding`},
+		{[]byte("\x05\x05"), "", false, `Padding longer than the data`},
+	}
+
+	for _, tc := range tt {
+		out, err := unpad(tc.input)
+		if (err == nil) != tc.ok || string(out) != tc.want {
+			t.Errorf("%s: got: %q (%v), want: %q.", tc.name, out, err, tc.want)
+		}
+	}
+}
+
+func encryptSegment(t *testing.T, key []byte, seq byte, data []byte) []byte {
+	t.Helper()
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	iv := make([]byte, aes.BlockSize)
+	iv[aes.BlockSize-1] = seq
+
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+
+	return out
+}
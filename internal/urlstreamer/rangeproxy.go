@@ -0,0 +1,207 @@
+package urlstreamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrNoRanges - The origin does not support byte range requests.
+var ErrNoRanges = errors.New("origin does not support range requests")
+
+// RangeSource - A media URL whose origin supports byte range
+// requests. Each Media Renderer request is proxied to the origin
+// with its own Range header, so the renderer can seek.
+type RangeSource struct {
+	URL         string
+	ContentType string
+	Length      int64
+	client      *http.Client
+}
+
+// ProbeURL - Check if the origin supports byte range requests.
+// We first try a HEAD request. As some origins don't handle HEAD
+// requests properly, we then fall back to a single byte GET.
+func ProbeURL(ctx context.Context, s string) (*RangeSource, error) {
+	if _, err := url.ParseRequestURI(s); err != nil {
+		return nil, fmt.Errorf("probeURL failed to parse url: %w", err)
+	}
+
+	src := &RangeSource{
+		URL:    s,
+		client: &http.Client{},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s, nil)
+	if err != nil {
+		return nil, fmt.Errorf("probeURL failed to call NewRequest: %w", err)
+	}
+
+	resp, err := src.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength > 0 {
+			src.ContentType = resp.Header.Get("Content-Type")
+			src.Length = resp.ContentLength
+			return src, nil
+		}
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+	if err != nil {
+		return nil, fmt.Errorf("probeURL failed to call NewRequest: %w", err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err = src.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("probeURL failed to client.Do: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, ErrNoRanges
+	}
+
+	// Content-Range: bytes 0-0/12345
+	cr := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(cr, "/")
+	if i < 0 {
+		return nil, ErrNoRanges
+	}
+
+	length, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil || length <= 0 {
+		return nil, ErrNoRanges
+	}
+
+	src.ContentType = resp.Header.Get("Content-Type")
+	src.Length = length
+
+	return src, nil
+}
+
+// Open - Request the media from the origin. An empty
+// rangeHeader requests the whole media file.
+func (r *RangeSource) Open(ctx context.Context, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open failed to call NewRequest: %w", err)
+	}
+
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("open failed to client.Do: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return resp, nil
+	}
+
+	resp.Body.Close()
+
+	return nil, errors.New("open bad status code: " + resp.Status)
+}
+
+// head reads the first bytes of the media with a small range request.
+func (r *RangeSource) head(ctx context.Context, n int) ([]byte, error) {
+	resp, err := r.Open(ctx, "bytes=0-"+strconv.Itoa(n-1))
+	if err != nil {
+		return nil, fmt.Errorf("head error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b := make([]byte, n)
+	m, err := io.ReadFull(resp.Body, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("head read error: %w", err)
+	}
+
+	return b[:m], nil
+}
+
+// OpenURL - Open the media URL for streaming. HLS playlists get
+// remuxed to a disk buffered MPEG-TS stream. For the rest, we prefer
+// the range-aware proxy, and fall back to a disk buffered stream
+// when the origin does not support byte ranges. We tell the playlists
+// apart by peeking into the media we open anyway, so that we don't
+// download it twice.
+func OpenURL(ctx context.Context, s string) (interface{}, error) {
+	var (
+		head     []byte
+		buffered *BufferedStream
+	)
+
+	src, err := ProbeURL(ctx, s)
+	switch {
+	case err == nil:
+		if !mayBePlaylist(s, src.ContentType) {
+			return src, nil
+		}
+
+		head, err = src.head(ctx, 10)
+		if err != nil {
+			return nil, fmt.Errorf("OpenURL error: %w", err)
+		}
+	default:
+		buffered, err = BufferURL(ctx, s, DefaultBufferLimit)
+		if err != nil {
+			return nil, fmt.Errorf("OpenURL error: %w", err)
+		}
+
+		if !mayBePlaylist(s, buffered.ContentType) {
+			return buffered, nil
+		}
+
+		head = make([]byte, 10)
+		n, _ := buffered.ReadAt(head, 0)
+		head = head[:n]
+	}
+
+	if isPlaylist(head) {
+		stream, err := OpenHLS(ctx, s)
+		switch {
+		case err == nil:
+			if buffered != nil {
+				buffered.Close()
+			}
+
+			b, err := newBufferedStream(stream, -1, DefaultBufferLimit)
+			if err != nil {
+				return nil, fmt.Errorf("OpenURL error: %w", err)
+			}
+
+			b.ContentType = HLSMediaType
+			return b, nil
+		case !errors.Is(err, errNotPlaylist):
+			if buffered != nil {
+				buffered.Close()
+			}
+
+			return nil, fmt.Errorf("OpenURL error: %w", err)
+		}
+
+		// Plain M3U playlists are served as they are.
+	}
+
+	if src != nil {
+		return src, nil
+	}
+
+	return buffered, nil
+}
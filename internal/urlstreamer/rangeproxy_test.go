@@ -0,0 +1,80 @@
+package urlstreamer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testMedia = bytes.Repeat([]byte("0123456789"), 100)
+
+func TestProbeURL(t *testing.T) {
+	ranged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Now(), bytes.NewReader(testMedia))
+	}))
+	defer ranged.Close()
+
+	// No HEAD support, but ranged GET requests work.
+	noHead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.ServeContent(w, r, "video.mp4", time.Now(), bytes.NewReader(testMedia))
+	}))
+	defer noHead.Close()
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testMedia)
+	}))
+	defer plain.Close()
+
+	tt := []struct {
+		input   string
+		wantErr bool
+		name    string
+	}{
+		{ranged.URL, false, `Ranged origin`},
+		{noHead.URL, false, `Ranged origin without HEAD`},
+		{plain.URL, true, `Origin without ranges`},
+	}
+
+	for _, tc := range tt {
+		src, err := ProbeURL(context.Background(), tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got: %v, want error: %t.", tc.name, err, tc.wantErr)
+			continue
+		}
+
+		if err == nil && src.Length != int64(len(testMedia)) {
+			t.Errorf("%s: got: %d, want: %d.", tc.name, src.Length, len(testMedia))
+		}
+	}
+}
+
+func TestRangeSourceOpen(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "video.mp4", time.Now(), bytes.NewReader(testMedia))
+	}))
+	defer origin.Close()
+
+	src, err := ProbeURL(context.Background(), origin.URL)
+	if err != nil {
+		t.Fatalf("ProbeURL: %s", err)
+	}
+
+	resp, err := src.Open(context.Background(), "bytes=990-")
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "0123456789" {
+		t.Errorf("Open: got: %s %q, want: 206 %q.", resp.Status, body, "0123456789")
+	}
+}
@@ -11,6 +11,17 @@ import (
 
 // StreamURL - Start the URL media streaming
 func StreamURL(ctx context.Context, s string) (io.ReadCloser, error) {
+	resp, err := getURL(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	body := resp.Body
+
+	return body, nil
+}
+
+func getURL(ctx context.Context, s string) (*http.Response, error) {
 	_, err := url.ParseRequestURI(s)
 	if err != nil {
 		return nil, fmt.Errorf("streamURL failed to parse url: %w", err)
@@ -28,10 +39,9 @@ func StreamURL(ctx context.Context, s string) (io.ReadCloser, error) {
 	}
 
 	if resp.StatusCode >= 400 {
+		resp.Body.Close()
 		return nil, errors.New("streamURL bad status code: " + resp.Status)
 	}
 
-	body := resp.Body
-
-	return body, nil
+	return resp, nil
 }
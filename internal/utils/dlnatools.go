@@ -1,46 +1,94 @@
 package utils
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/alexballas/go2tv/internal/containers"
 	"github.com/h2non/filetype"
 )
 
 var (
-	// If we're looking to use the dlnaOrgFlagSenderPaced
-	// flag for a 32bit build, we need to make sure that we
-	// first convert all the flag types to int64
-	//dlnaOrgFlagSenderPaced = 1 << 31
+	// The sender paced flag doesn't fit in a 32bit int,
+	// so all the flag types need to be int64.
+	dlnaOrgFlagSenderPaced int64 = 1 << 31
 	//dlnaOrgFlagTimeBasedSeek = 1 << 30
 	//dlnaOrgFlagByteBasedSeek = 1 << 29
 	//dlnaOrgFlagPlayContainer = 1 << 28
 	//dlnaOrgFlagS0Increase = 1 << 27
 	//dlnaOrgFlagSnIncrease = 1 << 26
 	//dlnaOrgFlagRtspPause = 1 << 25
-	dlnaOrgFlagStreamingTransferMode = 1 << 24
+	dlnaOrgFlagStreamingTransferMode int64 = 1 << 24
 	//dlnaOrgFlagInteractiveTransfertMode = 1 << 23
-	dlnaOrgFlagBackgroundTransfertMode = 1 << 22
-	dlnaOrgFlagConnectionStall         = 1 << 21
-	dlnaOrgFlagDlnaV15                 = 1 << 20
+	dlnaOrgFlagBackgroundTransfertMode int64 = 1 << 22
+	dlnaOrgFlagConnectionStall         int64 = 1 << 21
+	dlnaOrgFlagDlnaV15                 int64 = 1 << 20
 
+	// The video containers don't get a fixed profile, as the profile
+	// depends on the codecs and the resolution. We probe local files
+	// for it and omit the PN for the rest. The same goes for the
+	// formats that have no DLNA profile at all, e.g. FLAC or WebM.
 	dlnaprofiles = map[string]string{
-		"video/x-mkv":             "DLNA.ORG_PN=MATROSKA",
-		"video/x-matroska":        "DLNA.ORG_PN=MATROSKA",
+		"video/x-mkv":             "",
+		"video/x-matroska":        "",
 		"video/x-msvideo":         "DLNA.ORG_PN=AVI",
 		"video/mpeg":              "DLNA.ORG_PN=MPEG1",
-		"video/vnd.dlna.mpeg-tts": "DLNA.ORG_PN=MPEG1",
-		"video/mp4":               "DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5",
-		"video/quicktime":         "DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5",
-		"video/x-m4v":             "DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5",
-		"video/3gpp":              "DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5",
-		"video/x-flv":             "DLNA.ORG_PN=AVC_MP4_MP_SD_AAC_MULT5",
+		"video/vnd.dlna.mpeg-tts": "",
+		"video/mp2t":              "",
+		"video/mp4":               "",
+		"video/quicktime":         "",
+		"video/x-m4v":             "",
+		"video/3gpp":              "",
+		"video/x-flv":             "",
+		"video/webm":              "",
+		"video/ogg":               "",
 		"audio/mpeg":              "DLNA.ORG_PN=MP3",
-		"image/jpeg":              "JPEG_LRG",
-		"image/png":               "PNG_LRG"}
+		"audio/mp4":               "DLNA.ORG_PN=AAC_ISO",
+		"audio/aac":               "DLNA.ORG_PN=AAC_ADTS",
+		"audio/x-flac":            "",
+		"audio/x-wav":             "",
+		"audio/ogg":               "",
+		"image/jpeg":              "DLNA.ORG_PN=JPEG_LRG",
+		"image/png":               "DLNA.ORG_PN=PNG_LRG",
+		"image/gif":               "DLNA.ORG_PN=GIF_LRG"}
+
+	// extensionMediaTypes - The media types of the formats that
+	// filetype can't sniff, or can't tell apart, by extension.
+	extensionMediaTypes = map[string]string{
+		".ts":   "video/mp2t",
+		".m2ts": "video/mp2t",
+		".mts":  "video/mp2t",
+		".m4a":  "audio/mp4",
+		".aac":  "audio/aac",
+		".flac": "audio/x-flac",
+		".wav":  "audio/x-wav",
+		".ogg":  "audio/ogg",
+		".oga":  "audio/ogg",
+		".opus": "audio/ogg",
+		".ogv":  "video/ogg",
+		".webm": "video/webm",
+		".mkv":  "video/x-matroska",
+		".mp4":  "video/mp4",
+		".m4v":  "video/x-m4v",
+		".mov":  "video/quicktime",
+		".avi":  "video/x-msvideo",
+		".mpg":  "video/mpeg",
+		".mpeg": "video/mpeg",
+		".mp3":  "audio/mpeg",
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+		".png":  "image/png",
+		".gif":  "image/gif"}
+
+	// mediaTypeAliases - filetype uses a few media types
+	// that the Media Renderers don't know about.
+	mediaTypeAliases = map[string]string{
+		"audio/m4a": "audio/mp4"}
 )
 
 func defaultStreamingFlags() string {
@@ -50,26 +98,52 @@ func defaultStreamingFlags() string {
 		dlnaOrgFlagDlnaV15, 0)
 }
 
+// senderPacedFlags - We're the clock of the stream, as we
+// limit the rate that we serve the media at.
+func senderPacedFlags() string {
+	return fmt.Sprintf("%.8x%.24x", dlnaOrgFlagSenderPaced|
+		dlnaOrgFlagStreamingTransferMode|
+		dlnaOrgFlagBackgroundTransfertMode|
+		dlnaOrgFlagConnectionStall|
+		dlnaOrgFlagDlnaV15, 0)
+}
+
+// ContentFeatures - The media details that we
+// advertise in the "contentFeatures.dlna.org" header.
+type ContentFeatures struct {
+	MediaType string
+	// DLNAProfile is the DLNA.ORG_PN profile of the media, e.g. as
+	// probed from the media file. Without a profile we fall back to
+	// the MediaType one, and media types we don't know get the
+	// generic profile, i.e. no DLNA.ORG_PN.
+	DLNAProfile string
+	// Seek is the DLNA.ORG_OP flag of the media.
+	Seek      string
+	Transcode bool
+	// Paced sets the sender paced flag when we
+	// limit the rate of the media.
+	Paced bool
+}
+
 // BuildContentFeatures - Build the content features string
 // for the "contentFeatures.dlna.org" header.
-func BuildContentFeatures(mediaType string, seek string, transcode bool) (string, error) {
+func BuildContentFeatures(c ContentFeatures) (string, error) {
 	var cf strings.Builder
 
-	if mediaType != "" {
-		dlnaProf, profExists := dlnaprofiles[mediaType]
-		switch profExists {
-		case true:
-			cf.WriteString(dlnaProf + ";")
-		default:
-			return "", errors.New("non supported mediaType")
-		}
+	dlnaProfile := c.DLNAProfile
+	if dlnaProfile == "" {
+		dlnaProfile = strings.TrimPrefix(dlnaprofiles[c.MediaType], "DLNA.ORG_PN=")
+	}
+
+	if dlnaProfile != "" {
+		cf.WriteString("DLNA.ORG_PN=" + dlnaProfile + ";")
 	}
 
 	// "00" neither time seek range nor range supported
 	// "01" range supported
 	// "10" time seek range supported
 	// "11" both time seek range and range supported
-	switch seek {
+	switch c.Seek {
 	case "00":
 		cf.WriteString("DLNA.ORG_OP=00;")
 	case "01":
@@ -82,7 +156,7 @@ func BuildContentFeatures(mediaType string, seek string, transcode bool) (string
 		return "", errors.New("invalid seek flag")
 	}
 
-	switch transcode {
+	switch c.Transcode {
 	case true:
 		cf.WriteString("DLNA.ORG_CI=1;")
 	default:
@@ -90,12 +164,34 @@ func BuildContentFeatures(mediaType string, seek string, transcode bool) (string
 	}
 
 	cf.WriteString("DLNA.ORG_FLAGS=")
-	cf.WriteString(defaultStreamingFlags())
+	switch c.Paced {
+	case true:
+		cf.WriteString(senderPacedFlags())
+	default:
+		cf.WriteString(defaultStreamingFlags())
+	}
 
 	return cf.String(), nil
 }
 
-// GetMimeDetailsFromFile - Get media file mime details.
+// ProtocolInfo - Build the protocolInfo of the media, as used in the
+// DIDL-Lite res elements. Media without a DLNA profile get the generic
+// "*" one.
+func ProtocolInfo(mediaType, dlnaProfile string) string {
+	if dlnaProfile == "" {
+		dlnaProfile = strings.TrimPrefix(dlnaprofiles[mediaType], "DLNA.ORG_PN=")
+	}
+
+	info := "*"
+	if dlnaProfile != "" {
+		info = "DLNA.ORG_PN=" + dlnaProfile
+	}
+
+	return "http-get:*:" + mediaType + ":" + info
+}
+
+// GetMimeDetailsFromFile - Get media file mime details. When we
+// can't sniff the media type we go by the file extension.
 func GetMimeDetailsFromFile(f string) (string, error) {
 	file, err := os.Open(f)
 	if err != nil {
@@ -103,27 +199,89 @@ func GetMimeDetailsFromFile(f string) (string, error) {
 	}
 	defer file.Close()
 
-	head := make([]byte, 261)
-	file.Read(head)
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(file, head)
 
-	kind, err := filetype.Match(head)
+	mediaType, err := sniffMediaType(head[:n])
 	if err != nil {
 		return "", fmt.Errorf("getMimeDetailsFromFile error #2: %w", err)
 	}
 
-	return fmt.Sprintf("%s/%s", kind.MIME.Type, kind.MIME.Subtype), nil
+	if mediaType == "" {
+		mediaType = extensionMediaTypes[strings.ToLower(filepath.Ext(f))]
+	}
+
+	if mediaType == "" {
+		return "", errors.New("getMimeDetailsFromFile: unknown media type")
+	}
+
+	return mediaType, nil
 }
 
 // GetMimeDetailsFromStream - Get media URL mime details.
 func GetMimeDetailsFromStream(s io.ReadCloser) (string, error) {
 	defer s.Close()
-	head := make([]byte, 261)
-	s.Read(head)
 
-	kind, err := filetype.Match(head)
+	mediaType, _, err := PeekMimeDetails(s)
 	if err != nil {
 		return "", fmt.Errorf("getMimeDetailsFromStream error: %w", err)
 	}
 
-	return fmt.Sprintf("%s/%s", kind.MIME.Type, kind.MIME.Subtype), nil
+	// The URL streams of unknown media always got the
+	// empty "/" media type, which we keep for them.
+	if mediaType == "" {
+		mediaType = "/"
+	}
+
+	return mediaType, nil
+}
+
+// PeekMimeDetails - Get the mime details of a non-seekable stream, e.g.
+// the standard input, without losing the bytes we sniff. The media
+// should be read from the returned reader instead of the stream. The
+// media type is empty when we don't know the media.
+func PeekMimeDetails(s io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(s, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, fmt.Errorf("peekMimeDetails read error: %w", err)
+	}
+	head = head[:n]
+
+	r := io.MultiReader(bytes.NewReader(head), s)
+
+	mediaType, err := sniffMediaType(head)
+	if err != nil {
+		return "", nil, fmt.Errorf("peekMimeDetails error: %w", err)
+	}
+
+	return mediaType, r, nil
+}
+
+// We need a few MPEG-TS packets to be sure.
+const sniffLen = containers.TSHeadSize
+
+// sniffMediaType returns an empty media type when we don't know the media.
+func sniffMediaType(head []byte) (string, error) {
+	kind, err := filetype.Match(head)
+	if err != nil {
+		return "", err
+	}
+
+	if kind == filetype.Unknown {
+		// filetype doesn't know about MPEG-TS. We look for the sync
+		// byte of the first packets, with or without the M2TS prefix.
+		if containers.IsTS(head, containers.TSPacketSize, 0) || containers.IsTS(head, containers.M2TSPacketSize, 4) {
+			return "video/mp2t", nil
+		}
+
+		return "", nil
+	}
+
+	mediaType := kind.MIME.Value
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		mediaType = alias
+	}
+
+	return mediaType, nil
 }
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetMimeDetailsFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	ts := make([]byte, 3*188)
+	ts[0], ts[188], ts[376] = 0x47, 0x47, 0x47
+
+	files := map[string][]byte{
+		"video.mp4":  append([]byte{0, 0, 0, 0x18}, []byte("ftypisom")...),
+		"video.ts":   ts,
+		"audio.flac": []byte("fLaC"),
+		"song.m4a":   append([]byte{0, 0, 0, 0x18}, []byte("ftypM4A ")...),
+		"audio.ogg":  []byte("not really an ogg file"),
+		"notes.txt":  []byte("not a media file"),
+		// The GIF signature starts with the MPEG-TS sync byte.
+		"image.gif": append([]byte("GIF89a"), bytes.Repeat([]byte{0x47}, 3*192)...),
+	}
+
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), append(b, bytes.Repeat([]byte{0xff}, 16)...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tt := []struct {
+		input string
+		want  string
+		name  string
+	}{
+		{"video.mp4", "video/mp4", `Sniffed MP4`},
+		{"video.ts", "video/mp2t", `Sniffed MPEG-TS`},
+		{"audio.flac", "audio/x-flac", `Sniffed FLAC`},
+		{"song.m4a", "audio/mp4", `Aliased M4A`},
+		{"audio.ogg", "audio/ogg", `Extension fallback`},
+		{"image.gif", "image/gif", `Sniffed GIF`},
+	}
+
+	for _, tc := range tt {
+		out, err := GetMimeDetailsFromFile(filepath.Join(dir, tc.input))
+		if err != nil {
+			t.Errorf("%s: GetMimeDetailsFromFile error: %s", tc.name, err)
+			continue
+		}
+
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+
+	if _, err := GetMimeDetailsFromFile(filepath.Join(dir, "notes.txt")); err == nil {
+		t.Errorf("Unknown media: expected error")
+	}
+}
+
+func TestProtocolInfo(t *testing.T) {
+	tt := []struct {
+		mediaType   string
+		dlnaProfile string
+		want        string
+		name        string
+	}{
+		{"audio/mpeg", "", "http-get:*:audio/mpeg:DLNA.ORG_PN=MP3", `MediaType profile`},
+		{"image/gif", "", "http-get:*:image/gif:DLNA.ORG_PN=GIF_LRG", `GIF profile`},
+		{"video/mp4", "AVC_MP4_HP_HD_AAC", "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_HD_AAC", `Probed profile`},
+		{"audio/x-flac", "", "http-get:*:audio/x-flac:*", `No profile`},
+		{"video/x-unknown", "", "http-get:*:video/x-unknown:*", `Unknown media type`},
+	}
+
+	for _, tc := range tt {
+		if out := ProtocolInfo(tc.mediaType, tc.dlnaProfile); out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+}
+
+func TestBuildContentFeatures(t *testing.T) {
+	flags := "DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+	tt := []struct {
+		mediaType string
+		seek      string
+		want      string
+		name      string
+	}{
+		{"audio/mpeg", "01", "DLNA.ORG_PN=MP3;DLNA.ORG_OP=01;DLNA.ORG_CI=0;" + flags, `MP3`},
+		{"image/png", "00", "DLNA.ORG_PN=PNG_LRG;DLNA.ORG_OP=00;DLNA.ORG_CI=0;" + flags, `PNG`},
+		{"audio/ogg", "01", "DLNA.ORG_OP=01;DLNA.ORG_CI=0;" + flags, `No profile`},
+		{"video/x-unknown", "01", "DLNA.ORG_OP=01;DLNA.ORG_CI=0;" + flags, `Unknown media type`},
+	}
+
+	for _, tc := range tt {
+		out, err := BuildContentFeatures(ContentFeatures{MediaType: tc.mediaType, Seek: tc.seek})
+		if err != nil {
+			t.Errorf("%s: BuildContentFeatures error: %s", tc.name, err)
+			continue
+		}
+
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+
+	if _, err := BuildContentFeatures(ContentFeatures{MediaType: "audio/mpeg", Seek: "02"}); err == nil {
+		t.Errorf("Invalid seek flag: expected error")
+	}
+
+	paced, err := BuildContentFeatures(ContentFeatures{MediaType: "audio/mpeg", Seek: "01", Paced: true})
+	if err != nil {
+		t.Fatalf("Sender paced: BuildContentFeatures error: %s", err)
+	}
+
+	if want := "DLNA.ORG_PN=MP3;DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=81700000000000000000000000000000"; paced != want {
+		t.Errorf("Sender paced: got: %s, want: %s.", paced, want)
+	}
+}
+
+func TestPeekMimeDetails(t *testing.T) {
+	media := append([]byte{0, 0, 0, 0x18}, []byte("ftypisom")...)
+	media = append(media, bytes.Repeat([]byte("video"), 200)...)
+
+	mediaType, r, err := PeekMimeDetails(io.NopCloser(bytes.NewReader(media)))
+	if err != nil {
+		t.Fatalf("PeekMimeDetails error: %s", err)
+	}
+
+	if mediaType != "video/mp4" {
+		t.Errorf("PeekMimeDetails: got: %s, want: video/mp4.", mediaType)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("PeekMimeDetails read error: %s", err)
+	}
+
+	if !bytes.Equal(out, media) {
+		t.Errorf("PeekMimeDetails: got: %d bytes, want: %d bytes.", len(out), len(media))
+	}
+
+	// Short streams are fine too.
+	mediaType, r, err = PeekMimeDetails(bytes.NewReader([]byte("short")))
+	if err != nil {
+		t.Fatalf("PeekMimeDetails short stream error: %s", err)
+	}
+
+	if out, _ := io.ReadAll(r); mediaType != "" || string(out) != "short" {
+		t.Errorf("PeekMimeDetails short stream: got: %q %q, want: no media type and \"short\".", mediaType, out)
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
@@ -9,38 +10,155 @@ import (
 	"time"
 )
 
+// ListenConfig - Network settings for the media server.
+type ListenConfig struct {
+	// Interfaces restricts the listen IP to
+	// the selected network interfaces.
+	Interfaces []string
+	// Advertise overrides the host[:port] in the URLs
+	// we send to the Media Renderers. Useful when running
+	// in a container or behind NAT.
+	Advertise string
+	// Port pins the listen port. When zero, we pick
+	// the first available port starting from 3500.
+	Port int
+	// AllowedHosts lets extra hosts, besides the Media
+	// Renderer, fetch the media and the subtitles.
+	AllowedHosts []string
+	// Rate limits the rate that we serve the media at,
+	// so that we don't saturate the network.
+	Rate StreamRate
+}
+
+// Addresses - For a given Media Renderer URL, return the
+// host:port pair to listen to and the one to advertise.
+func (c ListenConfig) Addresses(u string) (string, string, error) {
+	ipToListen, err := urlToListenIP(u, c.Interfaces)
+	if err != nil {
+		return "", "", fmt.Errorf("Addresses listen IP error: %w", err)
+	}
+
+	var portToListen string
+	switch c.Port {
+	case 0:
+		portToListen, err = checkAndPickPort(ipToListen, 3500)
+		if err != nil {
+			return "", "", fmt.Errorf("Addresses port error: %w", err)
+		}
+	default:
+		portToListen = strconv.Itoa(c.Port)
+		conn, err := net.Listen("tcp", net.JoinHostPort(ipToListen, portToListen))
+		if err != nil {
+			return "", "", fmt.Errorf("Addresses pinned port error: %w", err)
+		}
+		conn.Close()
+	}
+
+	listen := net.JoinHostPort(ipToListen, portToListen)
+
+	advertise, err := AdvertisedHostPort(listen, c.Advertise)
+	if err != nil {
+		return "", "", fmt.Errorf("Addresses advertise error: %w", err)
+	}
+
+	return listen, advertise, nil
+}
+
+// ListenIP - For a given Media Renderer URL, return the IP to listen
+// to, without picking a port. Useful to tell if an already running
+// media server can serve the Media Renderer.
+func (c ListenConfig) ListenIP(u string) (string, error) {
+	ip, err := urlToListenIP(u, c.Interfaces)
+	if err != nil {
+		return "", fmt.Errorf("ListenIP error: %w", err)
+	}
+
+	return ip, nil
+}
+
+// AdvertisedHostPort - Return the host:port pair we advertise
+// to the Media Renderers. The advertise value can be a host
+// or a host:port pair. When it doesn't include a port,
+// we advertise the listen port.
+func AdvertisedHostPort(listen, advertise string) (string, error) {
+	if advertise == "" {
+		return listen, nil
+	}
+
+	_, listenPort, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("AdvertisedHostPort listen address error: %w", err)
+	}
+
+	host, port, err := net.SplitHostPort(advertise)
+	if err != nil {
+		// No port provided. This also covers
+		// IPv6 addresses without brackets.
+		host, port = strings.Trim(advertise, "[]"), listenPort
+	}
+
+	if host == "" {
+		return "", errors.New("empty advertise host")
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return "", errors.New("invalid advertise port: " + port)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
 // URLtoListenIPandPort for a given internal URL,
 // find the correct IP/Interface to listen to.
-func URLtoListenIPandPort(u string) (string, error) {
+// When network interface names are provided, we only
+// pick an IP address that belongs to one of them.
+func URLtoListenIPandPort(u string, ifaces ...string) (string, error) {
+	res, _, err := ListenConfig{Interfaces: ifaces}.Addresses(u)
+	if err != nil {
+		return "", fmt.Errorf("URLtoListenIPandPort error: %w", err)
+	}
+
+	return res, nil
+}
+
+func urlToListenIP(u string, ifaces []string) (string, error) {
 	parsedURL, err := url.Parse(u)
 	if err != nil {
-		return "", fmt.Errorf("URLtoListenIPandPort parse error: %w", err)
+		return "", fmt.Errorf("urlToListenIP parse error: %w", err)
 	}
 
 	callURL := parsedURL.Host
 	if parsedURL.Port() == "" {
 		switch parsedURL.Scheme {
 		case "http":
-			callURL = callURL + ":80"
+			callURL = net.JoinHostPort(parsedURL.Hostname(), "80")
 		case "https":
-			callURL = callURL + ":443"
+			callURL = net.JoinHostPort(parsedURL.Hostname(), "443")
 		}
 	}
 
+	if len(ifaces) > 0 {
+		ipToListen, err := interfaceIPFor(parsedURL.Hostname(), ifaces)
+		if err != nil {
+			return "", fmt.Errorf("urlToListenIP interface error: %w", err)
+		}
+		return ipToListen, nil
+	}
+
 	conn, err := net.Dial("udp", callURL)
 	if err != nil {
-		return "", fmt.Errorf("URLtoListenIPandPort UDP call error: %w", err)
+		return "", fmt.Errorf("urlToListenIP UDP call error: %w", err)
 	}
+	conn.Close()
 
-	ipToListen := strings.Split(conn.LocalAddr().String(), ":")[0]
-	portToListen, err := checkAndPickPort(ipToListen, 3500)
+	// For IPv6 link-local addresses the host
+	// also includes the zone, e.g. fe80::1%eth0.
+	ipToListen, _, err := net.SplitHostPort(conn.LocalAddr().String())
 	if err != nil {
-		return "", fmt.Errorf("URLtoListenIPandPort port error: %w", err)
+		return "", fmt.Errorf("urlToListenIP local address error: %w", err)
 	}
 
-	res := net.JoinHostPort(ipToListen, portToListen)
-
-	return res, nil
+	return ipToListen, nil
 }
 
 func checkAndPickPort(ip string, port int) (string, error) {
@@ -74,3 +192,197 @@ func HostPortIsAlive(h string) bool {
 	conn.Close()
 	return true
 }
+
+// NetInterfaces - Convert the network interface names
+// to net.Interface values.
+func NetInterfaces(names []string) ([]net.Interface, error) {
+	out := make([]net.Interface, 0, len(names))
+	for _, n := range names {
+		ifi, err := net.InterfaceByName(n)
+		if err != nil {
+			return nil, fmt.Errorf("NetInterfaces error for %q: %w", n, err)
+		}
+		out = append(out, *ifi)
+	}
+
+	return out, nil
+}
+
+// InterfaceNames - Return the names of the network interfaces
+// that are up and have at least one IP address assigned.
+func InterfaceNames() ([]string, error) {
+	iflist, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("InterfaceNames error: %w", err)
+	}
+
+	out := make([]string, 0)
+	for _, ifi := range iflist {
+		if ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		if len(interfaceIPs(ifi)) == 0 {
+			continue
+		}
+
+		out = append(out, ifi.Name)
+	}
+
+	return out, nil
+}
+
+// InterfaceForHost - Return the name of the local network
+// interface we reach the host IP through, as the routing
+// table has it, falling back to the interface whose network
+// contains the host IP. An empty string is returned if there
+// is no such interface.
+func InterfaceForHost(host string) string {
+	ip, zone := splitZone(host)
+	if zone != "" {
+		return zone
+	}
+
+	if ip == nil {
+		return ""
+	}
+
+	iflist, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	// Connecting a UDP socket doesn't send anything,
+	// it only picks the route to the host.
+	if conn, err := net.Dial("udp", net.JoinHostPort(ip.String(), "9")); err == nil {
+		local := conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+
+		for _, ifi := range iflist {
+			addrs, err := ifi.Addrs()
+			if err != nil {
+				continue
+			}
+
+			for _, a := range addrs {
+				ipnet, ok := a.(*net.IPNet)
+				if ok && ipnet.IP.Equal(local) {
+					return ifi.Name
+				}
+			}
+		}
+	}
+
+	for _, ifi := range iflist {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if ok && ipnet.Contains(ip) {
+				return ifi.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// interfaceIPFor returns the IP address we should listen to
+// on the selected interfaces. We prefer an address in the same
+// network as the host and fall back to the first usable one of
+// the same IP family. Link-local IPv6 addresses include the zone.
+func interfaceIPFor(host string, names []string) (string, error) {
+	iflist, err := NetInterfaces(names)
+	if err != nil {
+		return "", err
+	}
+
+	hostIP, hostZone := splitZone(host)
+	if hostIP == nil {
+		if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
+			hostIP = ips[0]
+		}
+	}
+
+	wantIPv6 := hostIP != nil && hostIP.To4() == nil
+
+	var fallback string
+	for _, ifi := range iflist {
+		if hostZone != "" && hostZone != ifi.Name {
+			continue
+		}
+
+		for _, ip := range interfaceIPs(ifi) {
+			if (ip.IP.To4() == nil) != wantIPv6 {
+				continue
+			}
+
+			candidate := ip.IP.String()
+			if ip.IP.IsLinkLocalUnicast() && ip.IP.To4() == nil {
+				candidate = candidate + "%" + ifi.Name
+			}
+
+			if hostIP != nil && ip.Contains(hostIP) {
+				return candidate, nil
+			}
+
+			if fallback == "" {
+				fallback = candidate
+			}
+		}
+	}
+
+	if fallback == "" {
+		return "", errors.New("no usable IP address on the selected interfaces")
+	}
+
+	return fallback, nil
+}
+
+// splitZone parses an IP address that may include
+// an IPv6 zone, e.g. fe80::1%eth0.
+func splitZone(host string) (net.IP, string) {
+	var zone string
+	if i := strings.LastIndex(host, "%"); i > 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+
+	return net.ParseIP(host), zone
+}
+
+func interfaceIPs(ifi net.Interface) []*net.IPNet {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+
+	out := make([]*net.IPNet, 0)
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if ok && !ipnet.IP.IsUnspecified() {
+			out = append(out, ipnet)
+		}
+	}
+
+	return out
+}
+
+// BuildHTTPURL - Build the HTTP URL that we advertise to
+// the Media Renderers for the host:port pair and the already
+// escaped path. IPv6 addresses are bracketed and the zone is
+// dropped, as it only has a meaning on our side of the link.
+func BuildHTTPURL(hostPort, path string) string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return "http://" + hostPort + "/" + path
+	}
+
+	if i := strings.LastIndex(host, "%"); i > 0 {
+		host = host[:i]
+	}
+
+	return "http://" + net.JoinHostPort(host, port) + "/" + path
+}
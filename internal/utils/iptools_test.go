@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net"
 	"strconv"
 	"strings"
 	"testing"
@@ -54,3 +55,163 @@ func TestURLtoListenIPandPort(t *testing.T) {
 		}
 	}
 }
+
+func TestURLtoListenIPandPortInterface(t *testing.T) {
+	iflist, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("Failed to list interfaces due to %s", err.Error())
+	}
+
+	var loopback string
+	for _, ifi := range iflist {
+		if ifi.Flags&net.FlagLoopback != 0 {
+			loopback = ifi.Name
+			break
+		}
+	}
+
+	if loopback == "" {
+		t.Skip("no loopback interface available")
+	}
+
+	out, err := URLtoListenIPandPort(`http://127.0.0.1:9197/dmr`, loopback)
+	if err != nil {
+		t.Fatalf("Failed to call URLtoListenIPandPort due to %s", err.Error())
+	}
+
+	host, _, err := net.SplitHostPort(out)
+	if err != nil {
+		t.Fatalf("Not in ip:port format: %s", out)
+	}
+
+	if host != "127.0.0.1" {
+		t.Errorf("got: %s, want: %s.", host, "127.0.0.1")
+	}
+
+	if iface := InterfaceForHost("127.0.0.1"); iface != loopback {
+		t.Errorf("got: %s, want: %s.", iface, loopback)
+	}
+}
+
+func TestBuildHTTPURL(t *testing.T) {
+	tt := []struct {
+		name     string
+		hostPort string
+		path     string
+		want     string
+	}{
+		{
+			`Test #1`,
+			`192.168.88.250:3500`,
+			`video.mp4`,
+			`http://192.168.88.250:3500/video.mp4`,
+		},
+		{
+			`Test #2`,
+			`[2001:db8::10]:3500`,
+			`video.mp4`,
+			`http://[2001:db8::10]:3500/video.mp4`,
+		},
+		{
+			`Test #3`,
+			`[fe80::10%eth0]:3500`,
+			`video.mp4`,
+			`http://[fe80::10]:3500/video.mp4`,
+		},
+	}
+
+	for _, tc := range tt {
+		out := BuildHTTPURL(tc.hostPort, tc.path)
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+}
+
+func TestURLtoListenIPandPortIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback available")
+	}
+	ln.Close()
+
+	out, err := URLtoListenIPandPort(`http://[::1]:9197/dmr`)
+	if err != nil {
+		t.Fatalf("Failed to call URLtoListenIPandPort due to %s", err.Error())
+	}
+
+	host, _, err := net.SplitHostPort(out)
+	if err != nil {
+		t.Fatalf("Not in ip:port format: %s", out)
+	}
+
+	if host != "::1" {
+		t.Errorf("got: %s, want: %s.", host, "::1")
+	}
+}
+
+func TestAdvertisedHostPort(t *testing.T) {
+	tt := []struct {
+		name      string
+		listen    string
+		advertise string
+		want      string
+	}{
+		{
+			`Test #1`,
+			`172.17.0.2:3500`,
+			``,
+			`172.17.0.2:3500`,
+		},
+		{
+			`Test #2`,
+			`172.17.0.2:3500`,
+			`192.168.1.20`,
+			`192.168.1.20:3500`,
+		},
+		{
+			`Test #3`,
+			`172.17.0.2:3500`,
+			`nas.lan:8500`,
+			`nas.lan:8500`,
+		},
+		{
+			`Test #4`,
+			`172.17.0.2:3500`,
+			`2001:db8::20`,
+			`[2001:db8::20]:3500`,
+		},
+	}
+
+	for _, tc := range tt {
+		out, err := AdvertisedHostPort(tc.listen, tc.advertise)
+		if err != nil {
+			t.Errorf("%s: Failed to call AdvertisedHostPort due to %s", tc.name, err.Error())
+			continue
+		}
+		if out != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, out, tc.want)
+		}
+	}
+
+	if _, err := AdvertisedHostPort(`172.17.0.2:3500`, `nas.lan:99999`); err == nil {
+		t.Errorf("Test #5: expected error for invalid port")
+	}
+}
+
+func TestListenConfigPinnedPort(t *testing.T) {
+	c := ListenConfig{Port: 4321, Advertise: "192.168.1.20:8500"}
+
+	listen, advertise, err := c.Addresses(`http://127.0.0.1:9197/dmr`)
+	if err != nil {
+		t.Fatalf("Failed to call Addresses due to %s", err.Error())
+	}
+
+	if listen != "127.0.0.1:4321" {
+		t.Errorf("got: %s, want: %s.", listen, "127.0.0.1:4321")
+	}
+
+	if advertise != "192.168.1.20:8500" {
+		t.Errorf("got: %s, want: %s.", advertise, "192.168.1.20:8500")
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// StreamRate - Rate limit for the media that we serve.
+type StreamRate struct {
+	// Cap is the maximum rate in bytes per second.
+	// When zero, we don't cap the rate.
+	Cap int64
+	// Paced paces the media to its bitrate,
+	// when we know it.
+	Paced bool
+}
+
+// Enabled - We limit the rate of the media.
+func (r StreamRate) Enabled() bool {
+	return r.Cap > 0 || r.Paced
+}
+
+// String - The rate limit in the ParseStreamRate form.
+func (r StreamRate) String() string {
+	var parts []string
+
+	if r.Paced {
+		parts = append(parts, "auto")
+	}
+
+	if r.Cap > 0 {
+		bits := r.Cap * 8
+		switch {
+		case bits%1e9 == 0:
+			parts = append(parts, strconv.FormatInt(bits/1e9, 10)+"G")
+		case bits%1e6 == 0:
+			parts = append(parts, strconv.FormatInt(bits/1e6, 10)+"M")
+		case bits%1e3 == 0:
+			parts = append(parts, strconv.FormatInt(bits/1e3, 10)+"k")
+		default:
+			parts = append(parts, strconv.FormatInt(bits, 10))
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ParseStreamRate - Parse a comma separated rate limit, i.e. "auto"
+// to pace the media to its bitrate, a fixed cap in bits per second
+// (e.g. 20M, 2500k or 20Mbps), or both. An empty string means no limit.
+func ParseStreamRate(s string) (StreamRate, error) {
+	var r StreamRate
+
+	for _, v := range strings.Split(s, ",") {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+
+		if v == "auto" {
+			r.Paced = true
+			continue
+		}
+
+		bits, err := parseBitrate(v)
+		if err != nil {
+			return StreamRate{}, fmt.Errorf("ParseStreamRate error: %w", err)
+		}
+
+		r.Cap = bits / 8
+	}
+
+	return r, nil
+}
+
+func parseBitrate(v string) (int64, error) {
+	v = strings.TrimSuffix(v, "bps")
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(v, "k"):
+		multiplier = 1e3
+	case strings.HasSuffix(v, "m"):
+		multiplier = 1e6
+	case strings.HasSuffix(v, "g"):
+		multiplier = 1e9
+	}
+	v = strings.TrimRight(v, "kmg")
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parseBitrate error: %w", err)
+	}
+
+	bits := n * multiplier
+	if math.IsNaN(bits) || bits < 8 || bits > math.MaxInt64 {
+		return 0, errors.New("parseBitrate: invalid rate " + v)
+	}
+
+	return int64(bits), nil
+}
@@ -0,0 +1,40 @@
+package utils
+
+import "testing"
+
+func TestParseStreamRate(t *testing.T) {
+	tt := []struct {
+		input string
+		want  StreamRate
+		name  string
+	}{
+		{"", StreamRate{}, `No limit`},
+		{"auto", StreamRate{Paced: true}, `Paced`},
+		{"20M", StreamRate{Cap: 2500000}, `Mbit/s`},
+		{"2500kbps", StreamRate{Cap: 312500}, `kbit/s`},
+		{" auto , 1.5G ", StreamRate{Cap: 187500000, Paced: true}, `Paced with cap`},
+		{"8000", StreamRate{Cap: 1000}, `bit/s`},
+	}
+
+	for _, tc := range tt {
+		out, err := ParseStreamRate(tc.input)
+		if err != nil {
+			t.Errorf("%s: ParseStreamRate error: %s", tc.name, err)
+			continue
+		}
+
+		if out != tc.want {
+			t.Errorf("%s: got: %+v, want: %+v.", tc.name, out, tc.want)
+		}
+
+		if back, _ := ParseStreamRate(out.String()); back != out {
+			t.Errorf("%s: String round trip got: %+v, want: %+v.", tc.name, back, out)
+		}
+	}
+
+	for _, input := range []string{"fast", "-20M", "0", "inf", "nan"} {
+		if _, err := ParseStreamRate(input); err == nil {
+			t.Errorf("Invalid rate %q: expected error", input)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTimeSeekRange - Parse the TimeSeekRange.dlna.org request
+// header, e.g. "npt=10.5-" or "npt=00:01:00.000-00:02:00.000".
+// The end is -1 when the range is open ended.
+func ParseTimeSeekRange(h string) (time.Duration, time.Duration, error) {
+	h = strings.TrimSpace(h)
+	if !strings.HasPrefix(h, "npt=") {
+		return 0, 0, errors.New("parseTimeSeekRange: missing npt prefix")
+	}
+
+	// Some renderers also send the bytes range,
+	// e.g. "npt=10.0- bytes=1000-".
+	fields := strings.Fields(strings.TrimPrefix(h, "npt="))
+	if len(fields) == 0 {
+		return 0, 0, errors.New("parseTimeSeekRange: empty range")
+	}
+
+	parts := strings.SplitN(fields[0], "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New("parseTimeSeekRange: invalid range")
+	}
+
+	start, err := ParseNPT(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("parseTimeSeekRange start error: %w", err)
+	}
+
+	end := time.Duration(-1)
+	if parts[1] != "" {
+		end, err = ParseNPT(parts[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("parseTimeSeekRange end error: %w", err)
+		}
+
+		if end < start {
+			return 0, 0, errors.New("parseTimeSeekRange: end before start")
+		}
+	}
+
+	return start, end, nil
+}
+
+// ParseNPT - Parse a Normal Play Time value. Both the
+// seconds (10.5) and the hh:mm:ss (0:00:10.5) forms are valid.
+func ParseNPT(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.New("parseNPT: empty value")
+	}
+
+	var secs float64
+	for _, p := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, errors.New("parseNPT: invalid value " + s)
+		}
+		secs = secs*60 + v
+	}
+
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// FormatNPT - Format a duration as a hh:mm:ss.sss Normal Play Time.
+func FormatNPT(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// TimeSeekRangeHeader - Build the TimeSeekRange.dlna.org
+// response header, e.g. "npt=0:00:10.000-0:01:00.000/0:01:00.000".
+func TimeSeekRangeHeader(start, end, duration time.Duration) string {
+	if end < 0 {
+		end = duration
+	}
+
+	return fmt.Sprintf("npt=%s-%s/%s", FormatNPT(start), FormatNPT(end), FormatNPT(duration))
+}
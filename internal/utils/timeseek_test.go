@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeSeekRange(t *testing.T) {
+	tt := []struct {
+		name      string
+		input     string
+		wantStart time.Duration
+		wantEnd   time.Duration
+	}{
+		{
+			`Seconds`,
+			`npt=10.5-`,
+			10500 * time.Millisecond,
+			-1,
+		},
+		{
+			`hh:mm:ss`,
+			`npt=0:01:00.000-0:02:30`,
+			time.Minute,
+			150 * time.Second,
+		},
+		{
+			`With bytes`,
+			`npt=1:00:00- bytes=1000-`,
+			time.Hour,
+			-1,
+		},
+	}
+
+	for _, tc := range tt {
+		start, end, err := ParseTimeSeekRange(tc.input)
+		if err != nil {
+			t.Errorf("%s: Failed to call ParseTimeSeekRange due to %s", tc.name, err.Error())
+			continue
+		}
+		if start != tc.wantStart || end != tc.wantEnd {
+			t.Errorf("%s: got: %s-%s, want: %s-%s.", tc.name, start, end, tc.wantStart, tc.wantEnd)
+		}
+	}
+
+	for _, input := range []string{"10-", "npt=", "npt=abc-", "npt=20-10"} {
+		if _, _, err := ParseTimeSeekRange(input); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestTimeSeekRangeHeader(t *testing.T) {
+	got := TimeSeekRangeHeader(90*time.Second, -1, time.Hour+1500*time.Millisecond)
+	want := "npt=0:01:30.000-1:00:01.500/1:00:01.500"
+
+	if got != want {
+		t.Errorf("got: %s, want: %s.", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"net/url"
 	"path"
 	"strings"
@@ -12,3 +13,14 @@ func ConvertFilename(s string) string {
 	out = strings.ReplaceAll(out, "+", "%20")
 	return out
 }
+
+// TokenizedPath - Build an unguessable URL path for the file. We keep
+// the file name at the end, as some Media Renderers go by its extension.
+func TokenizedPath(s string) (string, error) {
+	token, err := RandomString()
+	if err != nil {
+		return "", fmt.Errorf("TokenizedPath error: %w", err)
+	}
+
+	return token + "/" + ConvertFilename(s), nil
+}
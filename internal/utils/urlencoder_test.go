@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -30,3 +31,27 @@ func TestCovertFilename(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenizedPath(t *testing.T) {
+	a, err := TokenizedPath("/home/user/something & something.mp4")
+	if err != nil {
+		t.Fatalf("TokenizedPath error: %s", err)
+	}
+
+	b, err := TokenizedPath("/home/user/something & something.mp4")
+	if err != nil {
+		t.Fatalf("TokenizedPath error: %s", err)
+	}
+
+	if !strings.HasSuffix(a, "/something%20%26%20something.mp4") {
+		t.Errorf("TokenizedPath: got: %s, want: the file name at the end.", a)
+	}
+
+	if a == b {
+		t.Errorf("TokenizedPath: got the same path twice: %s.", a)
+	}
+
+	if strings.Contains(a, "home") {
+		t.Errorf("TokenizedPath: got: %s, want: no directories.", a)
+	}
+}
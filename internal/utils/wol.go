@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	neighbourIPRe  = regexp.MustCompile(`\b(\d{1,3}\.){3}\d{1,3}\b`)
+	neighbourMACRe = regexp.MustCompile(`\b([0-9a-fA-F]{1,2}[:-]){5}[0-9a-fA-F]{1,2}\b`)
+)
+
+// MagicPacket - Build the Wake-on-LAN magic packet for the MAC
+// address. Six 0xFF bytes followed by 16 copies of the address.
+func MagicPacket(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("MagicPacket error: invalid MAC address %q", mac.String())
+	}
+
+	var b bytes.Buffer
+	b.Write(bytes.Repeat([]byte{0xFF}, 6))
+	for i := 0; i < 16; i++ {
+		b.Write(mac)
+	}
+
+	return b.Bytes(), nil
+}
+
+// SendMagicPacket - Broadcast the Wake-on-LAN magic packet. We send
+// it to the limited broadcast address and to the directed broadcast
+// address of every IPv4 network, as the limited broadcast only
+// leaves through the default interface on some systems.
+func SendMagicPacket(mac net.HardwareAddr) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return fmt.Errorf("SendMagicPacket listen error: %w", err)
+	}
+	defer conn.Close()
+
+	targets := append([]net.IP{net.IPv4bcast}, directedBroadcasts()...)
+
+	var sent int
+	for _, ip := range targets {
+		if _, err := conn.WriteTo(packet, &net.UDPAddr{IP: ip, Port: 9}); err == nil {
+			sent++
+		}
+	}
+
+	if sent == 0 {
+		return fmt.Errorf("SendMagicPacket error: could not send the magic packet")
+	}
+
+	return nil
+}
+
+func directedBroadcasts() []net.IP {
+	iflist, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	out := make([]net.IP, 0)
+	for _, ifi := range iflist {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+
+		for _, ipnet := range interfaceIPs(ifi) {
+			ip4 := ipnet.IP.To4()
+			if ip4 == nil || len(ipnet.Mask) != net.IPv4len {
+				continue
+			}
+
+			bcast := make(net.IP, net.IPv4len)
+			for i := range ip4 {
+				bcast[i] = ip4[i] | ^ipnet.Mask[i]
+			}
+			out = append(out, bcast)
+		}
+	}
+
+	return out
+}
+
+// NeighbourMACs - Return the IPv4 to MAC address mappings
+// of the OS neighbour (ARP) table.
+func NeighbourMACs() (map[string]net.HardwareAddr, error) {
+	var out []byte
+	var err error
+
+	switch runtime.GOOS {
+	case "linux", "android":
+		out, err = os.ReadFile("/proc/net/arp")
+	case "windows":
+		out, err = exec.Command("arp", "-a").Output()
+	default:
+		out, err = exec.Command("arp", "-an").Output()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("NeighbourMACs error: %w", err)
+	}
+
+	return parseNeighbours(string(out)), nil
+}
+
+// parseNeighbours parses the neighbour table formats of the
+// different platforms. Each entry is on its own line and has
+// the IP address before the MAC address.
+func parseNeighbours(table string) map[string]net.HardwareAddr {
+	out := make(map[string]net.HardwareAddr)
+
+	for _, line := range strings.Split(table, "\n") {
+		ip := neighbourIPRe.FindString(line)
+		macStr := neighbourMACRe.FindString(line)
+		if ip == "" || macStr == "" {
+			continue
+		}
+
+		mac, err := parseLooseMAC(macStr)
+		if err != nil || bytes.Equal(mac, make(net.HardwareAddr, 6)) {
+			// Incomplete entries have a zero MAC address.
+			continue
+		}
+
+		out[ip] = mac
+	}
+
+	return out
+}
+
+// parseLooseMAC also accepts MAC addresses without the leading
+// zeros, e.g. a:b:c:d:e:f, as printed by the BSD arp command.
+func parseLooseMAC(s string) (net.HardwareAddr, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ':' || r == '-'
+	})
+
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+
+	return net.ParseMAC(strings.Join(parts, ":"))
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+
+	out, err := MagicPacket(mac)
+	if err != nil {
+		t.Fatalf("MagicPacket: %s", err)
+	}
+
+	if len(out) != 102 {
+		t.Fatalf("MagicPacket: got: %d bytes, want: 102.", len(out))
+	}
+
+	if !bytes.Equal(out[:6], bytes.Repeat([]byte{0xFF}, 6)) {
+		t.Errorf("MagicPacket: got: %x, want: six 0xFF bytes.", out[:6])
+	}
+
+	for i := 6; i < len(out); i += 6 {
+		if !bytes.Equal(out[i:i+6], mac) {
+			t.Errorf("MagicPacket: got: %x at %d, want: %x.", out[i:i+6], i, []byte(mac))
+		}
+	}
+}
+
+func TestParseNeighbours(t *testing.T) {
+	tt := []struct {
+		input string
+		ip    string
+		want  string
+		name  string
+	}{
+		{
+			"IP address       HW type     Flags       HW address            Mask     Device\n" +
+				"192.168.1.10     0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0\n",
+			"192.168.1.10",
+			"aa:bb:cc:dd:ee:ff",
+			`Linux /proc/net/arp`,
+		},
+		{
+			"? (192.168.1.10) at a:b:c:d:e:f on en0 ifscope [ethernet]\n",
+			"192.168.1.10",
+			"0a:0b:0c:0d:0e:0f",
+			`BSD arp -an`,
+		},
+		{
+			"Interface: 192.168.1.5 --- 0xb\n" +
+				"  Internet Address      Physical Address      Type\n" +
+				"  192.168.1.10          aa-bb-cc-dd-ee-ff     dynamic\n",
+			"192.168.1.10",
+			"aa:bb:cc:dd:ee:ff",
+			`Windows arp -a`,
+		},
+		{
+			"192.168.1.10     0x1         0x0         00:00:00:00:00:00     *        eth0\n",
+			"192.168.1.10",
+			"",
+			`Incomplete entry`,
+		},
+	}
+
+	for _, tc := range tt {
+		got := parseNeighbours(tc.input)[tc.ip].String()
+		if got != tc.want {
+			t.Errorf("%s: got: %s, want: %s.", tc.name, got, tc.want)
+		}
+	}
+}